@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"wasfaty.api/pkg/cerror"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/pkg/log"
+	"wasfaty.api/services/mpi/entity"
+)
+
+const (
+	StoreMemory   = "memory"
+	StorePostgres = "postgres"
+)
+
+// Config is the external document registry cache configuration
+type Config struct {
+	Enabled     bool          `env:"EXT_DOC_REGISTRY_CACHE_ENABLED" envDefault:"true"`
+	Store       string        `env:"EXT_DOC_REGISTRY_CACHE_STORE" envDefault:"memory"`
+	Size        int           `env:"EXT_DOC_REGISTRY_CACHE_SIZE" envDefault:"10000"`
+	TTL         time.Duration `env:"EXT_DOC_REGISTRY_CACHE_TTL" envDefault:"24h"`
+	NegativeTTL time.Duration `env:"EXT_DOC_REGISTRY_CACHE_NEGATIVE_TTL" envDefault:"1h"`
+	PostgresDSN string        `env:"EXT_DOC_REGISTRY_CACHE_POSTGRES_DSN"`
+	// the postgres store keys are the hmac of the document type and number by the secret
+	KeySecret string `env:"EXT_DOC_REGISTRY_CACHE_KEY_SECRET"`
+	// the expired results are deleted from the postgres store every interval
+	PurgeInterval time.Duration `env:"EXT_DOC_REGISTRY_CACHE_PURGE_INTERVAL" envDefault:"1h"`
+}
+
+// Client is the decorated external document registry client
+type Client interface {
+	Search(ctx context.Context, i *fhirModel.Identifier) (*entity.ExtDocRegistrySearchResult, error)
+}
+
+// Store keeps the registry results until their expiration
+type Store interface {
+	Get(ctx context.Context, key string) (*entity.ExtDocRegistrySearchResult, bool, error)
+	Set(ctx context.Context, key string, r *entity.ExtDocRegistrySearchResult, ttl time.Duration) error
+}
+
+// Stats is the snapshot of the cache metrics
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Coalesced uint64 `json:"coalesced"`
+	Errors    uint64 `json:"errors"`
+}
+
+type call struct {
+	done chan struct{}
+	r    *entity.ExtDocRegistrySearchResult
+	err  error
+}
+
+// Cache caches the registry results, the invalid documents are cached with the negative ttl,
+// the concurrent lookups of the same identifier share one registry call
+type Cache struct {
+	client Client
+	store  Store
+	cfg    *Config
+
+	mu    sync.Mutex
+	calls map[string]*call
+
+	hits      uint64
+	misses    uint64
+	coalesced uint64
+	errors    uint64
+}
+
+func New(c Client, s Store, cfg *Config) *Cache {
+	return &Cache{client: c, store: s, cfg: cfg, calls: make(map[string]*call)}
+}
+
+func (c *Cache) Search(ctx context.Context, i *fhirModel.Identifier) (*entity.ExtDocRegistrySearchResult, error) {
+	key := cacheKey(i)
+
+	r, ok, err := c.store.Get(ctx, key)
+	if err != nil {
+		atomic.AddUint64(&c.errors, 1)
+		log.ErrorF(ctx, "external registry cache get error: %s", err)
+	}
+
+	if ok {
+		atomic.AddUint64(&c.hits, 1)
+		return r, nil
+	}
+
+	atomic.AddUint64(&c.misses, 1)
+
+	return c.search(ctx, key, i)
+}
+
+// Stats returns the cache metrics
+func (c *Cache) Stats() Stats {
+	return Stats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Coalesced: atomic.LoadUint64(&c.coalesced),
+		Errors:    atomic.LoadUint64(&c.errors),
+	}
+}
+
+// search calls the registry once per key for all the concurrent lookups, the waiting lookups give up
+// on their own context only
+func (c *Cache) search(ctx context.Context, key string, i *fhirModel.Identifier) (
+	*entity.ExtDocRegistrySearchResult, error) {
+	c.mu.Lock()
+	if cl, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		atomic.AddUint64(&c.coalesced, 1)
+
+		select {
+		case <-cl.done:
+			return cl.r, cl.err
+		case <-ctx.Done():
+			return nil, cerror.New(ctx, cerror.KindInternal, ctx.Err()).LogError()
+		}
+	}
+
+	cl := &call{done: make(chan struct{})}
+	c.calls[key] = cl
+	c.mu.Unlock()
+
+	// the waiters are released even if the registry call panics
+	defer c.finish(ctx, key, cl)
+
+	// the shared call is not canceled with the request that started it
+	callCtx := detachedContext{ctx}
+
+	cl.r, cl.err = c.client.Search(callCtx, i)
+	if cl.err == nil {
+		ttl := c.cfg.TTL
+		if !cl.r.IsValid {
+			ttl = c.cfg.NegativeTTL
+		}
+
+		if ttl > 0 {
+			if err := c.store.Set(callCtx, key, cl.r, ttl); err != nil {
+				atomic.AddUint64(&c.errors, 1)
+				log.ErrorF(ctx, "external registry cache set error: %s", err)
+			}
+		}
+	}
+
+	return cl.r, cl.err
+}
+
+// finish releases the waiters of the call, the call without a result and an error is the panicked one
+func (c *Cache) finish(ctx context.Context, key string, cl *call) {
+	if cl.r == nil && cl.err == nil {
+		cl.err = cerror.NewF(ctx, cerror.KindInternal, "external registry search is interrupted").LogError()
+	}
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	c.mu.Unlock()
+
+	close(cl.done)
+}
+
+// detachedContext keeps the values of the parent context, such as the trace, without its cancellation
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func cacheKey(i *fhirModel.Identifier) string {
+	var code string
+	if i.Type != nil && len(i.Type.Codings) > 0 {
+		code = i.Type.Codings[0].Code
+	}
+
+	return fmt.Sprintf("%s:%s", code, i.Value)
+}
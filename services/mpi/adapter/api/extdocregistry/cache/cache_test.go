@@ -0,0 +1,264 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"wasfaty.api/pkg/cerror"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/pkg/log"
+	"wasfaty.api/services/mpi/adapter/api/extdocregistry/cache"
+	"wasfaty.api/services/mpi/entity"
+)
+
+type testClient struct {
+	calls   int64
+	release chan struct{}
+	results map[string]*entity.ExtDocRegistrySearchResult
+	err     error
+	panics  bool
+}
+
+func (c *testClient) Search(ctx context.Context, i *fhirModel.Identifier) (*entity.ExtDocRegistrySearchResult, error) {
+	atomic.AddInt64(&c.calls, 1)
+
+	if c.release != nil {
+		<-c.release
+	}
+
+	if c.panics {
+		panic("registry client panic")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	if r, ok := c.results[i.Value]; ok {
+		return r, nil
+	}
+
+	return &entity.ExtDocRegistrySearchResult{IsValid: false}, nil
+}
+
+type cacheTestSuite struct {
+	suite.Suite
+	now    time.Time
+	client *testClient
+	store  *cache.LRUStore
+	cache  *cache.Cache
+}
+
+func TestCacheTestSuite(t *testing.T) {
+	log.SetGlobalLogLevel("fatal")
+	suite.Run(t, new(cacheTestSuite))
+}
+
+func (s *cacheTestSuite) SetupTest() {
+	s.now = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.client = &testClient{results: map[string]*entity.ExtDocRegistrySearchResult{
+		"1000000008": {IsValid: true, GivenName: "Ahmad", Status: "ACTIVE"},
+	}}
+	s.store = cache.NewLRUStore(2).WithClock(func() time.Time { return s.now })
+	s.cache = cache.New(s.client, s.store, &cache.Config{TTL: time.Hour, NegativeTTL: time.Minute})
+}
+
+func (s *cacheTestSuite) TestSearch() {
+	ctx := context.Background()
+
+	r, err := s.cache.Search(ctx, nationalID("1000000008"))
+	s.Require().NoError(err)
+	s.Require().True(r.IsValid)
+	s.Require().Equal("Ahmad", r.GivenName)
+
+	r, err = s.cache.Search(ctx, nationalID("1000000008"))
+	s.Require().NoError(err)
+	s.Require().True(r.IsValid)
+
+	s.Require().EqualValues(1, s.client.calls)
+	s.Require().Equal(cache.Stats{Hits: 1, Misses: 1}, s.cache.Stats())
+
+	// the result expires after the ttl
+	s.now = s.now.Add(time.Hour)
+
+	_, err = s.cache.Search(ctx, nationalID("1000000008"))
+	s.Require().NoError(err)
+	s.Require().EqualValues(2, s.client.calls)
+}
+
+func (s *cacheTestSuite) TestSearchNegativeTTL() {
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		r, err := s.cache.Search(ctx, nationalID("1000000016"))
+		s.Require().NoError(err)
+		s.Require().False(r.IsValid)
+	}
+
+	s.Require().EqualValues(1, s.client.calls)
+
+	s.now = s.now.Add(time.Minute)
+
+	_, err := s.cache.Search(ctx, nationalID("1000000016"))
+	s.Require().NoError(err)
+	s.Require().EqualValues(2, s.client.calls)
+}
+
+func (s *cacheTestSuite) TestSearchErrorIsNotCached() {
+	ctx := context.Background()
+	s.client.err = cerror.NewF(ctx, cerror.KindInternal, "registry is unavailable")
+
+	_, err := s.cache.Search(ctx, nationalID("1000000008"))
+	s.Require().Error(err)
+
+	s.client.err = nil
+
+	r, err := s.cache.Search(ctx, nationalID("1000000008"))
+	s.Require().NoError(err)
+	s.Require().True(r.IsValid)
+	s.Require().EqualValues(2, s.client.calls)
+}
+
+func (s *cacheTestSuite) TestSearchCoalescing() {
+	ctx := context.Background()
+	s.client.release = make(chan struct{})
+
+	const lookups = 10
+
+	var wg sync.WaitGroup
+
+	results := make([]*entity.ExtDocRegistrySearchResult, lookups)
+
+	for i := 0; i < lookups; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			r, err := s.cache.Search(ctx, nationalID("1000000008"))
+			s.NoError(err)
+
+			results[i] = r
+		}(i)
+	}
+
+	// wait until all the lookups but the registry call are waiting for the result
+	s.Require().Eventually(func() bool {
+		return s.cache.Stats().Coalesced == lookups-1
+	}, time.Second, time.Millisecond)
+
+	close(s.client.release)
+	wg.Wait()
+
+	s.Require().EqualValues(1, s.client.calls)
+
+	for _, r := range results {
+		s.Require().NotNil(r)
+		s.Require().True(r.IsValid)
+	}
+}
+
+func (s *cacheTestSuite) TestSearchCoalescingLeaderCanceled() {
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	s.client.release = make(chan struct{})
+
+	leaderErr := make(chan error, 1)
+
+	go func() {
+		_, err := s.cache.Search(leaderCtx, nationalID("1000000008"))
+		leaderErr <- err
+	}()
+
+	s.Require().Eventually(func() bool {
+		return atomic.LoadInt64(&s.client.calls) == 1
+	}, time.Second, time.Millisecond)
+
+	waiterResult := make(chan *entity.ExtDocRegistrySearchResult, 1)
+
+	go func() {
+		r, err := s.cache.Search(context.Background(), nationalID("1000000008"))
+		s.NoError(err)
+
+		waiterResult <- r
+	}()
+
+	s.Require().Eventually(func() bool {
+		return s.cache.Stats().Coalesced == 1
+	}, time.Second, time.Millisecond)
+
+	// the canceled leader request does not fail the shared call
+	cancel()
+	close(s.client.release)
+
+	s.Require().NoError(<-leaderErr)
+
+	r := <-waiterResult
+	s.Require().NotNil(r)
+	s.Require().True(r.IsValid)
+}
+
+func (s *cacheTestSuite) TestSearchCoalescingLeaderPanics() {
+	s.client.release = make(chan struct{})
+	s.client.panics = true
+
+	go func() {
+		defer func() { _ = recover() }()
+
+		_, _ = s.cache.Search(context.Background(), nationalID("1000000008"))
+	}()
+
+	s.Require().Eventually(func() bool {
+		return atomic.LoadInt64(&s.client.calls) == 1
+	}, time.Second, time.Millisecond)
+
+	waiterErr := make(chan error, 1)
+
+	go func() {
+		_, err := s.cache.Search(context.Background(), nationalID("1000000008"))
+		waiterErr <- err
+	}()
+
+	s.Require().Eventually(func() bool {
+		return s.cache.Stats().Coalesced == 1
+	}, time.Second, time.Millisecond)
+
+	close(s.client.release)
+
+	// the waiter is released with an error instead of hanging
+	s.Require().Error(<-waiterErr)
+}
+
+func (s *cacheTestSuite) TestLRUEviction() {
+	ctx := context.Background()
+
+	for _, v := range []string{"1", "2", "1", "3"} {
+		_, err := s.cache.Search(ctx, nationalID(v))
+		s.Require().NoError(err)
+	}
+
+	s.Require().Equal(2, s.store.Len())
+
+	// "2" is evicted as the least recently used one
+	_, ok, err := s.store.Get(ctx, "NI:2")
+	s.Require().NoError(err)
+	s.Require().False(ok)
+
+	_, ok, err = s.store.Get(ctx, "NI:1")
+	s.Require().NoError(err)
+	s.Require().True(ok)
+}
+
+func nationalID(v string) *fhirModel.Identifier {
+	return &fhirModel.Identifier{
+		Type:  &fhirModel.CodeableConcept{Codings: []*fhirModel.Coding{{Code: fhirModel.IdentNationalID}}},
+		Value: v,
+	}
+}
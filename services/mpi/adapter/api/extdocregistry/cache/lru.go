@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"wasfaty.api/services/mpi/entity"
+)
+
+type lruEntry struct {
+	key       string
+	r         *entity.ExtDocRegistrySearchResult
+	expiresAt time.Time
+}
+
+// LRUStore is the in-memory store evicting the least recently used results when the size is reached
+type LRUStore struct {
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+func NewLRUStore(size int) *LRUStore {
+	return &LRUStore{
+		size:    size,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// WithClock replaces the store clock, it is used to check the expiration in tests
+func (s *LRUStore) WithClock(now func() time.Time) *LRUStore {
+	s.now = now
+	return s
+}
+
+func (s *LRUStore) Get(_ context.Context, key string) (*entity.ExtDocRegistrySearchResult, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	e := el.Value.(*lruEntry)
+	if !s.now().Before(e.expiresAt) {
+		s.order.Remove(el)
+		delete(s.entries, key)
+
+		return nil, false, nil
+	}
+
+	s.order.MoveToFront(el)
+
+	return e.r, true, nil
+}
+
+func (s *LRUStore) Set(_ context.Context, key string, r *entity.ExtDocRegistrySearchResult, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt := s.now().Add(ttl)
+
+	if el, ok := s.entries[key]; ok {
+		e := el.Value.(*lruEntry)
+		e.r = r
+		e.expiresAt = expiresAt
+		s.order.MoveToFront(el)
+
+		return nil
+	}
+
+	s.entries[key] = s.order.PushFront(&lruEntry{key: key, r: r, expiresAt: expiresAt})
+
+	for s.size > 0 && s.order.Len() > s.size {
+		el := s.order.Back()
+		s.order.Remove(el)
+		delete(s.entries, el.Value.(*lruEntry).key)
+	}
+
+	return nil
+}
+
+// Len returns the number of the stored results including the expired ones
+func (s *LRUStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.order.Len()
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/services/mpi/entity"
+)
+
+const (
+	postgresDriver = "pgx"
+
+	createTableQuery = `CREATE TABLE IF NOT EXISTS ext_doc_registry_cache (
+	key        TEXT PRIMARY KEY,
+	result     JSONB NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+)`
+
+	getQuery = `SELECT result FROM ext_doc_registry_cache WHERE key = $1 AND expires_at > $2`
+
+	setQuery = `INSERT INTO ext_doc_registry_cache (key, result, expires_at) VALUES ($1, $2, $3)
+ON CONFLICT (key) DO UPDATE SET result = EXCLUDED.result, expires_at = EXCLUDED.expires_at`
+
+	purgeQuery = `DELETE FROM ext_doc_registry_cache WHERE expires_at <= $1`
+)
+
+// PostgresStore keeps the registry results in postgres, so they are shared between the service instances.
+// The results hold the registry personal data, so the expired rows are deleted by Purge
+// and are not kept longer than the purge interval after their ttl.
+// The keys hold the document numbers, so only their hmac is stored
+type PostgresStore struct {
+	db        *sql.DB
+	keySecret []byte
+}
+
+// NewPostgresStore connects to the database and creates the cache table if it does not exist
+func NewPostgresStore(ctx context.Context, dsn, keySecret string) (*PostgresStore, error) {
+	if keySecret == "" {
+		return nil, cerror.NewF(ctx, cerror.KindInternal, "key secret is required by the postgres cache store").
+			LogError()
+	}
+
+	db, err := sql.Open(postgresDriver, dsn)
+	if err != nil {
+		return nil, cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	if _, err := db.ExecContext(ctx, createTableQuery); err != nil {
+		_ = db.Close()
+		return nil, cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	return &PostgresStore{db: db, keySecret: []byte(keySecret)}, nil
+}
+
+func (s *PostgresStore) Get(ctx context.Context, key string) (*entity.ExtDocRegistrySearchResult, bool, error) {
+	var b []byte
+
+	err := s.db.QueryRowContext(ctx, getQuery, s.hashKey(key), time.Now().UTC()).Scan(&b)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	r := new(entity.ExtDocRegistrySearchResult)
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, false, cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	return r, true, nil
+}
+
+func (s *PostgresStore) Set(ctx context.Context, key string, r *entity.ExtDocRegistrySearchResult, ttl time.Duration) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	if _, err := s.db.ExecContext(ctx, setQuery, s.hashKey(key), b, time.Now().UTC().Add(ttl)); err != nil {
+		return cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	return nil
+}
+
+// Purge deletes the expired results and returns their count
+func (s *PostgresStore) Purge(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx, purgeQuery, time.Now().UTC())
+	if err != nil {
+		return 0, cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	return n, nil
+}
+
+// hashKey returns the hex hmac-sha256 of the key, the key could not be restored from it without the secret
+func (s *PostgresStore) hashKey(key string) string {
+	h := hmac.New(sha256.New, s.keySecret)
+	h.Write([]byte(key))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
@@ -2,26 +2,187 @@ package extdocregistry
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"time"
 
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+
+	"wasfaty.api/pkg/cerror"
 	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/pkg/http/headers"
 	"wasfaty.api/pkg/log"
 	"wasfaty.api/services/mpi/entity"
 )
 
+const (
+	IDTypeNationalID = "NIN"
+	IDTypeIqama      = "IQAMA"
+	IDTypeBorder     = "BORDER"
+
+	StatusActive   = "ACTIVE"
+	StatusDeceased = "DECEASED"
+
+	HeaderAPIKey = "X-API-Key"
+)
+
+// Config is the external document registry client configuration
+type Config struct {
+	Host           string        `env:"EXT_DOC_REGISTRY_HOST,required"`
+	APIKey         string        `env:"EXT_DOC_REGISTRY_API_KEY"`
+	RequestTimeout time.Duration `env:"EXT_DOC_REGISTRY_REQUEST_TIMEOUT" envDefault:"30s"`
+	// Fake answers the searches by the in-process fake registry for the local runs, it is refused in production
+	Fake bool `env:"EXT_DOC_REGISTRY_FAKE" envDefault:"false"`
+}
+
+type SearchReq struct {
+	IDType   string `json:"idType"`
+	IDNumber string `json:"idNumber"`
+}
+
+type Person struct {
+	IDType      string `json:"idType"`
+	IDNumber    string `json:"idNumber"`
+	GivenName   string `json:"givenName"`
+	FamilyName  string `json:"familyName"`
+	BirthDate   string `json:"birthDate"`
+	Gender      string `json:"gender"`
+	Nationality string `json:"nationality"`
+	Status      string `json:"status"`
+	DeathDate   string `json:"deathDate,omitempty"`
+}
+
+type SearchResp struct {
+	Data Person `json:"data"`
+}
+
+type HTTPClient interface {
+	Do(req *fasthttp.Request, resp *fasthttp.Response) error
+	DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error
+}
+
 type Client struct {
+	cfg        *Config
+	httpClient HTTPClient
+}
+
+func NewClient(cfg *Config) *Client {
+	return &Client{cfg: cfg, httpClient: new(fasthttp.Client)}
 }
 
-func NewClient() *Client {
-	return new(Client)
+func (c *Client) WithHTTPClient(h HTTPClient) *Client {
+	c.httpClient = h
+	return c
 }
 
 func (c *Client) Search(ctx context.Context, i *fhirModel.Identifier) (*entity.ExtDocRegistrySearchResult, error) {
 	var code string
-	if len(i.Type.Codings) > 0 {
+	if i.Type != nil && len(i.Type.Codings) > 0 {
 		code = i.Type.Codings[0].Code
 	}
 
-	log.InfoF(ctx, "sending request to external registry for %s:%s", code, i.Value)
+	idType, ok := identTypes()[code]
+	if !ok {
+		return nil, cerror.NewF(ctx, cerror.KindBadParams, "identifier type %s is not supported by external registry", code).
+			LogError()
+	}
+
+	// the document number is personal data, so only its type is logged
+	log.InfoF(ctx, "sending request to external registry for %s", code)
 
-	return &entity.ExtDocRegistrySearchResult{IsValid: true}, nil
+	b, err := json.Marshal(SearchReq{IDType: idType, IDNumber: i.Value})
+	if err != nil {
+		return nil, cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	dst := new(SearchResp)
+
+	if err := c.sendRequest(ctx, c.cfg.Host+"/search", fiber.MethodPost, b, dst); err != nil {
+		if cerror.ErrKind(err) == cerror.KindNotExist {
+			return &entity.ExtDocRegistrySearchResult{IsValid: false}, nil
+		}
+
+		return nil, err
+	}
+
+	return &entity.ExtDocRegistrySearchResult{
+		IsValid:     dst.Data.Status == StatusActive,
+		GivenName:   dst.Data.GivenName,
+		FamilyName:  dst.Data.FamilyName,
+		BirthDate:   dst.Data.BirthDate,
+		Gender:      dst.Data.Gender,
+		Nationality: dst.Data.Nationality,
+		Status:      dst.Data.Status,
+		Deceased:    dst.Data.Status == StatusDeceased,
+		DeathDate:   dst.Data.DeathDate,
+	}, nil
+}
+
+func (c *Client) sendRequest(ctx context.Context, url, method string, body []byte, dst interface{}) error {
+	var err error
+
+	req := fasthttp.AcquireRequest()
+
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetContentType(fiber.MIMEApplicationJSON)
+	req.Header.SetMethod(method)
+
+	headers.AddHeadersFromContext(ctx, req)
+
+	if c.cfg.APIKey != "" {
+		req.Header.Set(HeaderAPIKey, c.cfg.APIKey)
+	}
+
+	req.SetRequestURI(url)
+	req.SetBody(body)
+
+	resp := fasthttp.AcquireResponse()
+
+	defer fasthttp.ReleaseResponse(resp)
+
+	if c.cfg.RequestTimeout != 0 {
+		err = c.httpClient.DoTimeout(req, resp, c.cfg.RequestTimeout)
+	} else {
+		err = c.httpClient.Do(req, resp)
+	}
+
+	if err != nil {
+		return cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	respStatus := resp.StatusCode()
+	respBody := resp.Body()
+
+	if !(respStatus >= 200 && respStatus < 300) {
+		var payload cerror.ResponseErrorWrap
+
+		var errMsg string
+		if err := json.Unmarshal(respBody, &payload); err == nil && payload.Error.Message != "" {
+			errMsg = fmt.Sprintf("external registry error: %s", payload.Error.Message)
+		} else {
+			errMsg = fmt.Sprintf("external registry error. Code: %d", respStatus)
+		}
+
+		cErr := cerror.NewF(ctx, cerror.KindFromHTTPCode(respStatus), errMsg).WithPayload(payload).LogError()
+
+		return cErr.WithPayload(nil)
+	}
+
+	if dst != nil {
+		if err := json.Unmarshal(respBody, dst); err != nil {
+			return cerror.New(ctx, cerror.KindInternal, err).LogError()
+		}
+	}
+
+	return nil
+}
+
+// identTypes maps the patient identifier codes to the registry id types
+func identTypes() map[string]string {
+	return map[string]string{
+		fhirModel.IdentNationalID:                  IDTypeNationalID,
+		fhirModel.IdentPermanentResidentCardNumber: IDTypeIqama,
+		fhirModel.IdentBorderNumber:                IDTypeBorder,
+	}
 }
@@ -0,0 +1,156 @@
+package extdocregistry_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/valyala/fasthttp"
+	"wasfaty.api/pkg/cerror"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/pkg/log"
+	"wasfaty.api/services/mpi/adapter/api/extdocregistry"
+	"wasfaty.api/services/mpi/adapter/api/extdocregistry/fake"
+	"wasfaty.api/services/mpi/entity"
+)
+
+type extDocRegistryTestSuite struct {
+	suite.Suite
+	cfg    *extdocregistry.Config
+	server *fake.Server
+	client *extdocregistry.Client
+}
+
+type mockClient struct {
+	DoFunc func(req *fasthttp.Request, resp *fasthttp.Response) error
+}
+
+func (m *mockClient) Do(req *fasthttp.Request, resp *fasthttp.Response) error {
+	return m.DoFunc(req, resp)
+}
+
+func (m *mockClient) DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	return m.DoFunc(req, resp)
+}
+
+func TestExtDocRegistryAdapterTestSuite(t *testing.T) {
+	log.SetGlobalLogLevel("fatal")
+	suite.Run(t, new(extDocRegistryTestSuite))
+}
+
+func (s *extDocRegistryTestSuite) SetupSuite() {
+	s.cfg = &extdocregistry.Config{Host: "http://registry", RequestTimeout: time.Second}
+	s.server = fake.NewServer()
+	s.server.Add(
+		extdocregistry.Person{
+			IDType:      extdocregistry.IDTypeNationalID,
+			IDNumber:    "1000000008",
+			GivenName:   "Ahmad",
+			FamilyName:  "Alharbi",
+			BirthDate:   "1990-01-01",
+			Nationality: "SA",
+			Status:      extdocregistry.StatusActive,
+		},
+		extdocregistry.Person{
+			IDType:   extdocregistry.IDTypeIqama,
+			IDNumber: "2000000006",
+			Status:   "EXPIRED",
+		},
+		extdocregistry.Person{
+			IDType:    extdocregistry.IDTypeNationalID,
+			IDNumber:  "1000000016",
+			Status:    extdocregistry.StatusDeceased,
+			DeathDate: "2026-01-01",
+		},
+	)
+}
+
+func (s *extDocRegistryTestSuite) SetupTest() {
+	s.client = extdocregistry.NewClient(s.cfg).WithHTTPClient(s.server.HTTPClient())
+}
+
+func (s *extDocRegistryTestSuite) TearDownSuite() {
+	s.NoError(s.server.Close())
+}
+
+func (s *extDocRegistryTestSuite) TestSearch() {
+	ctx := context.Background()
+
+	r, err := s.client.Search(ctx, identifier(fhirModel.IdentNationalID, "1000000008"))
+	s.NoError(err)
+	s.Equal(&entity.ExtDocRegistrySearchResult{
+		IsValid:     true,
+		GivenName:   "Ahmad",
+		FamilyName:  "Alharbi",
+		BirthDate:   "1990-01-01",
+		Nationality: "SA",
+		Status:      extdocregistry.StatusActive,
+	}, r)
+
+	r, err = s.client.Search(ctx, identifier(fhirModel.IdentPermanentResidentCardNumber, "2000000006"))
+	s.NoError(err)
+	s.False(r.IsValid)
+	s.Equal("EXPIRED", r.Status)
+
+	r, err = s.client.Search(ctx, identifier(fhirModel.IdentNationalID, "1000000016"))
+	s.NoError(err)
+	s.False(r.IsValid)
+	s.True(r.Deceased)
+	s.Equal("2026-01-01", r.DeathDate)
+
+	r, err = s.client.Search(ctx, identifier(fhirModel.IdentBorderNumber, "3000000004"))
+	s.NoError(err)
+	s.Equal(&entity.ExtDocRegistrySearchResult{IsValid: false}, r)
+
+	_, err = s.client.Search(ctx, identifier(fhirModel.IdentPassport, "AB123"))
+	s.Error(err)
+	s.Equal(cerror.KindBadParams.String(), cerror.ErrKind(err).String())
+}
+
+func (s *extDocRegistryTestSuite) TestSearchRequest() {
+	cfg := *s.cfg
+	cfg.APIKey = "secret"
+
+	var isCalled bool
+
+	c := extdocregistry.NewClient(&cfg).WithHTTPClient(&mockClient{
+		DoFunc: func(req *fasthttp.Request, resp *fasthttp.Response) error {
+			isCalled = true
+
+			s.Equal("http://registry/search", req.URI().String())
+			s.Equal(http.MethodPost, string(req.Header.Method()))
+			s.Equal("secret", string(req.Header.Peek(extdocregistry.HeaderAPIKey)))
+			s.JSONEq(`{"idType":"BORDER","idNumber":"3000000004"}`, string(req.Body()))
+
+			resp.SetStatusCode(http.StatusServiceUnavailable)
+
+			return nil
+		},
+	})
+
+	_, err := c.Search(context.Background(), identifier(fhirModel.IdentBorderNumber, "3000000004"))
+	s.True(isCalled)
+	s.Error(err)
+	s.Equal(cerror.KindFromHTTPCode(http.StatusServiceUnavailable).String(), cerror.ErrKind(err).String())
+	s.Equal("external registry error. Code: 503", err.Error())
+}
+
+func (s *extDocRegistryTestSuite) TestFakeServerAllowUnknown() {
+	server := fake.NewServer().AllowUnknown()
+	defer server.Close()
+
+	c := extdocregistry.NewClient(s.cfg).WithHTTPClient(server.HTTPClient())
+
+	r, err := c.Search(context.Background(), identifier(fhirModel.IdentNationalID, "1000000008"))
+	s.NoError(err)
+	s.True(r.IsValid)
+}
+
+func identifier(code, value string) *fhirModel.Identifier {
+	return &fhirModel.Identifier{
+		Type:  &fhirModel.CodeableConcept{Codings: []*fhirModel.Coding{{Code: code}}},
+		Value: value,
+	}
+}
@@ -0,0 +1,113 @@
+// Package fake provides an in-process external document registry server for tests and local runs
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/services/mpi/adapter/api/extdocregistry"
+)
+
+type Server struct {
+	mu      sync.RWMutex
+	persons map[string]extdocregistry.Person
+
+	// allowUnknown makes the server answer with an active person for any unknown id
+	allowUnknown bool
+
+	ln  *fasthttputil.InmemoryListener
+	srv *fasthttp.Server
+}
+
+func NewServer() *Server {
+	s := &Server{
+		persons: make(map[string]extdocregistry.Person),
+		ln:      fasthttputil.NewInmemoryListener(),
+	}
+	s.srv = &fasthttp.Server{Handler: s.handle}
+
+	go func() {
+		_ = s.srv.Serve(s.ln)
+	}()
+
+	return s
+}
+
+// AllowUnknown makes the server treat every unknown id as an active person, it is used for local runs only
+func (s *Server) AllowUnknown() *Server {
+	s.allowUnknown = true
+	return s
+}
+
+func (s *Server) Add(persons ...extdocregistry.Person) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range persons {
+		s.persons[key(p.IDType, p.IDNumber)] = p
+	}
+}
+
+// HTTPClient returns the client sending the requests to the server
+func (s *Server) HTTPClient() *fasthttp.Client {
+	return &fasthttp.Client{
+		Dial: func(addr string) (net.Conn, error) {
+			return s.ln.Dial()
+		},
+	}
+}
+
+func (s *Server) Close() error {
+	return s.ln.Close()
+}
+
+func (s *Server) handle(ctx *fasthttp.RequestCtx) {
+	if string(ctx.Path()) != "/search" || !ctx.IsPost() {
+		writeError(ctx, cerror.KindNotExist, "route not found")
+		return
+	}
+
+	req := new(extdocregistry.SearchReq)
+	if err := json.Unmarshal(ctx.PostBody(), req); err != nil || req.IDType == "" || req.IDNumber == "" {
+		writeError(ctx, cerror.KindBadParams, "invalid request")
+		return
+	}
+
+	s.mu.RLock()
+	p, ok := s.persons[key(req.IDType, req.IDNumber)]
+	s.mu.RUnlock()
+
+	if !ok && !s.allowUnknown {
+		writeError(ctx, cerror.KindNotExist, "person not found")
+		return
+	}
+
+	if !ok {
+		p = extdocregistry.Person{IDType: req.IDType, IDNumber: req.IDNumber, Status: extdocregistry.StatusActive}
+	}
+
+	b, _ := json.Marshal(extdocregistry.SearchResp{Data: p})
+
+	ctx.SetContentType(fiber.MIMEApplicationJSON)
+	ctx.SetStatusCode(fiber.StatusOK)
+	ctx.SetBody(b)
+}
+
+func writeError(ctx *fasthttp.RequestCtx, kind cerror.Kind, msg string) {
+	b, _ := json.Marshal(cerror.BuildErrorResponse(cerror.NewF(context.Background(), kind, msg)))
+
+	ctx.SetContentType(fiber.MIMEApplicationJSON)
+	ctx.SetStatusCode(kind.HTTPCode())
+	ctx.SetBody(b)
+}
+
+func key(idType, idNumber string) string {
+	return idType + "|" + idNumber
+}
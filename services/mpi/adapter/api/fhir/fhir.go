@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/valyala/fasthttp"
 
@@ -63,10 +65,48 @@ func (c *Client) SearchTaskByParams(ctx context.Context, params *entity.SearchTa
 		}...)
 	}
 
+	if params.PatientID != "" {
+		qParams = append(qParams, &client.QParam{
+			Key:   "patient",
+			Value: fmt.Sprintf("%s/%s", fhirModel.ResourcePatient, params.PatientID),
+		})
+	}
+
+	if params.AuthoredBefore != nil {
+		qParams = append(qParams, &client.QParam{
+			Key:   "authored-on",
+			Value: "lt" + params.AuthoredBefore.UTC().Format(time.RFC3339),
+		})
+	}
+
+	if params.StatusReason != "" {
+		qParams = append(qParams, &client.QParam{Key: "status-reason", Value: params.StatusReason})
+	}
+
 	if len(qParams) == 0 {
 		return nil, cerror.NewF(ctx, cerror.KindInternal, "no search criteria set").LogError()
 	}
 
+	if params.Status != "" {
+		qParams = append(qParams, &client.QParam{Key: "status", Value: params.Status})
+	}
+
+	if len(params.Profiles) > 0 {
+		qParams = append(qParams, &client.QParam{Key: "_profile", Value: strings.Join(params.Profiles, ",")})
+	}
+
+	if params.Sort != "" {
+		qParams = append(qParams, &client.QParam{Key: "_sort", Value: params.Sort})
+	}
+
+	if params.Count > 0 {
+		qParams = append(qParams, &client.QParam{Key: "_count", Value: strconv.Itoa(params.Count)})
+	}
+
+	if params.Offset > 0 {
+		qParams = append(qParams, &client.QParam{Key: "_offset", Value: strconv.Itoa(params.Offset)})
+	}
+
 	qParams = append(qParams, &client.QParam{Key: "_revinclude", Value: "Task:input-reference"})
 
 	bundle, err := c.fhir.SearchResourceByParams(ctx, fhirModel.ResourceTask, qParams)
@@ -119,17 +159,31 @@ func (c *Client) GetParametersByID(ctx context.Context, id fhirModel.ID) (*fhirM
 func (c *Client) SearchPatientByParams(ctx context.Context, params *entity.SearchPatientParams) ([]*fhirModel.Patient, error) {
 	qParams := []*client.QParam{}
 	if params.Phone != nil {
-		qParams = append(qParams, []*client.QParam{
-			{Key: "phone", Value: params.Phone.Phone},
-			{Key: "birthdate", Value: params.Phone.BirthDate},
-		}...)
+		qParams = append(qParams, &client.QParam{Key: "phone", Value: params.Phone.Phone})
+
+		if params.Phone.BirthDate != "" {
+			qParams = append(qParams, &client.QParam{Key: "birthdate", Value: params.Phone.BirthDate})
+		}
+	}
+
+	if params.BirthDate != "" {
+		qParams = append(qParams, &client.QParam{Key: "birthdate", Value: params.BirthDate})
+	}
+
+	if params.Family != "" {
+		qParams = append(qParams, &client.QParam{Key: "family", Value: params.Family})
+	}
+
+	if params.Given != "" {
+		qParams = append(qParams, &client.QParam{Key: "given", Value: params.Given})
 	}
 
 	if params.Identifier != nil {
-		qParams = append(qParams, []*client.QParam{
-			{Key: "identifier", Value: params.Identifier.Value},
-			{Key: "identifier-type", Value: params.Identifier.Type},
-		}...)
+		qParams = append(qParams, &client.QParam{Key: "identifier", Value: params.Identifier.Value})
+
+		if params.Identifier.Type != "" {
+			qParams = append(qParams, &client.QParam{Key: "identifier-type", Value: params.Identifier.Type})
+		}
 	}
 
 	if len(qParams) == 0 {
@@ -141,7 +195,15 @@ func (c *Client) SearchPatientByParams(ctx context.Context, params *entity.Searc
 		{Key: "_profile", Value: fhirModel.StructureDefinitionPatientIdentified},
 	}...)
 
-	bundle, err := c.fhir.SearchResourceByParams(ctx, fhirModel.ResourceTask, qParams)
+	if params.Count > 0 {
+		qParams = append(qParams, &client.QParam{Key: "_count", Value: strconv.Itoa(params.Count)})
+	}
+
+	if params.Offset > 0 {
+		qParams = append(qParams, &client.QParam{Key: "_offset", Value: strconv.Itoa(params.Offset)})
+	}
+
+	bundle, err := c.fhir.SearchResourceByParams(ctx, fhirModel.ResourcePatient, qParams)
 	if err != nil {
 		return nil, err
 	}
@@ -3,6 +3,7 @@ package fhir_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 	"wasfaty.api/pkg/env"
@@ -247,6 +248,70 @@ func (s *fhirTestSuite) TestSearchTaskByParams() {
 	s.Equal(1, len(r))
 	s.Equal(expectedTask, r[0])
 
+	s.mockClient.searchResourceByParamsFunc = func(_ context.Context, resName string, params []*client.QParam) (
+		*fhirModel.Bundle, error) {
+		s.Equal([]*client.QParam{
+			{Key: "patient", Value: "Patient/123"},
+			{Key: "status", Value: "in-progress"},
+			{Key: "_profile", Value: "profile1,profile2"},
+			{Key: "_revinclude", Value: "Task:input-reference"},
+		}, params)
+
+		return new(fhirModel.Bundle), nil
+	}
+
+	r, err = s.fhir.SearchTaskByParams(context.Background(), &entity.SearchTaskParams{
+		PatientID: fhirModel.ID("123"),
+		Status:    "in-progress",
+		Profiles:  []string{"profile1", "profile2"},
+	})
+	s.NoError(err)
+	s.Empty(r)
+
+	authoredBefore := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	s.mockClient.searchResourceByParamsFunc = func(_ context.Context, resName string, params []*client.QParam) (
+		*fhirModel.Bundle, error) {
+		s.Equal([]*client.QParam{
+			{Key: "authored-on", Value: "lt2022-01-02T03:04:05Z"},
+			{Key: "status", Value: "in-progress"},
+			{Key: "_sort", Value: "authored-on"},
+			{Key: "_count", Value: "50"},
+			{Key: "_offset", Value: "100"},
+			{Key: "_revinclude", Value: "Task:input-reference"},
+		}, params)
+
+		return new(fhirModel.Bundle), nil
+	}
+
+	r, err = s.fhir.SearchTaskByParams(context.Background(), &entity.SearchTaskParams{
+		AuthoredBefore: &authoredBefore,
+		Status:         "in-progress",
+		Sort:           "authored-on",
+		Count:          50,
+		Offset:         100,
+	})
+	s.NoError(err)
+	s.Empty(r)
+
+	s.mockClient.searchResourceByParamsFunc = func(_ context.Context, resName string, params []*client.QParam) (
+		*fhirModel.Bundle, error) {
+		s.Equal([]*client.QParam{
+			{Key: "status-reason", Value: "system|"},
+			{Key: "status", Value: "on-hold"},
+			{Key: "_revinclude", Value: "Task:input-reference"},
+		}, params)
+
+		return new(fhirModel.Bundle), nil
+	}
+
+	r, err = s.fhir.SearchTaskByParams(context.Background(), &entity.SearchTaskParams{
+		StatusReason: "system|",
+		Status:       "on-hold",
+	})
+	s.NoError(err)
+	s.Empty(r)
+
 	r, err = s.fhir.SearchTaskByParams(context.Background(), &entity.SearchTaskParams{})
 	s.Error(err)
 	s.Equal("no search criteria set", err.Error())
@@ -276,7 +341,7 @@ func (s *fhirTestSuite) TestSearchPatientByParams() {
 		*fhirModel.Bundle, error) {
 		isCalled = true
 
-		s.Equal("Task", resName)
+		s.Equal("Patient", resName)
 		s.Equal([]*client.QParam{
 			{Key: "phone", Value: searchParams.Phone.Phone},
 			{Key: "birthdate", Value: searchParams.Phone.BirthDate},
@@ -304,6 +369,67 @@ func (s *fhirTestSuite) TestSearchPatientByParams() {
 	s.Equal(1, len(r))
 	s.Equal(expectedPatient, r[0])
 
+	s.mockClient.searchResourceByParamsFunc = func(_ context.Context, resName string, params []*client.QParam) (
+		*fhirModel.Bundle, error) {
+		s.Equal([]*client.QParam{
+			{Key: "identifier", Value: "system|value"},
+			{Key: "active", Value: "true"},
+			{Key: "_profile", Value: fhirModel.StructureDefinitionPatientIdentified},
+			{Key: "_count", Value: "11"},
+			{Key: "_offset", Value: "20"},
+		}, params)
+
+		return new(fhirModel.Bundle), nil
+	}
+
+	r, err = s.fhir.SearchPatientByParams(context.Background(), &entity.SearchPatientParams{
+		Identifier: &entity.SearchPatientByIdentifierParams{Value: "system|value"},
+		Count:      11,
+		Offset:     20,
+	})
+	s.NoError(err)
+	s.Empty(r)
+
+	s.mockClient.searchResourceByParamsFunc = func(_ context.Context, resName string, params []*client.QParam) (
+		*fhirModel.Bundle, error) {
+		s.Equal([]*client.QParam{
+			{Key: "phone", Value: "+966500000001"},
+			{Key: "birthdate", Value: "1990-05-12"},
+			{Key: "active", Value: "true"},
+			{Key: "_profile", Value: fhirModel.StructureDefinitionPatientIdentified},
+		}, params)
+
+		return new(fhirModel.Bundle), nil
+	}
+
+	// the phone without the birth date and the birth date alone are used to find the $match candidates
+	r, err = s.fhir.SearchPatientByParams(context.Background(), &entity.SearchPatientParams{
+		Phone:     &entity.SearchPatientByPhoneParams{Phone: "+966500000001"},
+		BirthDate: "1990-05-12",
+	})
+	s.NoError(err)
+	s.Empty(r)
+
+	s.mockClient.searchResourceByParamsFunc = func(_ context.Context, resName string, params []*client.QParam) (
+		*fhirModel.Bundle, error) {
+		s.Equal([]*client.QParam{
+			{Key: "birthdate", Value: "1990"},
+			{Key: "family", Value: "Mohammed"},
+			{Key: "given", Value: "Ahmad"},
+			{Key: "active", Value: "true"},
+			{Key: "_profile", Value: fhirModel.StructureDefinitionPatientIdentified},
+		}, params)
+
+		return new(fhirModel.Bundle), nil
+	}
+
+	// the name with the birth year finds the $match candidates with the birth date typos
+	r, err = s.fhir.SearchPatientByParams(context.Background(), &entity.SearchPatientParams{
+		Family: "Mohammed", Given: "Ahmad", BirthDate: "1990",
+	})
+	s.NoError(err)
+	s.Empty(r)
+
 	r, err = s.fhir.SearchPatientByParams(context.Background(), &entity.SearchPatientParams{})
 	s.Error(err)
 	s.Equal("no search criteria set", err.Error())
@@ -0,0 +1,163 @@
+package local
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"time"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/services/mpi/entity"
+)
+
+const (
+	StoreMemory   = "memory"
+	StorePostgres = "postgres"
+
+	minCodeLength = 4
+	maxCodeLength = 10
+)
+
+// Config is the built-in otp engine configuration, it is used when the otp service is not available
+type Config struct {
+	CodeLength  int           `env:"OTP_LOCAL_CODE_LENGTH" envDefault:"4"`
+	TTL         time.Duration `env:"OTP_LOCAL_TTL" envDefault:"5m"`
+	MaxAttempts int           `env:"OTP_LOCAL_MAX_ATTEMPTS" envDefault:"3"`
+	// Secret is the hmac key of the stored code hashes, so the short codes can not be recovered from the database
+	Secret      string `env:"OTP_LOCAL_SECRET"`
+	Store       string `env:"OTP_LOCAL_STORE" envDefault:"postgres"`
+	PostgresDSN string `env:"OTP_LOCAL_POSTGRES_DSN"`
+}
+
+// Record is the stored otp, the code itself is never stored
+type Record struct {
+	ProcessID   string
+	Value       string
+	CodeHash    []byte
+	ExpiresAt   time.Time
+	Attempts    int
+	MaxAttempts int
+}
+
+// Store keeps the otp records by the process id and the confirmed value
+type Store interface {
+	// Save replaces the previous otp of the process, so the resent code resets the attempts
+	Save(ctx context.Context, r *Record) error
+	Get(ctx context.Context, processID, value string) (*Record, bool, error)
+	// ConsumeAttempt increments the attempts count if it is less than the max one, false is returned otherwise
+	ConsumeAttempt(ctx context.Context, processID, value string) (int, bool, error)
+	Delete(ctx context.Context, processID, value string) error
+}
+
+// Client generates and validates the otp codes without the otp service, it implements the usecase otp client
+type Client struct {
+	cfg   *Config
+	store Store
+	now   func() time.Time
+}
+
+func NewClient(cfg *Config, store Store) *Client {
+	return &Client{cfg: cfg, store: store, now: time.Now}
+}
+
+// WithClock replaces the client clock, it is used to check the expiration in tests
+func (c *Client) WithClock(now func() time.Time) *Client {
+	c.now = now
+	return c
+}
+
+func (c *Client) GenerateByPhone(ctx context.Context, phone, processID string) (*entity.OTP, error) {
+	return c.generate(ctx, phone, processID)
+}
+
+func (c *Client) GenerateByEmail(ctx context.Context, email, processID string) (*entity.OTP, error) {
+	return c.generate(ctx, email, processID)
+}
+
+func (c *Client) generate(ctx context.Context, value, processID string) (*entity.OTP, error) {
+	code, err := c.newCode(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Record{
+		ProcessID:   processID,
+		Value:       value,
+		CodeHash:    c.hash(processID, value, code),
+		ExpiresAt:   c.now().UTC().Add(c.cfg.TTL),
+		MaxAttempts: c.cfg.MaxAttempts,
+	}
+
+	if err := c.store.Save(ctx, r); err != nil {
+		return nil, err
+	}
+
+	return &entity.OTP{
+		Code:             code,
+		ExpiresAt:        r.ExpiresAt,
+		MaxAttemptsCount: r.MaxAttempts,
+		Value:            value,
+	}, nil
+}
+
+func (c *Client) Validate(ctx context.Context, p *entity.ValidateOTPParams) error {
+	r, ok, err := c.store.Get(ctx, p.ProcessID, p.Value)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return cerror.NewF(ctx, cerror.KindNotExist, "otp not found").LogError()
+	}
+
+	if !c.now().Before(r.ExpiresAt) {
+		return cerror.NewF(ctx, cerror.KindBadValidation, "otp code is expired").LogError()
+	}
+
+	// the attempt is consumed before the comparison, so the concurrent guesses can not exceed the max attempts
+	attempts, ok, err := c.store.ConsumeAttempt(ctx, p.ProcessID, p.Value)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return cerror.NewF(ctx, cerror.KindBadValidation, "otp attempts are exhausted").
+			WithPayload(&entity.OTPAttempts{Count: r.MaxAttempts, MaxCount: r.MaxAttempts}).LogError()
+	}
+
+	if !hmac.Equal(r.CodeHash, c.hash(p.ProcessID, p.Value, p.Code)) {
+		return cerror.NewF(ctx, cerror.KindBadValidation, "otp code is not valid").
+			WithPayload(&entity.OTPAttempts{Count: attempts, MaxCount: r.MaxAttempts}).LogError()
+	}
+
+	// the code is valid once
+	return c.store.Delete(ctx, p.ProcessID, p.Value)
+}
+
+// newCode returns the random digits code of the configured length
+func (c *Client) newCode(ctx context.Context) (string, error) {
+	if c.cfg.CodeLength < minCodeLength || c.cfg.CodeLength > maxCodeLength {
+		return "", cerror.NewF(ctx, cerror.KindInternal,
+			"otp code length should be between %d and %d", minCodeLength, maxCodeLength).LogError()
+	}
+
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(c.cfg.CodeLength)), nil)
+
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	return fmt.Sprintf("%0*d", c.cfg.CodeLength, n), nil
+}
+
+// hash binds the code to the process and the value, so the same code of the other request does not match
+func (c *Client) hash(processID, value, code string) []byte {
+	m := hmac.New(sha256.New, []byte(c.cfg.Secret))
+	_, _ = fmt.Fprintf(m, "%s\x00%s\x00%s", processID, value, code)
+
+	return m.Sum(nil)
+}
@@ -0,0 +1,141 @@
+package local_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/pkg/log"
+	"wasfaty.api/services/mpi/adapter/api/otp/local"
+	"wasfaty.api/services/mpi/entity"
+)
+
+type localTestSuite struct {
+	suite.Suite
+	now    time.Time
+	store  *local.MemoryStore
+	client *local.Client
+}
+
+func TestLocalOTPTestSuite(t *testing.T) {
+	log.SetGlobalLogLevel("fatal")
+	suite.Run(t, new(localTestSuite))
+}
+
+func (s *localTestSuite) SetupTest() {
+	s.now = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.store = local.NewMemoryStore()
+	s.client = local.NewClient(&local.Config{
+		CodeLength:  6,
+		TTL:         5 * time.Minute,
+		MaxAttempts: 3,
+		Secret:      "secret",
+	}, s.store).WithClock(func() time.Time { return s.now })
+}
+
+func (s *localTestSuite) TestGenerate() {
+	ctx := context.Background()
+
+	otp, err := s.client.GenerateByPhone(ctx, "+966500000001", "task-1")
+	s.Require().NoError(err)
+	s.Len(otp.Code, 6)
+	s.Equal("+966500000001", otp.Value)
+	s.Equal(s.now.Add(5*time.Minute), otp.ExpiresAt)
+	s.Equal(3, otp.MaxAttemptsCount)
+
+	// the code is not stored as is
+	r, ok, err := s.store.Get(ctx, "task-1", "+966500000001")
+	s.Require().NoError(err)
+	s.Require().True(ok)
+	s.NotContains(string(r.CodeHash), otp.Code)
+
+	_, err = local.NewClient(&local.Config{CodeLength: 2}, s.store).GenerateByEmail(ctx, "a@b.c", "task-2")
+	s.Error(err)
+}
+
+func (s *localTestSuite) TestValidate() {
+	ctx := context.Background()
+
+	otp, err := s.client.GenerateByPhone(ctx, "+966500000001", "task-1")
+	s.Require().NoError(err)
+
+	err = s.client.Validate(ctx, &entity.ValidateOTPParams{Code: otp.Code, Value: "+966500000001", ProcessID: "task-2"})
+	s.Error(err)
+	s.Equal(cerror.KindNotExist, cerror.ErrKind(err))
+
+	err = s.client.Validate(ctx, &entity.ValidateOTPParams{Code: wrongCode(otp.Code), Value: "+966500000001",
+		ProcessID: "task-1"})
+	s.Error(err)
+	s.Equal(cerror.KindBadValidation, cerror.ErrKind(err))
+	s.Equal(&entity.OTPAttempts{Count: 1, MaxCount: 3}, attempts(err))
+
+	s.NoError(s.client.Validate(ctx, &entity.ValidateOTPParams{Code: otp.Code, Value: "+966500000001",
+		ProcessID: "task-1"}))
+
+	// the code is valid once
+	err = s.client.Validate(ctx, &entity.ValidateOTPParams{Code: otp.Code, Value: "+966500000001", ProcessID: "task-1"})
+	s.Equal(cerror.KindNotExist, cerror.ErrKind(err))
+}
+
+func (s *localTestSuite) TestValidateAttemptsExhausted() {
+	ctx := context.Background()
+
+	otp, err := s.client.GenerateByPhone(ctx, "+966500000001", "task-1")
+	s.Require().NoError(err)
+
+	p := &entity.ValidateOTPParams{Code: wrongCode(otp.Code), Value: "+966500000001", ProcessID: "task-1"}
+
+	for i := 1; i <= 3; i++ {
+		err = s.client.Validate(ctx, p)
+		s.Equal(&entity.OTPAttempts{Count: i, MaxCount: 3}, attempts(err))
+	}
+
+	// the valid code is rejected when the attempts are exhausted
+	p.Code = otp.Code
+	err = s.client.Validate(ctx, p)
+	s.Error(err)
+	s.Equal(0, attempts(err).Remaining())
+
+	// the resent code resets the attempts
+	otp, err = s.client.GenerateByPhone(ctx, "+966500000001", "task-1")
+	s.Require().NoError(err)
+
+	p.Code = otp.Code
+	s.NoError(s.client.Validate(ctx, p))
+}
+
+func (s *localTestSuite) TestValidateExpired() {
+	ctx := context.Background()
+
+	otp, err := s.client.GenerateByEmail(ctx, "a@b.c", "task-1")
+	s.Require().NoError(err)
+
+	s.now = s.now.Add(5 * time.Minute)
+
+	err = s.client.Validate(ctx, &entity.ValidateOTPParams{Code: otp.Code, Value: "a@b.c", ProcessID: "task-1"})
+	s.Error(err)
+	s.Equal(cerror.KindBadValidation, cerror.ErrKind(err))
+	s.Contains(err.Error(), "expired")
+}
+
+func wrongCode(code string) string {
+	if code[0] == '0' {
+		return "1" + code[1:]
+	}
+
+	return "0" + code[1:]
+}
+
+func attempts(err error) *entity.OTPAttempts {
+	var cErr *cerror.CError
+	if !errors.As(err, &cErr) {
+		return nil
+	}
+
+	a, _ := cErr.Payload().(*entity.OTPAttempts)
+
+	return a
+}
@@ -0,0 +1,67 @@
+package local
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore keeps the otp records in memory, it is used in tests and single instance local runs
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]*Record)}
+}
+
+func (s *MemoryStore) Save(_ context.Context, r *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp := *r
+	s.records[recordKey(r.ProcessID, r.Value)] = &cp
+
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, processID, value string) (*Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.records[recordKey(processID, value)]
+	if !ok {
+		return nil, false, nil
+	}
+
+	cp := *r
+
+	return &cp, true, nil
+}
+
+func (s *MemoryStore) ConsumeAttempt(_ context.Context, processID, value string) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.records[recordKey(processID, value)]
+	if !ok || r.Attempts >= r.MaxAttempts {
+		return 0, false, nil
+	}
+
+	r.Attempts++
+
+	return r.Attempts, true, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, processID, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, recordKey(processID, value))
+
+	return nil
+}
+
+func recordKey(processID, value string) string {
+	return processID + "\x00" + value
+}
@@ -0,0 +1,105 @@
+package local
+
+import (
+	"context"
+	"database/sql"
+
+	"wasfaty.api/pkg/cerror"
+)
+
+const (
+	postgresDriver = "pgx"
+
+	createTableQuery = `CREATE TABLE IF NOT EXISTS otp (
+	process_id   TEXT NOT NULL,
+	value        TEXT NOT NULL,
+	code_hash    BYTEA NOT NULL,
+	expires_at   TIMESTAMPTZ NOT NULL,
+	attempts     INTEGER NOT NULL DEFAULT 0,
+	max_attempts INTEGER NOT NULL,
+	PRIMARY KEY (process_id, value)
+)`
+
+	saveQuery = `INSERT INTO otp (process_id, value, code_hash, expires_at, attempts, max_attempts)
+VALUES ($1, $2, $3, $4, 0, $5)
+ON CONFLICT (process_id, value) DO UPDATE SET code_hash = EXCLUDED.code_hash, expires_at = EXCLUDED.expires_at,
+	attempts = 0, max_attempts = EXCLUDED.max_attempts`
+
+	getQuery = `SELECT code_hash, expires_at, attempts, max_attempts FROM otp WHERE process_id = $1 AND value = $2`
+
+	consumeAttemptQuery = `UPDATE otp SET attempts = attempts + 1
+WHERE process_id = $1 AND value = $2 AND attempts < max_attempts RETURNING attempts`
+
+	deleteQuery = `DELETE FROM otp WHERE process_id = $1 AND value = $2`
+)
+
+// PostgresStore keeps the otp records in postgres, so they are shared between the service instances
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore connects to the database and creates the otp table if it does not exist
+func NewPostgresStore(ctx context.Context, dsn string) (*PostgresStore, error) {
+	db, err := sql.Open(postgresDriver, dsn)
+	if err != nil {
+		return nil, cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	if _, err := db.ExecContext(ctx, createTableQuery); err != nil {
+		_ = db.Close()
+		return nil, cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	return &PostgresStore{db: db}, nil
+}
+
+func (s *PostgresStore) Save(ctx context.Context, r *Record) error {
+	if _, err := s.db.ExecContext(ctx, saveQuery, r.ProcessID, r.Value, r.CodeHash, r.ExpiresAt, r.MaxAttempts); err != nil {
+		return cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) Get(ctx context.Context, processID, value string) (*Record, bool, error) {
+	r := &Record{ProcessID: processID, Value: value}
+
+	err := s.db.QueryRowContext(ctx, getQuery, processID, value).
+		Scan(&r.CodeHash, &r.ExpiresAt, &r.Attempts, &r.MaxAttempts)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	return r, true, nil
+}
+
+func (s *PostgresStore) ConsumeAttempt(ctx context.Context, processID, value string) (int, bool, error) {
+	var attempts int
+
+	err := s.db.QueryRowContext(ctx, consumeAttemptQuery, processID, value).Scan(&attempts)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+
+	if err != nil {
+		return 0, false, cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	return attempts, true, nil
+}
+
+func (s *PostgresStore) Delete(ctx context.Context, processID, value string) error {
+	if _, err := s.db.ExecContext(ctx, deleteQuery, processID, value); err != nil {
+		return cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
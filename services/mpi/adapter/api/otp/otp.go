@@ -16,7 +16,11 @@ import (
 )
 
 const (
+	EngineRemote = "remote"
+	EngineLocal  = "local"
+
 	typePhone = "PHONE"
+	typeEmail = "EMAIL"
 )
 
 type generateReq struct {
@@ -29,6 +33,12 @@ type generateResp struct {
 	Data entity.OTP `json:"data"`
 }
 
+// errorResp is the otp service error, the data is passed with the attempts state on a wrong code
+type errorResp struct {
+	cerror.ResponseErrorWrap
+	Data *entity.OTP `json:"data"`
+}
+
 type HTTPClient interface {
 	Do(req *fasthttp.Request, resp *fasthttp.Response) error
 	DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error
@@ -49,9 +59,17 @@ func (c *Client) WithHTTPClient(h HTTPClient) *Client {
 }
 
 func (c *Client) GenerateByPhone(ctx context.Context, phone, processID string) (*entity.OTP, error) {
+	return c.generate(ctx, typePhone, phone, processID)
+}
+
+func (c *Client) GenerateByEmail(ctx context.Context, email, processID string) (*entity.OTP, error) {
+	return c.generate(ctx, typeEmail, email, processID)
+}
+
+func (c *Client) generate(ctx context.Context, otpType, value, processID string) (*entity.OTP, error) {
 	b, err := json.Marshal(generateReq{
-		Type:      typePhone,
-		Value:     phone,
+		Type:      otpType,
+		Value:     value,
 		ProcessID: processID,
 	})
 
@@ -65,6 +83,10 @@ func (c *Client) GenerateByPhone(ctx context.Context, phone, processID string) (
 		return nil, err
 	}
 
+	if dst.Data.Value == "" {
+		dst.Data.Value = value
+	}
+
 	return &dst.Data, nil
 }
 
@@ -128,7 +150,7 @@ func (c *Client) sendRequest(
 	respBody := resp.Body()
 
 	if !(respStatus >= 200 && respStatus < 300) {
-		var payload cerror.ResponseErrorWrap
+		var payload errorResp
 
 		var errMsg string
 		if err := json.Unmarshal(respBody, &payload); err == nil && payload.Error.Message != "" {
@@ -137,7 +159,14 @@ func (c *Client) sendRequest(
 			errMsg = fmt.Sprintf("otp service error. Code: %d", respStatus)
 		}
 
-		cErr := cerror.NewF(ctx, cerror.KindFromHTTPCode(respStatus), errMsg).WithPayload(payload).LogError()
+		cErr := cerror.NewF(ctx, cerror.KindFromHTTPCode(respStatus), errMsg).WithPayload(payload.ResponseErrorWrap).LogError()
+
+		if payload.Data != nil && payload.Data.MaxAttemptsCount > 0 {
+			return cErr.WithPayload(&entity.OTPAttempts{
+				Count:    payload.Data.AttemptsCount,
+				MaxCount: payload.Data.MaxAttemptsCount,
+			})
+		}
 
 		return cErr.WithPayload(nil)
 	}
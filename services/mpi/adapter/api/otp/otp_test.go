@@ -97,6 +97,47 @@ func (s *otpTestSuite) TestGenerateByPhone() {
 	})
 }
 
+func (s *otpTestSuite) TestGenerateByEmail() {
+	ctx := reqContext()
+	email := "patient@example.com"
+	procID := "2"
+	expectedOTP := &entity.OTP{
+		Code:      "1234",
+		ExpiresAt: time.Date(2000, 1, 1, 1, 1, 1, 1, time.UTC),
+		Value:     email,
+	}
+	s.httpClient.DoFunc = func(req *fasthttp.Request, resp *fasthttp.Response) error {
+		s.assertReq(ctx, req, "generate", http.MethodPost)
+
+		reqBody := make(map[string]string)
+		err := json.Unmarshal(req.Body(), &reqBody)
+
+		s.NoError(err)
+		s.Equal(map[string]string{
+			"type":      "EMAIL",
+			"value":     email,
+			"processID": procID,
+		}, reqBody)
+
+		resp.SetStatusCode(http.StatusOK)
+
+		b, _ := json.Marshal(map[string]interface{}{"data": expectedOTP})
+		_, _ = resp.BodyWriter().Write(b)
+
+		return nil
+	}
+
+	r, err := s.otpClient.GenerateByEmail(ctx, email, procID)
+
+	s.NoError(err)
+	s.Equal(expectedOTP, r)
+
+	s.asserErrResp(func() error {
+		_, err := s.otpClient.GenerateByEmail(ctx, email, procID)
+		return err
+	})
+}
+
 func (s *otpTestSuite) TestValidate() {
 	ctx := reqContext()
 	phone := "1"
@@ -127,6 +168,18 @@ func (s *otpTestSuite) TestValidate() {
 	s.asserErrResp(func() error {
 		return s.otpClient.Validate(ctx, p)
 	})
+
+	s.httpClient.DoFunc = func(req *fasthttp.Request, resp *fasthttp.Response) error {
+		resp.SetStatusCode(http.StatusBadRequest)
+		_, _ = resp.BodyWriter().Write(
+			[]byte(`{"error":{"message":"wrong code"},"data":{"attemptsCount":2,"maxAttemptsCount":3}}`))
+
+		return nil
+	}
+
+	err = s.otpClient.Validate(ctx, p)
+	s.Error(err)
+	s.Equal(&entity.OTPAttempts{Count: 2, MaxCount: 3}, err.(*cerror.CError).Payload())
 }
 
 func (s *otpTestSuite) asserErrResp(callFn func() error) {
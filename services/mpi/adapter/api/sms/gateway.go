@@ -0,0 +1,137 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/pkg/http/headers"
+	"wasfaty.api/services/mpi/entity"
+)
+
+const HeaderAPIKey = "X-API-Key"
+
+type SendReq struct {
+	From     string `json:"from,omitempty"`
+	To       string `json:"to"`
+	Text     string `json:"text"`
+	Language string `json:"language,omitempty"`
+}
+
+type Message struct {
+	MessageID string `json:"messageId"`
+	Status    string `json:"status"`
+}
+
+type SendResp struct {
+	Data Message `json:"data"`
+}
+
+type HTTPClient interface {
+	Do(req *fasthttp.Request, resp *fasthttp.Response) error
+	DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error
+}
+
+// GatewayClient sends the sms through the http sms gateway
+type GatewayClient struct {
+	cfg        *Config
+	httpClient HTTPClient
+}
+
+func NewGatewayClient(cfg *Config) *GatewayClient {
+	return &GatewayClient{cfg: cfg, httpClient: new(fasthttp.Client)}
+}
+
+func (c *GatewayClient) WithHTTPClient(h HTTPClient) *GatewayClient {
+	c.httpClient = h
+	return c
+}
+
+func (c *GatewayClient) Send(ctx context.Context, m *entity.SMSMessage) (*entity.SMSDelivery, error) {
+	b, err := json.Marshal(SendReq{From: c.cfg.SenderID, To: m.To, Text: m.Text, Language: m.Language})
+	if err != nil {
+		return nil, cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	dst := new(SendResp)
+
+	if err := c.sendRequest(ctx, c.cfg.GatewayHost+"/messages", fiber.MethodPost, b, dst); err != nil {
+		return nil, err
+	}
+
+	status := dst.Data.Status
+	if status == "" {
+		status = entity.SMSStatusSent
+	}
+
+	return &entity.SMSDelivery{
+		MessageID:        dst.Data.MessageID,
+		SubmissionStatus: status,
+		SentAt:           time.Now().UTC(),
+	}, nil
+}
+
+func (c *GatewayClient) sendRequest(ctx context.Context, url, method string, body []byte, dst interface{}) error {
+	var err error
+
+	req := fasthttp.AcquireRequest()
+
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetContentType(fiber.MIMEApplicationJSON)
+	req.Header.SetMethod(method)
+
+	headers.AddHeadersFromContext(ctx, req)
+
+	if c.cfg.GatewayAPIKey != "" {
+		req.Header.Set(HeaderAPIKey, c.cfg.GatewayAPIKey)
+	}
+
+	req.SetRequestURI(url)
+	req.SetBody(body)
+
+	resp := fasthttp.AcquireResponse()
+
+	defer fasthttp.ReleaseResponse(resp)
+
+	if c.cfg.GatewayRequestTimeout != 0 {
+		err = c.httpClient.DoTimeout(req, resp, c.cfg.GatewayRequestTimeout)
+	} else {
+		err = c.httpClient.Do(req, resp)
+	}
+
+	if err != nil {
+		return cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	respStatus := resp.StatusCode()
+	respBody := resp.Body()
+
+	if !(respStatus >= 200 && respStatus < 300) {
+		var payload cerror.ResponseErrorWrap
+
+		var errMsg string
+		if err := json.Unmarshal(respBody, &payload); err == nil && payload.Error.Message != "" {
+			errMsg = fmt.Sprintf("sms gateway error: %s", payload.Error.Message)
+		} else {
+			errMsg = fmt.Sprintf("sms gateway error. Code: %d", respStatus)
+		}
+
+		// the gateway error is not a client error of the request that sends the otp
+		cErr := cerror.NewF(ctx, cerror.KindInternal, errMsg).WithPayload(payload).LogError()
+
+		return cErr.WithPayload(nil)
+	}
+
+	if dst != nil {
+		if err := json.Unmarshal(respBody, dst); err != nil {
+			return cerror.New(ctx, cerror.KindInternal, err).LogError()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/services/mpi/entity"
+)
+
+// MemorySender keeps the sent messages in memory, it is used in tests
+type MemorySender struct {
+	mu       sync.Mutex
+	messages []*entity.SMSMessage
+}
+
+func NewMemorySender() *MemorySender {
+	return new(MemorySender)
+}
+
+func (s *MemorySender) Send(_ context.Context, m *entity.SMSMessage) (*entity.SMSDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = append(s.messages, m)
+
+	return &entity.SMSDelivery{
+		MessageID:        uuid.NewV4().String(),
+		SubmissionStatus: entity.SMSStatusSent,
+		SentAt:           time.Now().UTC(),
+	}, nil
+}
+
+// Messages returns the sent messages
+func (s *MemorySender) Messages() []*entity.SMSMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*entity.SMSMessage(nil), s.messages...)
+}
+
+// FileSender appends the messages to the json lines file, it is used for the local runs
+// instead of the real delivery, so the file must not be used in the shared environments
+type FileSender struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileSender(path string) *FileSender {
+	return &FileSender{path: path}
+}
+
+type fileMessage struct {
+	MessageID string    `json:"messageId"`
+	To        string    `json:"to"`
+	Text      string    `json:"text"`
+	Language  string    `json:"language"`
+	SentAt    time.Time `json:"sentAt"`
+}
+
+func (s *FileSender) Send(ctx context.Context, m *entity.SMSMessage) (*entity.SMSDelivery, error) {
+	d := &entity.SMSDelivery{
+		MessageID:        uuid.NewV4().String(),
+		SubmissionStatus: entity.SMSStatusSent,
+		SentAt:           time.Now().UTC(),
+	}
+
+	b, err := json.Marshal(fileMessage{
+		MessageID: d.MessageID,
+		To:        m.To,
+		Text:      m.Text,
+		Language:  m.Language,
+		SentAt:    d.SentAt,
+	})
+	if err != nil {
+		return nil, cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintf(f, "%s\n", b); err != nil {
+		return nil, cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	return d, nil
+}
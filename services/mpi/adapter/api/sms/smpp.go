@@ -0,0 +1,289 @@
+package sms
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/services/mpi/entity"
+)
+
+// the smpp 3.4 commands used to submit a message
+const (
+	CommandGenericNack         uint32 = 0x80000000
+	CommandBindTransmitter     uint32 = 0x00000002
+	CommandBindTransmitterResp uint32 = 0x80000002
+	CommandSubmitSM            uint32 = 0x00000004
+	CommandSubmitSMResp        uint32 = 0x80000004
+	CommandUnbind              uint32 = 0x00000006
+	CommandUnbindResp          uint32 = 0x80000006
+
+	interfaceVersion = 0x34
+
+	tonInternational = 0x01
+	tonAlphanumeric  = 0x05
+	npiISDN          = 0x01
+
+	DataCodingDefault = 0x00
+	DataCodingUCS2    = 0x08
+
+	// the session is unbound right after the submit, so no delivery receipt can be received
+	registeredDeliveryNone = 0x00
+
+	tagMessagePayload = 0x0424
+	maxShortMessage   = 254
+	pduHeaderLength   = 16
+	maxPDULength      = 64 * 1024
+)
+
+// PDU is the smpp protocol data unit
+type PDU struct {
+	CommandID uint32
+	Status    uint32
+	Sequence  uint32
+	Body      []byte
+}
+
+// SMPPClient submits the sms to the smsc, a transmitter session is bound for every message.
+// The returned status is the submission one, the final delivery state is not tracked
+type SMPPClient struct {
+	cfg  *Config
+	dial func(ctx context.Context, addr string) (net.Conn, error)
+}
+
+func NewSMPPClient(cfg *Config) *SMPPClient {
+	d := new(net.Dialer)
+
+	return &SMPPClient{cfg: cfg, dial: func(ctx context.Context, addr string) (net.Conn, error) {
+		return d.DialContext(ctx, "tcp", addr)
+	}}
+}
+
+// WithDialer replaces the smsc connection dialer
+func (c *SMPPClient) WithDialer(dial func(ctx context.Context, addr string) (net.Conn, error)) *SMPPClient {
+	c.dial = dial
+	return c
+}
+
+func (c *SMPPClient) Send(ctx context.Context, m *entity.SMSMessage) (*entity.SMSDelivery, error) {
+	conn, err := c.dial(ctx, c.cfg.SMPPAddr)
+	if err != nil {
+		return nil, cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+	defer conn.Close()
+
+	if c.cfg.SMPPTimeout != 0 {
+		if err := conn.SetDeadline(time.Now().Add(c.cfg.SMPPTimeout)); err != nil {
+			return nil, cerror.New(ctx, cerror.KindInternal, err).LogError()
+		}
+	}
+
+	s := &smppSession{conn: conn, r: bufio.NewReader(conn)}
+
+	if _, err := s.call(ctx, CommandBindTransmitter, bindTransmitterBody(c.cfg)); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.call(ctx, CommandSubmitSM, submitSMBody(c.cfg.SenderID, m))
+	if err != nil {
+		return nil, err
+	}
+
+	// the message is already accepted, so the unbind error does not fail the delivery
+	_, _ = s.call(ctx, CommandUnbind, nil)
+
+	return &entity.SMSDelivery{
+		MessageID:        readCString(resp.Body),
+		SubmissionStatus: entity.SMSStatusSent,
+		SentAt:           time.Now().UTC(),
+	}, nil
+}
+
+type smppSession struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	sequence uint32
+}
+
+// call writes the request pdu and waits for its response
+func (s *smppSession) call(ctx context.Context, commandID uint32, body []byte) (*PDU, error) {
+	s.sequence++
+
+	if err := WritePDU(s.conn, &PDU{CommandID: commandID, Sequence: s.sequence, Body: body}); err != nil {
+		return nil, cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	resp, err := ReadPDU(s.r)
+	if err != nil {
+		return nil, cerror.New(ctx, cerror.KindInternal, err).LogError()
+	}
+
+	if resp.CommandID != commandID|CommandGenericNack || resp.Sequence != s.sequence {
+		return nil, cerror.NewF(ctx, cerror.KindInternal,
+			"smsc unexpected response 0x%08x to command 0x%08x", resp.CommandID, commandID).LogError()
+	}
+
+	if resp.Status != 0 {
+		return nil, cerror.NewF(ctx, cerror.KindInternal,
+			"smsc error status 0x%08x to command 0x%08x", resp.Status, commandID).LogError()
+	}
+
+	return resp, nil
+}
+
+func WritePDU(w io.Writer, p *PDU) error {
+	b := make([]byte, pduHeaderLength, pduHeaderLength+len(p.Body))
+	binary.BigEndian.PutUint32(b[0:], uint32(pduHeaderLength+len(p.Body)))
+	binary.BigEndian.PutUint32(b[4:], p.CommandID)
+	binary.BigEndian.PutUint32(b[8:], p.Status)
+	binary.BigEndian.PutUint32(b[12:], p.Sequence)
+
+	_, err := w.Write(append(b, p.Body...))
+
+	return err
+}
+
+func ReadPDU(r io.Reader) (*PDU, error) {
+	h := make([]byte, pduHeaderLength)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(h[0:])
+	if length < pduHeaderLength || length > maxPDULength {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	p := &PDU{
+		CommandID: binary.BigEndian.Uint32(h[4:]),
+		Status:    binary.BigEndian.Uint32(h[8:]),
+		Sequence:  binary.BigEndian.Uint32(h[12:]),
+		Body:      make([]byte, length-pduHeaderLength),
+	}
+
+	if _, err := io.ReadFull(r, p.Body); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func bindTransmitterBody(cfg *Config) []byte {
+	b := new(bytes.Buffer)
+	writeCString(b, cfg.SMPPSystemID)
+	writeCString(b, cfg.SMPPPassword)
+	writeCString(b, "") // system_type
+	b.WriteByte(interfaceVersion)
+	b.WriteByte(0)      // addr_ton
+	b.WriteByte(0)      // addr_npi
+	writeCString(b, "") // address_range
+
+	return b.Bytes()
+}
+
+func submitSMBody(senderID string, m *entity.SMSMessage) []byte {
+	dataCoding, text := EncodeText(m.Text)
+
+	b := new(bytes.Buffer)
+	writeCString(b, "") // service_type
+
+	if isNumeric(senderID) {
+		b.WriteByte(tonInternational)
+		b.WriteByte(npiISDN)
+	} else {
+		b.WriteByte(tonAlphanumeric)
+		b.WriteByte(0)
+	}
+
+	writeCString(b, senderID)
+	b.WriteByte(tonInternational)
+	b.WriteByte(npiISDN)
+	writeCString(b, strings.TrimPrefix(m.To, "+"))
+	b.WriteByte(0)      // esm_class
+	b.WriteByte(0)      // protocol_id
+	b.WriteByte(0)      // priority_flag
+	writeCString(b, "") // schedule_delivery_time
+	writeCString(b, "") // validity_period
+	b.WriteByte(registeredDeliveryNone)
+	b.WriteByte(0) // replace_if_present_flag
+	b.WriteByte(dataCoding)
+	b.WriteByte(0) // sm_default_msg_id
+
+	if len(text) <= maxShortMessage {
+		b.WriteByte(byte(len(text)))
+		b.Write(text)
+
+		return b.Bytes()
+	}
+
+	// the long text is sent in the message_payload tlv with the empty short_message
+	b.WriteByte(0)
+
+	tlv := make([]byte, 4)
+	binary.BigEndian.PutUint16(tlv[0:], tagMessagePayload)
+	binary.BigEndian.PutUint16(tlv[2:], uint16(len(text)))
+	b.Write(tlv)
+	b.Write(text)
+
+	return b.Bytes()
+}
+
+// EncodeText encodes the ascii text with the default smsc alphabet and the other text such as arabic with ucs2
+func EncodeText(text string) (byte, []byte) {
+	ascii := true
+
+	for _, r := range text {
+		if r > unicode.MaxASCII {
+			ascii = false
+			break
+		}
+	}
+
+	if ascii {
+		return DataCodingDefault, []byte(text)
+	}
+
+	units := utf16.Encode([]rune(text))
+	b := make([]byte, 2*len(units))
+
+	for i, u := range units {
+		binary.BigEndian.PutUint16(b[2*i:], u)
+	}
+
+	return DataCodingUCS2, b
+}
+
+func writeCString(b *bytes.Buffer, s string) {
+	b.WriteString(s)
+	b.WriteByte(0)
+}
+
+func readCString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		return string(b[:i])
+	}
+
+	return string(b)
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, r := range strings.TrimPrefix(s, "+") {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,29 @@
+package sms
+
+import (
+	"time"
+)
+
+const (
+	SenderGateway = "gateway"
+	SenderSMPP    = "smpp"
+	SenderFile    = "file"
+	SenderMemory  = "memory"
+)
+
+// Config is the sms delivery configuration, Sender selects one of the senders
+type Config struct {
+	Sender   string `env:"SMS_SENDER" envDefault:"gateway"`
+	SenderID string `env:"SMS_SENDER_ID"`
+
+	GatewayHost           string        `env:"SMS_GATEWAY_HOST"`
+	GatewayAPIKey         string        `env:"SMS_GATEWAY_API_KEY"`
+	GatewayRequestTimeout time.Duration `env:"SMS_GATEWAY_REQUEST_TIMEOUT" envDefault:"30s"`
+
+	SMPPAddr     string        `env:"SMS_SMPP_ADDR"`
+	SMPPSystemID string        `env:"SMS_SMPP_SYSTEM_ID"`
+	SMPPPassword string        `env:"SMS_SMPP_PASSWORD"`
+	SMPPTimeout  time.Duration `env:"SMS_SMPP_TIMEOUT" envDefault:"10s"`
+
+	FilePath string `env:"SMS_FILE_PATH" envDefault:"sms.jsonl"`
+}
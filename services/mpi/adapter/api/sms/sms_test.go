@@ -0,0 +1,210 @@
+package sms_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/valyala/fasthttp"
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/pkg/log"
+	"wasfaty.api/services/mpi/adapter/api/sms"
+	"wasfaty.api/services/mpi/entity"
+)
+
+type smsTestSuite struct {
+	suite.Suite
+	cfg        *sms.Config
+	httpClient mockClient
+}
+
+type mockClient struct {
+	DoFunc func(req *fasthttp.Request, resp *fasthttp.Response) error
+}
+
+func (m *mockClient) Do(req *fasthttp.Request, resp *fasthttp.Response) error {
+	return m.DoFunc(req, resp)
+}
+
+func (m *mockClient) DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	return m.DoFunc(req, resp)
+}
+
+func TestSMSAdapterTestSuite(t *testing.T) {
+	log.SetGlobalLogLevel("fatal")
+	suite.Run(t, new(smsTestSuite))
+}
+
+func (s *smsTestSuite) SetupTest() {
+	s.cfg = &sms.Config{
+		SenderID:      "Wasfaty",
+		GatewayHost:   "http://sms-gateway",
+		GatewayAPIKey: "key",
+		SMPPAddr:      "smsc:2775",
+		SMPPSystemID:  "mpi",
+		SMPPPassword:  "secret",
+		SMPPTimeout:   time.Second,
+	}
+}
+
+func (s *smsTestSuite) TestGatewaySend() {
+	ctx := context.Background()
+	msg := &entity.SMSMessage{To: "+966500000001", Text: "Your verification code is 1234.", Language: "en"}
+
+	s.httpClient.DoFunc = func(req *fasthttp.Request, resp *fasthttp.Response) error {
+		s.Equal("http://sms-gateway/messages", req.URI().String())
+		s.Equal(http.MethodPost, string(req.Header.Method()))
+		s.Equal("key", string(req.Header.Peek(sms.HeaderAPIKey)))
+
+		reqBody := new(sms.SendReq)
+		s.NoError(json.Unmarshal(req.Body(), reqBody))
+		s.Equal(&sms.SendReq{From: "Wasfaty", To: msg.To, Text: msg.Text, Language: msg.Language}, reqBody)
+
+		b, _ := json.Marshal(sms.SendResp{Data: sms.Message{MessageID: "m-1", Status: entity.SMSStatusQueued}})
+		resp.SetBody(b)
+		resp.SetStatusCode(http.StatusAccepted)
+
+		return nil
+	}
+
+	d, err := sms.NewGatewayClient(s.cfg).WithHTTPClient(&s.httpClient).Send(ctx, msg)
+	s.Require().NoError(err)
+	s.Equal("m-1", d.MessageID)
+	s.Equal(entity.SMSStatusQueued, d.SubmissionStatus)
+	s.False(d.SentAt.IsZero())
+
+	s.httpClient.DoFunc = func(req *fasthttp.Request, resp *fasthttp.Response) error {
+		resp.SetStatusCode(http.StatusBadRequest)
+		return nil
+	}
+
+	_, err = sms.NewGatewayClient(s.cfg).WithHTTPClient(&s.httpClient).Send(ctx, msg)
+	s.Require().Error(err)
+	s.Equal(cerror.KindInternal, cerror.ErrKind(err))
+	s.NotContains(err.Error(), msg.Text)
+}
+
+func (s *smsTestSuite) TestSMPPSend() {
+	ctx := context.Background()
+	msg := &entity.SMSMessage{To: "+966500000001", Text: "رمز التحقق 1234", Language: "ar"}
+
+	var submitted []byte
+
+	c := sms.NewSMPPClient(s.cfg).WithDialer(s.smsc(0, func(body []byte) { submitted = body }))
+
+	d, err := c.Send(ctx, msg)
+	s.Require().NoError(err)
+	s.Equal("smsc-1", d.MessageID)
+	s.Equal(entity.SMSStatusSent, d.SubmissionStatus)
+
+	_, text := sms.EncodeText(msg.Text)
+	s.True(bytes.Contains(submitted, []byte("966500000001\x00")))
+	s.True(bytes.Contains(submitted, []byte("Wasfaty\x00")))
+	// no delivery receipt is requested: registered_delivery, replace_if_present_flag, data_coding, sm_default_msg_id
+	s.True(bytes.HasSuffix(submitted, append([]byte{0, 0, sms.DataCodingUCS2, 0, byte(len(text))}, text...)))
+
+	// the smsc rejects the message
+	c = sms.NewSMPPClient(s.cfg).WithDialer(s.smsc(0x45, nil))
+
+	_, err = c.Send(ctx, msg)
+	s.Require().Error(err)
+	s.Equal(cerror.KindInternal, cerror.ErrKind(err))
+}
+
+func (s *smsTestSuite) TestEncodeText() {
+	coding, b := sms.EncodeText("code 1234")
+	s.Equal(byte(sms.DataCodingDefault), coding)
+	s.Equal([]byte("code 1234"), b)
+
+	coding, b = sms.EncodeText("رمز")
+	s.Equal(byte(sms.DataCodingUCS2), coding)
+	s.Equal([]byte{0x06, 0x31, 0x06, 0x45, 0x06, 0x32}, b)
+}
+
+func (s *smsTestSuite) TestMemorySender() {
+	sender := sms.NewMemorySender()
+	msg := &entity.SMSMessage{To: "+966500000001", Text: "code"}
+
+	d, err := sender.Send(context.Background(), msg)
+	s.Require().NoError(err)
+	s.NotEmpty(d.MessageID)
+	s.Equal([]*entity.SMSMessage{msg}, sender.Messages())
+}
+
+func (s *smsTestSuite) TestFileSender() {
+	path := filepath.Join(s.T().TempDir(), "sms.jsonl")
+	sender := sms.NewFileSender(path)
+
+	for _, to := range []string{"+966500000001", "+966500000002"} {
+		_, err := sender.Send(context.Background(), &entity.SMSMessage{To: to, Text: "code"})
+		s.Require().NoError(err)
+	}
+
+	f, err := os.Open(path)
+	s.Require().NoError(err)
+
+	defer f.Close()
+
+	var lines []map[string]interface{}
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		m := make(map[string]interface{})
+		s.Require().NoError(json.Unmarshal(sc.Bytes(), &m))
+		lines = append(lines, m)
+	}
+
+	s.Require().Len(lines, 2)
+	s.Equal("+966500000002", lines[1]["to"])
+}
+
+// smsc returns the dialer of the fake smsc answering the submit_sm with the passed status
+func (s *smsTestSuite) smsc(submitStatus uint32, onSubmit func(body []byte)) func(context.Context, string) (net.Conn, error) {
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		s.Equal("smsc:2775", addr)
+
+		client, server := net.Pipe()
+
+		go func() {
+			defer server.Close()
+
+			r := bufio.NewReader(server)
+
+			for {
+				p, err := sms.ReadPDU(r)
+				if err != nil {
+					return
+				}
+
+				resp := &sms.PDU{CommandID: p.CommandID | sms.CommandGenericNack, Sequence: p.Sequence}
+
+				switch p.CommandID {
+				case sms.CommandBindTransmitter:
+					s.True(bytes.HasPrefix(p.Body, []byte("mpi\x00secret\x00")))
+					resp.Body = []byte("smsc\x00")
+				case sms.CommandSubmitSM:
+					if onSubmit != nil {
+						onSubmit(p.Body)
+					}
+
+					resp.Status = submitStatus
+					resp.Body = []byte("smsc-1\x00")
+				}
+
+				if err := sms.WritePDU(server, resp); err != nil || p.CommandID == sms.CommandUnbind {
+					return
+				}
+			}
+		}()
+
+		return client, nil
+	}
+}
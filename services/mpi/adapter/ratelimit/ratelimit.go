@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"wasfaty.api/services/mpi/entity"
+)
+
+// Config is the otp rate limits configuration, the zero count disables the limit. The process limit counts
+// the first otp of the patient request together with its resends, so it allows 1+entity.OTPResendMaxCount codes
+type Config struct {
+	Enabled        bool          `env:"OTP_RATE_LIMIT_ENABLED" envDefault:"true"`
+	ValueCount     int           `env:"OTP_RATE_LIMIT_PHONE_COUNT" envDefault:"5"`
+	ValueWindow    time.Duration `env:"OTP_RATE_LIMIT_PHONE_WINDOW" envDefault:"1h"`
+	ProcessCount   int           `env:"OTP_RATE_LIMIT_PROCESS_COUNT" envDefault:"4"`
+	ProcessWindow  time.Duration `env:"OTP_RATE_LIMIT_PROCESS_WINDOW" envDefault:"15m"`
+	ConsumerCount  int           `env:"OTP_RATE_LIMIT_CONSUMER_COUNT" envDefault:"100"`
+	ConsumerWindow time.Duration `env:"OTP_RATE_LIMIT_CONSUMER_WINDOW" envDefault:"1m"`
+}
+
+func (c *Config) Limits() *entity.OTPRateLimits {
+	return &entity.OTPRateLimits{
+		Value:    entity.RateLimit{Count: c.ValueCount, Window: c.ValueWindow},
+		Process:  entity.RateLimit{Count: c.ProcessCount, Window: c.ProcessWindow},
+		Consumer: entity.RateLimit{Count: c.ConsumerCount, Window: c.ConsumerWindow},
+	}
+}
+
+// the keys without the events in their window are removed once per the interval
+const cleanupInterval = time.Minute
+
+type window struct {
+	events []time.Time
+	size   time.Duration
+}
+
+// prune drops the events which are out of the window
+func (w *window) prune(now time.Time) {
+	i := 0
+	for i < len(w.events) && !w.events[i].After(now.Add(-w.size)) {
+		i++
+	}
+
+	w.events = w.events[i:]
+}
+
+// MemoryLimiter is the in-memory sliding window log limiter, the limits are counted per service instance
+type MemoryLimiter struct {
+	now func() time.Time
+
+	mu          sync.Mutex
+	windows     map[string]*window
+	lastCleanup time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{now: time.Now, windows: make(map[string]*window)}
+}
+
+// WithClock replaces the limiter clock, it is used to move the windows in tests
+func (l *MemoryLimiter) WithClock(now func() time.Time) *MemoryLimiter {
+	l.now = now
+	return l
+}
+
+func (l *MemoryLimiter) Allow(_ context.Context, keys []*entity.RateLimitKey) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.cleanup(now)
+
+	var retryAfter time.Duration
+
+	for _, k := range keys {
+		w, ok := l.windows[k.Key]
+		if !ok {
+			continue
+		}
+
+		w.size = k.Limit.Window
+		w.prune(now)
+
+		if len(w.events) >= k.Limit.Count {
+			// the oldest event leaving the window frees the slot
+			if d := w.events[len(w.events)-k.Limit.Count].Add(w.size).Sub(now); d > retryAfter {
+				retryAfter = d
+			}
+		}
+	}
+
+	if retryAfter > 0 {
+		return retryAfter, nil
+	}
+
+	for _, k := range keys {
+		w, ok := l.windows[k.Key]
+		if !ok {
+			w = &window{size: k.Limit.Window}
+			l.windows[k.Key] = w
+		}
+
+		w.events = append(w.events, now)
+	}
+
+	return 0, nil
+}
+
+func (l *MemoryLimiter) cleanup(now time.Time) {
+	if now.Sub(l.lastCleanup) < cleanupInterval {
+		return
+	}
+
+	l.lastCleanup = now
+
+	for k, w := range l.windows {
+		w.prune(now)
+
+		if len(w.events) == 0 {
+			delete(l.windows, k)
+		}
+	}
+}
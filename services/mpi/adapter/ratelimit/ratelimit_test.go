@@ -0,0 +1,82 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"wasfaty.api/pkg/log"
+	"wasfaty.api/services/mpi/adapter/ratelimit"
+	"wasfaty.api/services/mpi/entity"
+)
+
+type rateLimitTestSuite struct {
+	suite.Suite
+	now     time.Time
+	limiter *ratelimit.MemoryLimiter
+}
+
+func TestRateLimitTestSuite(t *testing.T) {
+	log.SetGlobalLogLevel("fatal")
+	suite.Run(t, new(rateLimitTestSuite))
+}
+
+func (s *rateLimitTestSuite) SetupTest() {
+	s.now = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.limiter = ratelimit.NewMemoryLimiter().WithClock(func() time.Time { return s.now })
+}
+
+func (s *rateLimitTestSuite) TestSlidingWindow() {
+	ctx := context.Background()
+	keys := []*entity.RateLimitKey{{Key: "phone:1", Limit: entity.RateLimit{Count: 2, Window: time.Minute}}}
+
+	s.allowed(ctx, keys)
+
+	s.now = s.now.Add(40 * time.Second)
+	s.allowed(ctx, keys)
+
+	s.now = s.now.Add(10 * time.Second)
+	retryAfter, err := s.limiter.Allow(ctx, keys)
+	s.Require().NoError(err)
+	s.Equal(10*time.Second, retryAfter)
+
+	// the first event leaves the window, the second one is still in it
+	s.now = s.now.Add(10 * time.Second)
+	s.allowed(ctx, keys)
+
+	retryAfter, err = s.limiter.Allow(ctx, keys)
+	s.Require().NoError(err)
+	s.Equal(40*time.Second, retryAfter)
+}
+
+func (s *rateLimitTestSuite) TestAllKeysCounted() {
+	ctx := context.Background()
+	phone := &entity.RateLimitKey{Key: "phone:1", Limit: entity.RateLimit{Count: 1, Window: time.Minute}}
+	consumer := &entity.RateLimitKey{Key: "consumer:1", Limit: entity.RateLimit{Count: 2, Window: time.Hour}}
+
+	s.allowed(ctx, []*entity.RateLimitKey{phone, consumer})
+
+	// the denied event is not counted for the consumer
+	retryAfter, err := s.limiter.Allow(ctx, []*entity.RateLimitKey{phone, consumer})
+	s.Require().NoError(err)
+	s.Equal(time.Minute, retryAfter)
+
+	s.allowed(ctx, []*entity.RateLimitKey{
+		{Key: "phone:2", Limit: entity.RateLimit{Count: 1, Window: time.Minute}},
+		consumer,
+	})
+
+	retryAfter, err = s.limiter.Allow(ctx, []*entity.RateLimitKey{
+		{Key: "phone:3", Limit: entity.RateLimit{Count: 1, Window: time.Minute}},
+		consumer,
+	})
+	s.Require().NoError(err)
+	s.Equal(time.Hour, retryAfter)
+}
+
+func (s *rateLimitTestSuite) allowed(ctx context.Context, keys []*entity.RateLimitKey) {
+	retryAfter, err := s.limiter.Allow(ctx, keys)
+	s.Require().NoError(err)
+	s.Zero(retryAfter)
+}
@@ -9,6 +9,10 @@ import (
 //nolint:gochecknoinits
 func init() {
 	cmdRoot.AddCommand(cmdRun)
+
+	cmdSweepTasks.Use = "sweep-tasks"
+	cmdSweepTasks.Short = "Cancel the pending tasks which are not confirmed in time"
+	cmdRoot.AddCommand(cmdSweepTasks)
 	cobra.LoadFlagEnv(cmdRoot.PersistentFlags(), &envFile)
 }
 
@@ -16,6 +20,8 @@ var (
 	envFile string
 	cmdRoot = cobra.CmdRoot(&envFile)
 	cmdRun  = cobra.CmdRunService(runService)
+
+	cmdSweepTasks = cobra.CmdRunService(sweepTasks)
 )
 
 func Run() error {
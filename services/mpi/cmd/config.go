@@ -1,13 +1,152 @@
 package cmd
 
 import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"wasfaty.api/pkg/cerror"
 	"wasfaty.api/pkg/env"
+	"wasfaty.api/services/mpi/adapter/api/extdocregistry"
+	"wasfaty.api/services/mpi/adapter/api/extdocregistry/cache"
+	"wasfaty.api/services/mpi/adapter/api/otp/local"
+	"wasfaty.api/services/mpi/adapter/api/sms"
+	"wasfaty.api/services/mpi/adapter/ratelimit"
+	"wasfaty.api/services/mpi/entity"
 )
 
+// environmentProduction is the ENV value of the production deployment
+const environmentProduction = "production"
+
 type config struct {
 	env.Service
+	// Environment is the deployment environment, the fake adapters are refused in production
+	Environment string `env:"ENV" envDefault:"development"`
 	env.HTTPServer
 	env.Trace
 	env.FHIR
-	env.OTPClient
+	OTP otpConfig
+	extdocregistry.Config
+	ExtDocRegistryCache cache.Config
+	SMS                 sms.Config
+	OTPRateLimit        ratelimit.Config
+
+	TaskSweep               taskSweepConfig
+	PatientMatch            patientMatchConfig
+	PatientMerge            patientMergeConfig
+	DemographicsPolicy      string   `env:"DEMOGRAPHICS_MISMATCH_POLICY" envDefault:"warn"`
+	PossibleDuplicatePolicy string   `env:"POSSIBLE_DUPLICATE_POLICY" envDefault:"output"`
+	FraudPolicy             string   `env:"FRAUD_POLICY" envDefault:"reject"`
+	ReviewerConsumerIDs     []string `env:"REVIEWER_CONSUMER_IDS" envSeparator:","`
+	AdminConsumerIDs        []string `env:"ADMIN_CONSUMER_IDS" envSeparator:","`
+	GatewayNetworks         []string `env:"GATEWAY_TRUSTED_NETWORKS" envSeparator:","`
+	// the metrics are served on the separate internal address, they are not served when it is empty
+	MetricsAddr string `env:"METRICS_ADDR"`
+}
+
+// validate rejects the unsupported policies, a mistyped policy would silently behave like the default one
+func (c *config) validate(ctx context.Context) error {
+	if c.Environment == environmentProduction && c.Fake {
+		return cerror.NewF(ctx, cerror.KindInternal, "EXT_DOC_REGISTRY_FAKE is not allowed in production").LogError()
+	}
+
+	err := validatePolicy(ctx, "DEMOGRAPHICS_MISMATCH_POLICY", c.DemographicsPolicy, entity.DemographicsPolicies())
+	if err != nil {
+		return err
+	}
+
+	return validatePolicy(ctx, "POSSIBLE_DUPLICATE_POLICY", c.PossibleDuplicatePolicy, entity.PossibleDuplicatePolicies())
+}
+
+func validatePolicy(ctx context.Context, name, policy string, supported []string) error {
+	for _, s := range supported {
+		if policy == s {
+			return nil
+		}
+	}
+
+	return cerror.NewF(ctx, cerror.KindInternal, "unsupported %s value %q, expected one of %s",
+		name, policy, strings.Join(supported, ", ")).LogError()
+}
+
+func (c *config) gatewayNets(ctx context.Context) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(c.GatewayNetworks))
+
+	for _, cidr := range c.GatewayNetworks {
+		_, n, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, cerror.New(ctx, cerror.KindInternal, err).LogError()
+		}
+
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+// otpConfig selects the otp engine, the otp service host is required by the remote engine only,
+// so the service runs without the otp service when the local engine is used.
+// The email otp could be delivered by the remote engine only, the email verification is enabled explicitly
+// where the otp service sends the email codes, the local engine requires it disabled
+type otpConfig struct {
+	Engine            string        `env:"OTP_ENGINE" envDefault:"remote"`
+	Host              string        `env:"OTP_SERVICE_HOST"`
+	RequestTimeout    time.Duration `env:"OTP_SERVICE_REQUEST_TIMEOUT" envDefault:"30s"`
+	EmailVerification bool          `env:"OTP_EMAIL_VERIFICATION" envDefault:"false"`
+	Local             local.Config
+}
+
+type patientMatchConfig struct {
+	NameWeight       float64 `env:"PATIENT_MATCH_NAME_WEIGHT" envDefault:"0.35"`
+	BirthDateWeight  float64 `env:"PATIENT_MATCH_BIRTHDATE_WEIGHT" envDefault:"0.25"`
+	GenderWeight     float64 `env:"PATIENT_MATCH_GENDER_WEIGHT" envDefault:"0.1"`
+	PhoneWeight      float64 `env:"PATIENT_MATCH_PHONE_WEIGHT" envDefault:"0.15"`
+	IdentifierWeight float64 `env:"PATIENT_MATCH_IDENTIFIER_WEIGHT" envDefault:"0.15"`
+	Certain          float64 `env:"PATIENT_MATCH_CERTAIN_SCORE" envDefault:"0.95"`
+	Probable         float64 `env:"PATIENT_MATCH_PROBABLE_SCORE" envDefault:"0.85"`
+	Possible         float64 `env:"PATIENT_MATCH_POSSIBLE_SCORE" envDefault:"0.7"`
+}
+
+func (c *patientMatchConfig) policy() *entity.PatientMatchPolicy {
+	return &entity.PatientMatchPolicy{
+		Weights: entity.MatchWeights{
+			Name:       c.NameWeight,
+			BirthDate:  c.BirthDateWeight,
+			Gender:     c.GenderWeight,
+			Phone:      c.PhoneWeight,
+			Identifier: c.IdentifierWeight,
+		},
+		Certain:  c.Certain,
+		Probable: c.Probable,
+		Possible: c.Possible,
+	}
+}
+
+// patientMergeConfig sets the survivorship rules of the patient $merge: target, union or source
+type patientMergeConfig struct {
+	IdentifierRule string `env:"PATIENT_MERGE_IDENTIFIER_RULE" envDefault:"union"`
+	TelecomRule    string `env:"PATIENT_MERGE_TELECOM_RULE" envDefault:"union"`
+}
+
+func (c *patientMergeConfig) survivorship() *entity.MergeSurvivorship {
+	return &entity.MergeSurvivorship{Identifier: c.IdentifierRule, Telecom: c.TelecomRule}
+}
+
+type taskSweepConfig struct {
+	TTL       time.Duration `env:"TASK_SWEEP_TTL" envDefault:"24h"`
+	BatchSize int           `env:"TASK_SWEEP_BATCH_SIZE" envDefault:"100"`
+	// the sweeper is not started inside the service when the interval is zero
+	Interval time.Duration `env:"TASK_SWEEP_INTERVAL" envDefault:"0"`
+}
+
+func (c *taskSweepConfig) params() *entity.SweepTasksParams {
+	return &entity.SweepTasksParams{TTL: c.TTL, BatchSize: c.BatchSize}
+}
+
+// sweepConfig is the config of the sweep-tasks command, it needs the fhir server only
+type sweepConfig struct {
+	env.Service
+	env.FHIR
+	TaskSweep taskSweepConfig
 }
@@ -2,38 +2,129 @@ package cmd
 
 import (
 	"context"
+	"expvar"
+	nethttp "net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"wasfaty.api/pkg/cerror"
 	"wasfaty.api/pkg/env"
 	"wasfaty.api/pkg/log"
 	"wasfaty.api/pkg/log/logger"
 	"wasfaty.api/services/mpi/adapter/api/extdocregistry"
+	"wasfaty.api/services/mpi/adapter/api/extdocregistry/cache"
+	"wasfaty.api/services/mpi/adapter/api/extdocregistry/fake"
 	"wasfaty.api/services/mpi/adapter/api/fhir"
 	"wasfaty.api/services/mpi/adapter/api/otp"
+	"wasfaty.api/services/mpi/adapter/api/otp/local"
+	"wasfaty.api/services/mpi/adapter/api/sms"
+	"wasfaty.api/services/mpi/adapter/ratelimit"
 	"wasfaty.api/services/mpi/controller/http"
+	"wasfaty.api/services/mpi/entity"
 	"wasfaty.api/services/mpi/usecase"
 )
 
+const metricsReadHeaderTimeout = 5 * time.Second
+
 func runService(ctx context.Context) error {
 	cfg := new(config)
 	if err := env.ParseCfg(cfg); err != nil {
 		return err
 	}
 
+	if err := cfg.validate(ctx); err != nil {
+		return err
+	}
+
 	log.SetGlobalLogLevel(cfg.LogLevel)
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	fc := fhir.NewClient(&cfg.FHIR)
-	oc := otp.NewClient(&cfg.OTPClient)
-	edrc := extdocregistry.NewClient()
+	oc, closeOTP, err := newOTPClient(ctx, &cfg.OTP)
+	if err != nil {
+		return err
+	}
+	defer closeOTP()
+
+	edrc := extdocregistry.NewClient(&cfg.Config)
+
+	if cfg.Fake {
+		log.InfoF(ctx, "using fake external document registry")
+
+		fs := fake.NewServer().AllowUnknown()
+		defer fs.Close()
+
+		edrc.WithHTTPClient(fs.HTTPClient())
+	}
+
+	var docReg usecase.ExtDocRegistryClient = edrc
+
+	if cfg.ExtDocRegistryCache.Enabled {
+		var store cache.Store = cache.NewLRUStore(cfg.ExtDocRegistryCache.Size)
+
+		if cfg.ExtDocRegistryCache.Store == cache.StorePostgres {
+			ps, err := cache.NewPostgresStore(ctx, cfg.ExtDocRegistryCache.PostgresDSN,
+				cfg.ExtDocRegistryCache.KeySecret)
+			if err != nil {
+				return err
+			}
+			defer ps.Close()
+
+			go runCachePurger(ctx, ps, cfg.ExtDocRegistryCache.PurgeInterval)
+
+			store = ps
+		}
+
+		c := cache.New(edrc, store, &cfg.ExtDocRegistryCache)
+		expvar.Publish("ext_doc_registry_cache", expvar.Func(func() interface{} { return c.Stats() }))
 
-	uc := usecase.New(fc, oc, edrc)
-	s := http.NewServer(cfg.HTTPServer, cfg.Service, &cfg.Trace, uc)
+		docReg = c
+	}
+
+	ss, err := newSMSSender(ctx, &cfg.SMS, cfg.Environment)
+	if err != nil {
+		return err
+	}
+
+	uc := usecase.New(fc, oc, docReg, ss).
+		WithDemographicsPolicy(cfg.DemographicsPolicy).
+		WithMatchPolicy(cfg.PatientMatch.policy()).
+		WithMergeSurvivorship(cfg.PatientMerge.survivorship()).
+		WithPossibleDuplicatePolicy(cfg.PossibleDuplicatePolicy).
+		WithFraudPolicy(cfg.FraudPolicy).
+		WithReviewers(cfg.ReviewerConsumerIDs).
+		WithAdministrators(cfg.AdminConsumerIDs).
+		WithEmailVerification(cfg.OTP.EmailVerification).
+		WithDeathRegistry(edrc)
+
+	if cfg.OTPRateLimit.Enabled {
+		// the resends allowed by the patient request would be rejected by the lower process limit
+		if n := cfg.OTPRateLimit.ProcessCount; n > 0 && n < 1+entity.OTPResendMaxCount {
+			return cerror.NewF(ctx, cerror.KindInternal, "otp process rate limit should allow at least %d codes",
+				1+entity.OTPResendMaxCount).LogError()
+		}
+
+		uc.WithOTPRateLimiter(ratelimit.NewMemoryLimiter(), cfg.OTPRateLimit.Limits())
+	}
+
+	gatewayNets, err := cfg.gatewayNets(ctx)
+	if err != nil {
+		return err
+	}
+
+	s := http.NewServer(cfg.HTTPServer, cfg.Service, &cfg.Trace, gatewayNets, uc)
+
+	if cfg.TaskSweep.Interval > 0 {
+		go runTaskSweeper(ctx, uc, &cfg.TaskSweep)
+	}
+
+	if cfg.MetricsAddr != "" {
+		go runMetricsServer(ctx, cfg.MetricsAddr)
+	}
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh,
@@ -56,3 +147,117 @@ func runService(ctx context.Context) error {
 		return nil
 	}
 }
+
+// runMetricsServer serves the expvar metrics such as the external registry cache hits on /debug/vars,
+// the address is expected to be reachable from the internal network only
+func runMetricsServer(ctx context.Context, addr string) {
+	mux := nethttp.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	srv := &nethttp.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: metricsReadHeaderTimeout}
+
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && err != nethttp.ErrServerClosed {
+		log.ErrorF(ctx, "metrics server error: %s", err)
+	}
+}
+
+// runCachePurger deletes the expired registry results every interval until the context is done
+func runCachePurger(ctx context.Context, ps *cache.PostgresStore, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			// the error is already logged, the next purge deletes the rows
+			n, err := ps.Purge(ctx)
+			if err == nil && n > 0 {
+				log.InfoF(ctx, "%d expired registry cache results are deleted", n)
+			}
+		}
+	}
+}
+
+// newSMSSender returns the sender of the configured kind, the file and the memory senders do not send the messages,
+// so they are refused in production
+func newSMSSender(ctx context.Context, cfg *sms.Config, environment string) (usecase.SMSSender, error) {
+	if environment == environmentProduction && (cfg.Sender == sms.SenderFile || cfg.Sender == sms.SenderMemory) {
+		return nil, cerror.NewF(ctx, cerror.KindInternal, "%s sms sender is not allowed in production", cfg.Sender).
+			LogError()
+	}
+
+	switch cfg.Sender {
+	case sms.SenderGateway:
+		if cfg.GatewayHost == "" {
+			return nil, cerror.NewF(ctx, cerror.KindInternal, "sms gateway host is required by the gateway sms sender").
+				LogError()
+		}
+
+		return sms.NewGatewayClient(cfg), nil
+	case sms.SenderSMPP:
+		if cfg.SMPPAddr == "" {
+			return nil, cerror.NewF(ctx, cerror.KindInternal, "smsc address is required by the smpp sms sender").
+				LogError()
+		}
+
+		return sms.NewSMPPClient(cfg), nil
+	case sms.SenderFile:
+		log.InfoF(ctx, "using file sms sender %s", cfg.FilePath)
+		return sms.NewFileSender(cfg.FilePath), nil
+	case sms.SenderMemory:
+		log.InfoF(ctx, "using memory sms sender")
+		return sms.NewMemorySender(), nil
+	default:
+		return nil, cerror.NewF(ctx, cerror.KindInternal, "unsupported sms sender %s", cfg.Sender).LogError()
+	}
+}
+
+// newOTPClient returns the otp client of the configured engine and the function releasing its resources
+func newOTPClient(ctx context.Context, cfg *otpConfig) (usecase.OTPClient, func(), error) {
+	switch cfg.Engine {
+	case otp.EngineRemote:
+		if cfg.Host == "" {
+			return nil, nil, cerror.NewF(ctx, cerror.KindInternal, "otp service host is required by the remote otp engine").
+				LogError()
+		}
+
+		return otp.NewClient(&env.OTPClient{Host: cfg.Host, RequestTimeout: cfg.RequestTimeout}), func() {}, nil
+	case otp.EngineLocal:
+		if cfg.EmailVerification {
+			return nil, nil, cerror.NewF(ctx, cerror.KindInternal,
+				"local otp engine does not deliver email otp, the email verification should be disabled").LogError()
+		}
+
+		if cfg.Local.Secret == "" {
+			return nil, nil, cerror.NewF(ctx, cerror.KindInternal, "otp secret is required by the local otp engine").
+				LogError()
+		}
+
+		if cfg.Local.Store == local.StoreMemory {
+			log.InfoF(ctx, "using local otp engine with memory store")
+			return local.NewClient(&cfg.Local, local.NewMemoryStore()), func() {}, nil
+		}
+
+		ps, err := local.NewPostgresStore(ctx, cfg.Local.PostgresDSN)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		log.InfoF(ctx, "using local otp engine")
+
+		return local.NewClient(&cfg.Local, ps), func() { _ = ps.Close() }, nil
+	default:
+		return nil, nil, cerror.NewF(ctx, cerror.KindInternal, "unsupported otp engine %s", cfg.Engine).LogError()
+	}
+}
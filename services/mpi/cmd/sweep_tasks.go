@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"wasfaty.api/pkg/env"
+	"wasfaty.api/pkg/log"
+	"wasfaty.api/services/mpi/adapter/api/fhir"
+	"wasfaty.api/services/mpi/usecase"
+)
+
+// sweepTasks cancels the expired pending tasks once, it is run by the external scheduler
+func sweepTasks(ctx context.Context) error {
+	cfg := new(sweepConfig)
+	if err := env.ParseCfg(cfg); err != nil {
+		return err
+	}
+
+	log.SetGlobalLogLevel(cfg.LogLevel)
+
+	n, err := usecase.New(fhir.NewClient(&cfg.FHIR), nil, nil, nil).SweepExpiredTasks(ctx, cfg.TaskSweep.params())
+	if err != nil {
+		return err
+	}
+
+	log.InfoF(ctx, "%d expired tasks are canceled", n)
+
+	return nil
+}
+
+// runTaskSweeper cancels the expired pending tasks every interval until the context is done
+func runTaskSweeper(ctx context.Context, uc *usecase.UseCase, cfg *taskSweepConfig) {
+	t := time.NewTicker(cfg.Interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			// the error is already logged, the next sweep retries the tasks
+			n, err := uc.SweepExpiredTasks(ctx, cfg.params())
+			if err == nil && n > 0 {
+				log.InfoF(ctx, "%d expired tasks are canceled", n)
+			}
+		}
+	}
+}
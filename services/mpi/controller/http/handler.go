@@ -2,13 +2,18 @@ package http
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 
 	"wasfaty.api/pkg/cerror"
 	"wasfaty.api/pkg/fhir/ferror"
 	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
 )
 
 type UseCase interface {
@@ -18,6 +23,27 @@ type UseCase interface {
 	UpdatePatientIdentity(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
 	ConfirmUpdatePatientIdentity(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
 	UpdatePatientEmail(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	GetPatient(ctx context.Context, id fhirModel.ID, p *entity.GetPatientParams) (map[string]interface{}, error)
+	SearchPatients(ctx context.Context, p *entity.SearchPatientParams) (*fhirModel.Bundle, error)
+	GetTask(ctx context.Context, id fhirModel.ID) (*fhirModel.Task, error)
+	SearchTasks(ctx context.Context, p *entity.SearchTaskParams) (*fhirModel.Bundle, error)
+	CancelTask(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	ResendOTP(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	UpdatePatientPhone(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	ConfirmUpdatePatientPhone(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	ConfirmUpdatePatientEmail(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	ValidateCreatePatient(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.OperationOutcome, error)
+	MatchPatients(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Bundle, error)
+	MergePatients(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	UnmergePatients(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	LinkPatients(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	UnlinkPatients(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	DeactivatePatient(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	ReactivatePatient(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	MarkPatientDeceased(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	SearchReviewTasks(ctx context.Context, p *entity.SearchReviewTaskParams) (*fhirModel.Bundle, error)
+	ApproveReviewTask(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	RejectReviewTask(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
 }
 
 var (
@@ -148,6 +174,46 @@ func (h *handler) confirmCreatePatient(ctx *fiber.Ctx) error {
 	return ctx.Status(fiber.StatusCreated).JSON(resp)
 }
 
+// (POST /Patient/$create-request/$validate)
+//nolint:dupl
+func (h *handler) validateCreatePatient(ctx *fiber.Ctx) error {
+	req := new(fhirModel.Parameters)
+	if err := ctx.BodyParser(req); err != nil {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, err).LogError())
+	}
+
+	if err := validateReqProfile(ctx.Context(), req, []string{fhirModel.StructureDefinitionPatientCreateRequest}); err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	resp, err := h.uc.ValidateCreatePatient(ctx.Context(), req)
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
+// (POST /Patient/$resend-otp)
+//nolint:dupl
+func (h *handler) resendOTP(ctx *fiber.Ctx) error {
+	req := new(fhirModel.Parameters)
+	if err := ctx.BodyParser(req); err != nil {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, err).LogError())
+	}
+
+	if err := validateReqProfile(ctx.Context(), req, []string{entity.StructureDefinitionPatientResendOTPRequest}); err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	resp, err := h.uc.ResendOTP(ctx.Context(), req)
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
 // (POST /Patient/[id]/$confirm-identity)
 //nolint:dupl
 func (h *handler) confirmUpdatePatientIdentity(ctx *fiber.Ctx) error {
@@ -173,6 +239,459 @@ func (h *handler) confirmUpdatePatientIdentity(ctx *fiber.Ctx) error {
 	return ctx.Status(fiber.StatusOK).JSON(resp)
 }
 
+// (POST /Patient/[id]/$update-phone)
+//nolint:dupl
+func (h *handler) updatePatientPhone(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	if id == "" {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, errEmptyID).LogError())
+	}
+
+	req := new(fhirModel.Parameters)
+	if err := ctx.BodyParser(req); err != nil {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, err).LogError())
+	}
+
+	if err := validateReqProfile(ctx.Context(), req, []string{entity.StructureDefinitionPatientUpdatePhoneRequest}); err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	resp, err := h.uc.UpdatePatientPhone(ctx.Context(), fhirModel.ID(id), req)
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
+// (POST /Patient/[id]/$confirm-phone)
+//nolint:dupl
+func (h *handler) confirmUpdatePatientPhone(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	if id == "" {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, errEmptyID).LogError())
+	}
+
+	req := new(fhirModel.Parameters)
+	if err := ctx.BodyParser(req); err != nil {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, err).LogError())
+	}
+
+	if err := validateReqProfile(ctx.Context(), req, []string{entity.StructureDefinitionPatientConfirmUpdatePhoneRequest}); err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	resp, err := h.uc.ConfirmUpdatePatientPhone(ctx.Context(), fhirModel.ID(id), req)
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
+// (POST /Patient/[id]/$confirm-email)
+//nolint:dupl
+func (h *handler) confirmUpdatePatientEmail(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	if id == "" {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, errEmptyID).LogError())
+	}
+
+	req := new(fhirModel.Parameters)
+	if err := ctx.BodyParser(req); err != nil {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, err).LogError())
+	}
+
+	if err := validateReqProfile(ctx.Context(), req, []string{entity.StructureDefinitionPatientConfirmUpdateEmailRequest}); err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	resp, err := h.uc.ConfirmUpdatePatientEmail(ctx.Context(), fhirModel.ID(id), req)
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
+// (GET /Patient/[id])
+func (h *handler) getPatient(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	if id == "" {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, errEmptyID).LogError())
+	}
+
+	resp, err := h.uc.GetPatient(ctx.Context(), fhirModel.ID(id), &entity.GetPatientParams{
+		Elements: splitQueryList(ctx.Query("_elements")),
+		Summary:  ctx.Query("_summary"),
+	})
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
+// (GET /Patient?identifier=system|value) or (GET /Patient?phone=&birthdate=)
+func (h *handler) searchPatients(ctx *fiber.Ctx) error {
+	req := new(entity.SearchPatientParams)
+
+	if identifier := ctx.Query("identifier"); identifier != "" {
+		req.Identifier = &entity.SearchPatientByIdentifierParams{Value: identifier}
+	}
+
+	if phone, birthDate := ctx.Query("phone"), ctx.Query("birthdate"); phone != "" || birthDate != "" {
+		req.Phone = &entity.SearchPatientByPhoneParams{Phone: phone, BirthDate: birthDate}
+	}
+
+	var err error
+
+	if req.Count, err = queryInt(ctx, "_count"); err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	if req.Offset, err = queryInt(ctx, "_offset"); err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	resp, err := h.uc.SearchPatients(ctx.Context(), req)
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
+// (POST /Patient/$match)
+//nolint:dupl
+func (h *handler) matchPatients(ctx *fiber.Ctx) error {
+	req := new(fhirModel.Parameters)
+	if err := ctx.BodyParser(req); err != nil {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, err).LogError())
+	}
+
+	if err := validateReqProfile(ctx.Context(), req, []string{entity.StructureDefinitionPatientMatchRequest}); err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	resp, err := h.uc.MatchPatients(ctx.Context(), req)
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
+// (POST /Patient/$merge)
+//nolint:dupl
+func (h *handler) mergePatients(ctx *fiber.Ctx) error {
+	req := new(fhirModel.Parameters)
+	if err := ctx.BodyParser(req); err != nil {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, err).LogError())
+	}
+
+	if err := validateReqProfile(ctx.Context(), req, []string{entity.StructureDefinitionPatientMergeRequest}); err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	resp, err := h.uc.MergePatients(ctx.Context(), req)
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
+// (POST /Patient/$unmerge)
+//nolint:dupl
+func (h *handler) unmergePatients(ctx *fiber.Ctx) error {
+	req := new(fhirModel.Parameters)
+	if err := ctx.BodyParser(req); err != nil {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, err).LogError())
+	}
+
+	if err := validateReqProfile(ctx.Context(), req, []string{entity.StructureDefinitionPatientUnmergeRequest}); err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	resp, err := h.uc.UnmergePatients(ctx.Context(), req)
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
+// (POST /Patient/$link)
+//nolint:dupl
+func (h *handler) linkPatients(ctx *fiber.Ctx) error {
+	req := new(fhirModel.Parameters)
+	if err := ctx.BodyParser(req); err != nil {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, err).LogError())
+	}
+
+	if err := validateReqProfile(ctx.Context(), req, []string{entity.StructureDefinitionPatientLinkRequest}); err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	resp, err := h.uc.LinkPatients(ctx.Context(), req)
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
+// (POST /Patient/$unlink)
+//nolint:dupl
+func (h *handler) unlinkPatients(ctx *fiber.Ctx) error {
+	req := new(fhirModel.Parameters)
+	if err := ctx.BodyParser(req); err != nil {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, err).LogError())
+	}
+
+	if err := validateReqProfile(ctx.Context(), req, []string{entity.StructureDefinitionPatientUnlinkRequest}); err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	resp, err := h.uc.UnlinkPatients(ctx.Context(), req)
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
+// (POST /Patient/[id]/$deactivate)
+//nolint:dupl
+func (h *handler) deactivatePatient(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	if id == "" {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, errEmptyID).LogError())
+	}
+
+	req := new(fhirModel.Parameters)
+	if err := ctx.BodyParser(req); err != nil {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, err).LogError())
+	}
+
+	if err := validateReqProfile(ctx.Context(), req, []string{entity.StructureDefinitionPatientDeactivateRequest}); err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	resp, err := h.uc.DeactivatePatient(ctx.Context(), fhirModel.ID(id), req)
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
+// (POST /Patient/[id]/$reactivate)
+//nolint:dupl
+func (h *handler) reactivatePatient(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	if id == "" {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, errEmptyID).LogError())
+	}
+
+	req := new(fhirModel.Parameters)
+	if err := ctx.BodyParser(req); err != nil {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, err).LogError())
+	}
+
+	if err := validateReqProfile(ctx.Context(), req, []string{entity.StructureDefinitionPatientReactivateRequest}); err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	resp, err := h.uc.ReactivatePatient(ctx.Context(), fhirModel.ID(id), req)
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
+// (POST /Patient/[id]/$mark-deceased)
+//nolint:dupl
+func (h *handler) markPatientDeceased(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	if id == "" {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, errEmptyID).LogError())
+	}
+
+	req := new(fhirModel.Parameters)
+	if err := ctx.BodyParser(req); err != nil {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, err).LogError())
+	}
+
+	if err := validateReqProfile(ctx.Context(), req, []string{entity.StructureDefinitionPatientMarkDeceasedRequest}); err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	resp, err := h.uc.MarkPatientDeceased(ctx.Context(), fhirModel.ID(id), req)
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
+// (POST /Task/[id]/$cancel)
+//nolint:dupl
+func (h *handler) cancelTask(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	if id == "" {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, errEmptyID).LogError())
+	}
+
+	req := new(fhirModel.Parameters)
+	if err := ctx.BodyParser(req); err != nil {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, err).LogError())
+	}
+
+	if err := validateReqProfile(ctx.Context(), req, []string{entity.StructureDefinitionTaskCancelRequest}); err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	resp, err := h.uc.CancelTask(ctx.Context(), fhirModel.ID(id), req)
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
+// (GET /Task/[id])
+func (h *handler) getTask(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	if id == "" {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, errEmptyID).LogError())
+	}
+
+	resp, err := h.uc.GetTask(ctx.Context(), fhirModel.ID(id))
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
+// (GET /Task?patient=&status=&profile=)
+func (h *handler) searchTasks(ctx *fiber.Ctx) error {
+	req := &entity.SearchTaskParams{
+		PatientID: fhirModel.ID(strings.TrimPrefix(ctx.Query("patient"), fhirModel.ResourcePatient+"/")),
+		Status:    ctx.Query("status"),
+		Profiles:  splitQueryList(ctx.Query("profile")),
+	}
+
+	resp, err := h.uc.SearchTasks(ctx.Context(), req)
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
+// (GET /Task/$review-queue?reason=&_count=&_offset=)
+func (h *handler) searchReviewTasks(ctx *fiber.Ctx) error {
+	req := &entity.SearchReviewTaskParams{Reason: ctx.Query("reason")}
+
+	var err error
+
+	if req.Count, err = queryInt(ctx, "_count"); err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	if req.Offset, err = queryInt(ctx, "_offset"); err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	resp, err := h.uc.SearchReviewTasks(ctx.Context(), req)
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
+// (POST /Task/[id]/$approve)
+//nolint:dupl
+func (h *handler) approveReviewTask(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	if id == "" {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, errEmptyID).LogError())
+	}
+
+	req := new(fhirModel.Parameters)
+	if err := ctx.BodyParser(req); err != nil {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, err).LogError())
+	}
+
+	if err := validateReqProfile(ctx.Context(), req, []string{entity.StructureDefinitionTaskReviewRequest}); err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	resp, err := h.uc.ApproveReviewTask(ctx.Context(), fhirModel.ID(id), req)
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
+// (POST /Task/[id]/$reject)
+//nolint:dupl
+func (h *handler) rejectReviewTask(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	if id == "" {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, errEmptyID).LogError())
+	}
+
+	req := new(fhirModel.Parameters)
+	if err := ctx.BodyParser(req); err != nil {
+		return writeErrorResp(ctx, cerror.New(ctx.Context(), cerror.KindBadParams, err).LogError())
+	}
+
+	if err := validateReqProfile(ctx.Context(), req, []string{entity.StructureDefinitionTaskReviewRequest}); err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	resp, err := h.uc.RejectReviewTask(ctx.Context(), fhirModel.ID(id), req)
+	if err != nil {
+		return writeErrorResp(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(resp)
+}
+
+func queryInt(ctx *fiber.Ctx, key string) (int, error) {
+	v := ctx.Query(key)
+	if v == "" {
+		return 0, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, cerror.NewValidationError(ctx.Context(), map[string]string{key: "expected to be an integer"}).LogError()
+	}
+
+	return i, nil
+}
+
+func splitQueryList(v string) []string {
+	if v == "" {
+		return nil
+	}
+
+	list := strings.Split(v, ",")
+	for i := range list {
+		list[i] = strings.TrimSpace(list[i])
+	}
+
+	return list
+}
+
 func validateReqProfile(ctx context.Context, p *fhirModel.Parameters, allowedProfiles []string) error {
 	if p.Meta == nil {
 		return cerror.NewValidationError(ctx, map[string]string{"Parameters.meta": "value is required"})
@@ -193,5 +712,48 @@ func validateReqProfile(ctx context.Context, p *fhirModel.Parameters, allowedPro
 }
 
 func writeErrorResp(ctx *fiber.Ctx, err error) error {
-	return ctx.Status(cerror.ErrKind(err).HTTPCode()).JSON(ferror.OutcomeFromError(err))
+	o := ferror.OutcomeFromError(err)
+	addOTPAttemptsExtension(o, err)
+
+	if retryAfter, ok := rateLimitRetryAfter(err); ok {
+		ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+		return ctx.Status(fiber.StatusTooManyRequests).JSON(o)
+	}
+
+	return ctx.Status(cerror.ErrKind(err).HTTPCode()).JSON(o)
+}
+
+// rateLimitRetryAfter returns the seconds after which the limited request can be retried
+func rateLimitRetryAfter(err error) (int, bool) {
+	var cErr *cerror.CError
+	if !errors.As(err, &cErr) {
+		return 0, false
+	}
+
+	e, ok := cErr.Payload().(*entity.RateLimitExceeded)
+	if !ok {
+		return 0, false
+	}
+
+	return int(math.Ceil(e.RetryAfter.Seconds())), true
+}
+
+// addOTPAttemptsExtension tells the caller how many otp attempts remain after a wrong code
+func addOTPAttemptsExtension(o *fhirModel.OperationOutcome, err error) {
+	var cErr *cerror.CError
+	if !errors.As(err, &cErr) {
+		return
+	}
+
+	a, ok := cErr.Payload().(*entity.OTPAttempts)
+	if !ok {
+		return
+	}
+
+	remaining := a.Remaining()
+
+	o.Extension = append(o.Extension, &fhirModel.Extension{
+		URL:    entity.StructureDefinitionOutcomeOTPRemainingAttempts,
+		ValueX: fhirModel.ValueX{ValueInteger: &remaining},
+	})
 }
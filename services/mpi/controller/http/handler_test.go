@@ -6,8 +6,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/suite"
@@ -19,6 +21,7 @@ import (
 	pkgfiber "wasfaty.api/pkg/http/fiber"
 	"wasfaty.api/pkg/log"
 	"wasfaty.api/services/mpi/controller/http"
+	"wasfaty.api/services/mpi/entity"
 )
 
 type testUseCase struct {
@@ -28,6 +31,27 @@ type testUseCase struct {
 	confirmCreatePatientFunc         func(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error)
 	updatePatientIdentityFunc        func(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
 	confirmUpdatePatientIdentityFunc func(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	getPatientFunc                   func(ctx context.Context, id fhirModel.ID, p *entity.GetPatientParams) (map[string]interface{}, error)
+	searchPatientsFunc               func(ctx context.Context, p *entity.SearchPatientParams) (*fhirModel.Bundle, error)
+	getTaskFunc                      func(ctx context.Context, id fhirModel.ID) (*fhirModel.Task, error)
+	searchTasksFunc                  func(ctx context.Context, p *entity.SearchTaskParams) (*fhirModel.Bundle, error)
+	cancelTaskFunc                   func(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	resendOTPFunc                    func(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	updatePatientPhoneFunc           func(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	confirmUpdatePatientPhoneFunc    func(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	confirmUpdatePatientEmailFunc    func(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	validateCreatePatientFunc        func(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.OperationOutcome, error)
+	matchPatientsFunc                func(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Bundle, error)
+	mergePatientsFunc                func(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	unmergePatientsFunc              func(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	linkPatientsFunc                 func(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	unlinkPatientsFunc               func(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	deactivatePatientFunc            func(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	reactivatePatientFunc            func(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	markPatientDeceasedFunc          func(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	searchReviewTasksFunc            func(ctx context.Context, p *entity.SearchReviewTaskParams) (*fhirModel.Bundle, error)
+	approveReviewTaskFunc            func(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
+	rejectReviewTaskFunc             func(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error)
 }
 
 func (tuc *testUseCase) CreatePatient(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error) {
@@ -56,6 +80,94 @@ func (tuc *testUseCase) ConfirmUpdatePatientIdentity(ctx context.Context, id fhi
 	return tuc.confirmUpdatePatientIdentityFunc(ctx, id, p)
 }
 
+func (tuc *testUseCase) GetPatient(ctx context.Context, id fhirModel.ID, p *entity.GetPatientParams) (
+	map[string]interface{}, error) {
+	return tuc.getPatientFunc(ctx, id, p)
+}
+
+func (tuc *testUseCase) SearchPatients(ctx context.Context, p *entity.SearchPatientParams) (*fhirModel.Bundle, error) {
+	return tuc.searchPatientsFunc(ctx, p)
+}
+
+func (tuc *testUseCase) GetTask(ctx context.Context, id fhirModel.ID) (*fhirModel.Task, error) {
+	return tuc.getTaskFunc(ctx, id)
+}
+
+func (tuc *testUseCase) SearchTasks(ctx context.Context, p *entity.SearchTaskParams) (*fhirModel.Bundle, error) {
+	return tuc.searchTasksFunc(ctx, p)
+}
+
+func (tuc *testUseCase) CancelTask(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+	return tuc.cancelTaskFunc(ctx, id, p)
+}
+
+func (tuc *testUseCase) ResendOTP(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+	return tuc.resendOTPFunc(ctx, p)
+}
+
+func (tuc *testUseCase) UpdatePatientPhone(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+	return tuc.updatePatientPhoneFunc(ctx, id, p)
+}
+
+func (tuc *testUseCase) ConfirmUpdatePatientPhone(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+	return tuc.confirmUpdatePatientPhoneFunc(ctx, id, p)
+}
+
+func (tuc *testUseCase) ConfirmUpdatePatientEmail(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+	return tuc.confirmUpdatePatientEmailFunc(ctx, id, p)
+}
+
+func (tuc *testUseCase) ValidateCreatePatient(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.OperationOutcome, error) {
+	return tuc.validateCreatePatientFunc(ctx, p)
+}
+
+func (tuc *testUseCase) MatchPatients(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Bundle, error) {
+	return tuc.matchPatientsFunc(ctx, p)
+}
+
+func (tuc *testUseCase) MergePatients(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+	return tuc.mergePatientsFunc(ctx, p)
+}
+
+func (tuc *testUseCase) UnmergePatients(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+	return tuc.unmergePatientsFunc(ctx, p)
+}
+
+func (tuc *testUseCase) LinkPatients(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+	return tuc.linkPatientsFunc(ctx, p)
+}
+
+func (tuc *testUseCase) UnlinkPatients(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+	return tuc.unlinkPatientsFunc(ctx, p)
+}
+
+func (tuc *testUseCase) DeactivatePatient(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (
+	*fhirModel.Task, error) {
+	return tuc.deactivatePatientFunc(ctx, id, p)
+}
+
+func (tuc *testUseCase) ReactivatePatient(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (
+	*fhirModel.Task, error) {
+	return tuc.reactivatePatientFunc(ctx, id, p)
+}
+
+func (tuc *testUseCase) MarkPatientDeceased(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (
+	*fhirModel.Task, error) {
+	return tuc.markPatientDeceasedFunc(ctx, id, p)
+}
+
+func (tuc *testUseCase) SearchReviewTasks(ctx context.Context, p *entity.SearchReviewTaskParams) (*fhirModel.Bundle, error) {
+	return tuc.searchReviewTasksFunc(ctx, p)
+}
+
+func (tuc *testUseCase) ApproveReviewTask(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+	return tuc.approveReviewTaskFunc(ctx, id, p)
+}
+
+func (tuc *testUseCase) RejectReviewTask(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+	return tuc.rejectReviewTaskFunc(ctx, id, p)
+}
+
 type handlerTestSuite struct {
 	suite.Suite
 	uc *testUseCase
@@ -69,7 +181,7 @@ func TestHandlerTestSuite(t *testing.T) {
 
 func (s *handlerTestSuite) SetupSuite() {
 	s.uc = new(testUseCase)
-	s.s = http.NewServer(env.HTTPServer{}, env.Service{}, nil, s.uc)
+	s.s = http.NewServer(env.HTTPServer{}, env.Service{}, nil, testGatewayNets(), s.uc)
 }
 
 func (s *handlerTestSuite) TearDownTest() {
@@ -79,6 +191,27 @@ func (s *handlerTestSuite) TearDownTest() {
 	s.uc.confirmCreatePatientFunc = nil
 	s.uc.updatePatientIdentityFunc = nil
 	s.uc.confirmUpdatePatientIdentityFunc = nil
+	s.uc.getPatientFunc = nil
+	s.uc.searchPatientsFunc = nil
+	s.uc.getTaskFunc = nil
+	s.uc.searchTasksFunc = nil
+	s.uc.cancelTaskFunc = nil
+	s.uc.resendOTPFunc = nil
+	s.uc.updatePatientPhoneFunc = nil
+	s.uc.confirmUpdatePatientPhoneFunc = nil
+	s.uc.confirmUpdatePatientEmailFunc = nil
+	s.uc.validateCreatePatientFunc = nil
+	s.uc.matchPatientsFunc = nil
+	s.uc.mergePatientsFunc = nil
+	s.uc.unmergePatientsFunc = nil
+	s.uc.linkPatientsFunc = nil
+	s.uc.unlinkPatientsFunc = nil
+	s.uc.deactivatePatientFunc = nil
+	s.uc.reactivatePatientFunc = nil
+	s.uc.markPatientDeceasedFunc = nil
+	s.uc.searchReviewTasksFunc = nil
+	s.uc.approveReviewTaskFunc = nil
+	s.uc.rejectReviewTaskFunc = nil
 }
 
 func (s *handlerTestSuite) TearDownSuite() {
@@ -204,6 +337,50 @@ func (s *handlerTestSuite) TestCreatePatient() {
 	s.testProfileError(req, tm)
 }
 
+func (s *handlerTestSuite) TestValidateCreatePatient() {
+	var isCalled bool
+
+	_, req := preparePatientReq(fhirModel.StructureDefinitionPatientCreateRequest, nil)
+	outcome := &fhirModel.OperationOutcome{
+		DomainResource: fhirModel.DomainResource{
+			Resource: fhirModel.Resource{ResourceType: fhirModel.ResourceOperationOutcome},
+		},
+		Issue: []*fhirModel.OperationOutcomeIssue{
+			{Severity: "error", Code: "invariant", Diagnostics: "such person already exists"},
+		},
+	}
+
+	s.uc.validateCreatePatientFunc = func(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.OperationOutcome, error) {
+		isCalled = true
+		dst := new(fhirModel.Patient)
+
+		interfaceToStruct(p.Parameter[0].Resource, dst)
+
+		p.Parameter[0].Resource = dst
+
+		s.Equal(req, p)
+
+		return outcome, nil
+	}
+
+	tm := &testModel{
+		method:       fiber.MethodPost,
+		route:        "/Patient/$create-request/$validate",
+		req:          req,
+		dst:          new(fhirModel.OperationOutcome),
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*fhirModel.OperationOutcome)
+			s.True(ok)
+			s.Equal(outcome, body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+
+	s.testProfileError(req, tm)
+}
+
 //nolint: dupl
 func (s *handlerTestSuite) TestUpdatePatientIdentity() {
 	var isCalled bool
@@ -355,6 +532,885 @@ func (s *handlerTestSuite) TestUpdateEmailPatient() {
 	s.testProfileError(req, tm)
 }
 
+func (s *handlerTestSuite) TestGetPatient() {
+	var isCalled bool
+
+	patientID := fhirModel.ID("123")
+	expected := map[string]interface{}{"resourceType": "Patient", "id": "123", "active": true}
+
+	s.uc.getPatientFunc = func(ctx context.Context, id fhirModel.ID, p *entity.GetPatientParams) (
+		map[string]interface{}, error) {
+		isCalled = true
+
+		s.Equal(patientID, id)
+		s.Equal(&entity.GetPatientParams{Elements: []string{"active", "name"}, Summary: ""}, p)
+
+		return expected, nil
+	}
+
+	tm := &testModel{
+		method:       fiber.MethodGet,
+		route:        fmt.Sprintf("/Patient/%s?_elements=active,name", patientID),
+		dst:          &map[string]interface{}{},
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*map[string]interface{})
+			s.True(ok)
+			s.Equal(expected, *body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+
+	s.uc.getPatientFunc = func(ctx context.Context, id fhirModel.ID, p *entity.GetPatientParams) (
+		map[string]interface{}, error) {
+		return nil, cerror.NewF(ctx, cerror.KindNotExist, "such patient does not exist")
+	}
+
+	tm.expectedCode = fiber.StatusNotFound
+	tm.dst = new(fhirModel.OperationOutcome)
+	tm.assertFn = s.assertErr
+
+	testByModel(s, tm)
+}
+
+func (s *handlerTestSuite) TestSearchPatients() {
+	var isCalled bool
+
+	expected := &fhirModel.Bundle{
+		Resource: fhirModel.Resource{ID: fhirModel.ID("bundle-123")},
+		Type:     fhirModel.BundleTypeSearchset,
+	}
+
+	s.uc.searchPatientsFunc = func(ctx context.Context, p *entity.SearchPatientParams) (*fhirModel.Bundle, error) {
+		isCalled = true
+
+		s.Equal(&entity.SearchPatientParams{
+			Phone:  &entity.SearchPatientByPhoneParams{Phone: "+380673212121", BirthDate: "2000-01-01"},
+			Count:  5,
+			Offset: 10,
+		}, p)
+
+		return expected, nil
+	}
+
+	tm := &testModel{
+		method:       fiber.MethodGet,
+		route:        "/Patient?phone=%2B380673212121&birthdate=2000-01-01&_count=5&_offset=10",
+		dst:          new(fhirModel.Bundle),
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*fhirModel.Bundle)
+			s.True(ok)
+			s.Equal(expected, body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+
+	tm.route = "/Patient?identifier=system%7Cvalue&_count=abc"
+	tm.expectedCode = fiber.StatusUnprocessableEntity
+	tm.dst = new(fhirModel.OperationOutcome)
+	tm.assertFn = s.assertErr
+
+	testByModel(s, tm)
+}
+
+func (s *handlerTestSuite) TestGetTask() {
+	var isCalled bool
+
+	s.uc.getTaskFunc = func(ctx context.Context, id fhirModel.ID) (*fhirModel.Task, error) {
+		isCalled = true
+
+		s.Equal(task.ID, id)
+
+		return task, nil
+	}
+
+	tm := &testModel{
+		method:       fiber.MethodGet,
+		route:        fmt.Sprintf("/Task/%s", task.ID),
+		dst:          new(fhirModel.Task),
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*fhirModel.Task)
+			s.True(ok)
+			s.Equal(task, body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+
+	s.uc.getTaskFunc = func(ctx context.Context, id fhirModel.ID) (*fhirModel.Task, error) {
+		return nil, cerror.NewF(ctx, cerror.KindNotExist, "such patient request does not exist")
+	}
+
+	tm.expectedCode = fiber.StatusNotFound
+	tm.dst = new(fhirModel.OperationOutcome)
+	tm.assertFn = s.assertErr
+
+	testByModel(s, tm)
+}
+
+func (s *handlerTestSuite) TestSearchTasks() {
+	var isCalled bool
+
+	expected := &fhirModel.Bundle{
+		Resource: fhirModel.Resource{ID: fhirModel.ID("bundle-123")},
+		Type:     fhirModel.BundleTypeSearchset,
+	}
+
+	s.uc.searchTasksFunc = func(ctx context.Context, p *entity.SearchTaskParams) (*fhirModel.Bundle, error) {
+		isCalled = true
+
+		s.Equal(&entity.SearchTaskParams{
+			PatientID: fhirModel.ID("123"),
+			Status:    fhirModel.TaskStatusInProgress,
+			Profiles:  []string{fhirModel.StructureDefinitionTaskPatientUpdateIdentity},
+		}, p)
+
+		return expected, nil
+	}
+
+	tm := &testModel{
+		method: fiber.MethodGet,
+		route: fmt.Sprintf("/Task?patient=Patient/123&status=%s&profile=%s",
+			fhirModel.TaskStatusInProgress, fhirModel.StructureDefinitionTaskPatientUpdateIdentity),
+		dst:          new(fhirModel.Bundle),
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*fhirModel.Bundle)
+			s.True(ok)
+			s.Equal(expected, body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+}
+
+func (s *handlerTestSuite) TestCancelTask() {
+	var isCalled bool
+
+	req := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionTaskCancelRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "reason", ValueX: fhirModel.ValueX{ValueString: converto.StringPointer("changed my mind")}},
+		},
+	}
+
+	s.uc.cancelTaskFunc = func(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+		isCalled = true
+
+		s.Equal(task.ID, id)
+		s.Equal(req, p)
+
+		return task, nil
+	}
+
+	tm := &testModel{
+		method:       fiber.MethodPost,
+		route:        fmt.Sprintf("/Task/%s/$cancel", task.ID),
+		req:          req,
+		dst:          new(fhirModel.Task),
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*fhirModel.Task)
+			s.True(ok)
+			s.Equal(task, body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+
+	s.testProfileError(req, tm)
+}
+
+func (s *handlerTestSuite) TestResendOTP() {
+	var isCalled bool
+
+	req := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientResendOTPRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "task_id", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Task/123"}}},
+		},
+	}
+
+	s.uc.resendOTPFunc = func(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+		isCalled = true
+
+		s.Equal(req, p)
+
+		return task, nil
+	}
+
+	tm := &testModel{
+		method:       fiber.MethodPost,
+		route:        "/Patient/$resend-otp",
+		req:          req,
+		dst:          new(fhirModel.Task),
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*fhirModel.Task)
+			s.True(ok)
+			s.Equal(task, body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+
+	s.testProfileError(req, tm)
+}
+
+func (s *handlerTestSuite) TestUpdatePatientPhone() {
+	var isCalled bool
+
+	patientID := fhirModel.ID("123")
+	req := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientUpdatePhoneRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "phone", ValueX: fhirModel.ValueX{ValueString: converto.StringPointer("+380671112233")}},
+			{Name: "confirmOldPhone", ValueX: fhirModel.ValueX{ValueBoolean: converto.BoolPointer(true)}},
+		},
+	}
+
+	s.uc.updatePatientPhoneFunc = func(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+		isCalled = true
+
+		s.Equal(patientID, id)
+		s.Equal(req, p)
+
+		return task, nil
+	}
+
+	tm := &testModel{
+		method:       fiber.MethodPost,
+		route:        fmt.Sprintf("/Patient/%s/$update-phone", patientID),
+		req:          req,
+		dst:          new(fhirModel.Task),
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*fhirModel.Task)
+			s.True(ok)
+			s.Equal(task, body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+
+	s.testProfileError(req, tm)
+}
+
+func (s *handlerTestSuite) TestConfirmUpdatePatientPhone() {
+	var isCalled bool
+
+	req := prepareConfirmReq(entity.StructureDefinitionPatientConfirmUpdatePhoneRequest)
+	patientID := fhirModel.ID("123")
+
+	s.uc.confirmUpdatePatientPhoneFunc = func(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+		isCalled = true
+
+		s.Equal(patientID, id)
+		s.Equal(req, p)
+
+		return task, nil
+	}
+
+	tm := &testModel{
+		method:       fiber.MethodPost,
+		route:        fmt.Sprintf("/Patient/%s/$confirm-phone", patientID),
+		req:          req,
+		dst:          new(fhirModel.Task),
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*fhirModel.Task)
+			s.True(ok)
+			s.Equal(task, body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+
+	s.testProfileError(req, tm)
+}
+
+func (s *handlerTestSuite) TestConfirmUpdatePatientEmail() {
+	var isCalled bool
+
+	req := prepareConfirmReq(entity.StructureDefinitionPatientConfirmUpdateEmailRequest)
+	patientID := fhirModel.ID("123")
+
+	s.uc.confirmUpdatePatientEmailFunc = func(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+		isCalled = true
+
+		s.Equal(patientID, id)
+		s.Equal(req, p)
+
+		return task, nil
+	}
+
+	tm := &testModel{
+		method:       fiber.MethodPost,
+		route:        fmt.Sprintf("/Patient/%s/$confirm-email", patientID),
+		req:          req,
+		dst:          new(fhirModel.Task),
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*fhirModel.Task)
+			s.True(ok)
+			s.Equal(task, body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+
+	s.testProfileError(req, tm)
+}
+
+func (s *handlerTestSuite) TestConfirmCreatePatientOTPAttempts() {
+	req := prepareConfirmReq(fhirModel.StructureDefinitionPatientConfirmCreateRequest)
+
+	s.uc.confirmCreatePatientFunc = func(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+		return nil, cerror.NewF(ctx, cerror.KindBadValidation, "otp code is not valid").
+			WithPayload(&entity.OTPAttempts{Count: 1, MaxCount: 3})
+	}
+
+	testByModel(s, &testModel{
+		method:       fiber.MethodPost,
+		route:        "/Patient/$confirm-request",
+		req:          req,
+		dst:          new(fhirModel.OperationOutcome),
+		expectedCode: fiber.StatusUnprocessableEntity,
+		assertFn: func(code int, resp interface{}) {
+			s.assertErr(code, resp)
+
+			body := resp.(*fhirModel.OperationOutcome)
+			s.Equal(1, len(body.Extension))
+			s.Equal(entity.StructureDefinitionOutcomeOTPRemainingAttempts, body.Extension[0].URL)
+			s.Equal(2, *body.Extension[0].ValueInteger)
+		}})
+}
+
+func (s *handlerTestSuite) TestCreatePatientRateLimited() {
+	_, req := preparePatientReq(fhirModel.StructureDefinitionPatientCreateRequest, nil)
+
+	s.uc.createPatientFunc = func(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+		s.Equal("consumer-1", ctx.Value(entity.HeaderConsumerID))
+
+		return nil, cerror.NewF(ctx, cerror.KindBadValidation, "too many otp requests").
+			WithPayload(&entity.RateLimitExceeded{RetryAfter: 1500 * time.Millisecond})
+	}
+
+	b, _ := json.Marshal(req)
+	r := httptest.NewRequest(fiber.MethodPost, "/Patient/$create-request", bytes.NewReader(b))
+	r.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	r.Header.Set(entity.HeaderConsumerID, "consumer-1")
+
+	resp, err := s.s.Fiber().Test(r)
+	s.Require().NoError(err)
+
+	defer resp.Body.Close()
+
+	s.Equal(fiber.StatusTooManyRequests, resp.StatusCode)
+	s.Equal("2", resp.Header.Get(fiber.HeaderRetryAfter))
+}
+
+func (s *handlerTestSuite) TestConsumerIDFromUntrustedNetwork() {
+	_, req := preparePatientReq(fhirModel.StructureDefinitionPatientCreateRequest, nil)
+
+	s.uc.createPatientFunc = func(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+		s.Nil(ctx.Value(entity.HeaderConsumerID))
+
+		return task, nil
+	}
+
+	b, _ := json.Marshal(req)
+	r := httptest.NewRequest(fiber.MethodPost, "/Patient/$create-request", bytes.NewReader(b))
+	r.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	r.Header.Set(entity.HeaderConsumerID, "consumer-1")
+
+	// the test requests come from 0.0.0.0 which is not the gateway network of this server
+	srv := http.NewServer(env.HTTPServer{}, env.Service{}, nil, nil, s.uc)
+
+	resp, err := srv.Fiber().Test(r)
+	s.Require().NoError(err)
+
+	defer resp.Body.Close()
+
+	s.Equal(fiber.StatusOK, resp.StatusCode)
+}
+
+// testGatewayNets trusts the address of the fiber test requests
+func testGatewayNets() []*net.IPNet {
+	_, n, _ := net.ParseCIDR("0.0.0.0/32")
+
+	return []*net.IPNet{n}
+}
+
+func (s *handlerTestSuite) TestMatchPatients() {
+	var isCalled bool
+
+	_, req := preparePatientReq(entity.StructureDefinitionPatientMatchRequest, nil)
+
+	score := 0.97
+	bundle := &fhirModel.Bundle{
+		Resource: fhirModel.Resource{ResourceType: fhirModel.ResourceBundle},
+		Type:     fhirModel.BundleTypeSearchset,
+		Entry: []*fhirModel.BundleEntry{{
+			FullURL: "Patient/123",
+			Search: &fhirModel.BundleEntrySearch{
+				Mode:  entity.SearchEntryModeMatch,
+				Score: &score,
+				Extension: []*fhirModel.Extension{{
+					URL:    entity.StructureDefinitionMatchGrade,
+					ValueX: fhirModel.ValueX{ValueCode: converto.StringPointer(entity.MatchGradeCertain)},
+				}},
+			},
+		}},
+	}
+
+	s.uc.matchPatientsFunc = func(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Bundle, error) {
+		isCalled = true
+
+		s.Equal(req.Meta, p.Meta)
+
+		return bundle, nil
+	}
+
+	tm := &testModel{
+		method:       fiber.MethodPost,
+		route:        "/Patient/$match",
+		req:          req,
+		dst:          new(fhirModel.Bundle),
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*fhirModel.Bundle)
+			s.True(ok)
+			s.Equal(bundle, body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+
+	s.testProfileError(req, tm)
+}
+
+func (s *handlerTestSuite) TestMergePatients() {
+	var isCalled bool
+
+	req := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientMergeRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "source-patient", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Patient/1"}}},
+			{Name: "target-patient", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Patient/2"}}},
+		},
+	}
+
+	s.uc.mergePatientsFunc = func(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+		isCalled = true
+
+		s.Equal(req, p)
+
+		return task, nil
+	}
+
+	tm := &testModel{
+		method:       fiber.MethodPost,
+		route:        "/Patient/$merge",
+		req:          req,
+		dst:          new(fhirModel.Task),
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*fhirModel.Task)
+			s.True(ok)
+			s.Equal(task, body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+
+	s.testProfileError(req, tm)
+}
+
+func (s *handlerTestSuite) TestUnmergePatients() {
+	var isCalled bool
+
+	req := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientUnmergeRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "merge-task", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Task/1"}}},
+		},
+	}
+
+	s.uc.unmergePatientsFunc = func(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+		isCalled = true
+
+		s.Equal(req, p)
+
+		return task, nil
+	}
+
+	tm := &testModel{
+		method:       fiber.MethodPost,
+		route:        "/Patient/$unmerge",
+		req:          req,
+		dst:          new(fhirModel.Task),
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*fhirModel.Task)
+			s.True(ok)
+			s.Equal(task, body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+
+	s.testProfileError(req, tm)
+}
+
+func (s *handlerTestSuite) TestLinkPatients() {
+	var isCalled bool
+
+	req := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientLinkRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "source-patient", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Patient/1"}}},
+			{Name: "target-patient", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Patient/2"}}},
+			{Name: "type", ValueX: fhirModel.ValueX{ValueCode: converto.StringPointer(entity.PatientLinkSeeAlso)}},
+		},
+	}
+
+	s.uc.linkPatientsFunc = func(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+		isCalled = true
+
+		s.Equal(req, p)
+
+		return task, nil
+	}
+
+	tm := &testModel{
+		method:       fiber.MethodPost,
+		route:        "/Patient/$link",
+		req:          req,
+		dst:          new(fhirModel.Task),
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*fhirModel.Task)
+			s.True(ok)
+			s.Equal(task, body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+
+	s.testProfileError(req, tm)
+}
+
+func (s *handlerTestSuite) TestUnlinkPatients() {
+	var isCalled bool
+
+	req := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientUnlinkRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "source-patient", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Patient/1"}}},
+			{Name: "target-patient", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Patient/2"}}},
+		},
+	}
+
+	s.uc.unlinkPatientsFunc = func(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+		isCalled = true
+
+		s.Equal(req, p)
+
+		return task, nil
+	}
+
+	tm := &testModel{
+		method:       fiber.MethodPost,
+		route:        "/Patient/$unlink",
+		req:          req,
+		dst:          new(fhirModel.Task),
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*fhirModel.Task)
+			s.True(ok)
+			s.Equal(task, body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+
+	s.testProfileError(req, tm)
+}
+
+func (s *handlerTestSuite) TestDeactivatePatient() {
+	var isCalled bool
+
+	patientID := fhirModel.ID("123")
+	req := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientDeactivateRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{
+				Name:   "reason",
+				ValueX: fhirModel.ValueX{ValueCode: converto.StringPointer(entity.PatientActivityReasonEnteredInError)},
+			},
+		},
+	}
+
+	s.uc.deactivatePatientFunc = func(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+		isCalled = true
+
+		s.Equal(patientID, id)
+		s.Equal(req, p)
+
+		return task, nil
+	}
+
+	tm := &testModel{
+		method:       fiber.MethodPost,
+		route:        fmt.Sprintf("/Patient/%s/$deactivate", patientID),
+		req:          req,
+		dst:          new(fhirModel.Task),
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*fhirModel.Task)
+			s.True(ok)
+			s.Equal(task, body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+
+	s.testProfileError(req, tm)
+}
+
+func (s *handlerTestSuite) TestReactivatePatient() {
+	var isCalled bool
+
+	patientID := fhirModel.ID("123")
+	req := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientReactivateRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{
+				Name:   "reason",
+				ValueX: fhirModel.ValueX{ValueCode: converto.StringPointer(entity.PatientActivityReasonEnteredInError)},
+			},
+		},
+	}
+
+	s.uc.reactivatePatientFunc = func(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+		isCalled = true
+
+		s.Equal(patientID, id)
+		s.Equal(req, p)
+
+		return task, nil
+	}
+
+	tm := &testModel{
+		method:       fiber.MethodPost,
+		route:        fmt.Sprintf("/Patient/%s/$reactivate", patientID),
+		req:          req,
+		dst:          new(fhirModel.Task),
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*fhirModel.Task)
+			s.True(ok)
+			s.Equal(task, body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+
+	s.testProfileError(req, tm)
+}
+
+func (s *handlerTestSuite) TestMarkPatientDeceased() {
+	var isCalled bool
+
+	patientID := fhirModel.ID("123")
+	deathDate := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	req := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientMarkDeceasedRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{
+				Name:   "date",
+				ValueX: fhirModel.ValueX{ValueDateTime: (*fhirModel.DateTime)(converto.TimePointer(deathDate))},
+			},
+			{
+				Name:   "source",
+				ValueX: fhirModel.ValueX{ValueCode: converto.StringPointer(entity.DeathSourceCivilRegistry)},
+			},
+		},
+	}
+
+	s.uc.markPatientDeceasedFunc = func(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+		isCalled = true
+
+		s.Equal(patientID, id)
+		s.Equal(req.Parameter[1], p.Parameter[1])
+		s.True(deathDate.Equal(p.Parameter[0].ValueDateTime.Time()))
+
+		return task, nil
+	}
+
+	tm := &testModel{
+		method:       fiber.MethodPost,
+		route:        fmt.Sprintf("/Patient/%s/$mark-deceased", patientID),
+		req:          req,
+		dst:          new(fhirModel.Task),
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*fhirModel.Task)
+			s.True(ok)
+			s.Equal(task, body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+
+	s.testProfileError(req, tm)
+}
+
+func (s *handlerTestSuite) TestSearchReviewTasks() {
+	var isCalled bool
+
+	expected := &fhirModel.Bundle{
+		Resource: fhirModel.Resource{ID: fhirModel.ID("bundle-123")},
+		Type:     fhirModel.BundleTypeSearchset,
+	}
+
+	s.uc.searchReviewTasksFunc = func(ctx context.Context, p *entity.SearchReviewTaskParams) (*fhirModel.Bundle, error) {
+		isCalled = true
+
+		s.Equal(&entity.SearchReviewTaskParams{Reason: entity.TaskReasonFraudSuspected, Count: 5, Offset: 10}, p)
+
+		return expected, nil
+	}
+
+	tm := &testModel{
+		method:       fiber.MethodGet,
+		route:        fmt.Sprintf("/Task/$review-queue?reason=%s&_count=5&_offset=10", entity.TaskReasonFraudSuspected),
+		dst:          new(fhirModel.Bundle),
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*fhirModel.Bundle)
+			s.True(ok)
+			s.Equal(expected, body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+
+	// the reviewer is not allowed
+	s.uc.searchReviewTasksFunc = func(ctx context.Context, p *entity.SearchReviewTaskParams) (*fhirModel.Bundle, error) {
+		return nil, cerror.NewF(ctx, cerror.KindFromHTTPCode(fiber.StatusForbidden), "not allowed")
+	}
+
+	tm.expectedCode = fiber.StatusForbidden
+	tm.dst = new(fhirModel.OperationOutcome)
+	tm.assertFn = s.assertErr
+
+	testByModel(s, tm)
+}
+
+func (s *handlerTestSuite) TestApproveReviewTask() {
+	var isCalled bool
+
+	req := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionTaskReviewRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "comment", ValueX: fhirModel.ValueX{ValueString: converto.StringPointer("checked in person")}},
+		},
+	}
+
+	s.uc.approveReviewTaskFunc = func(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+		isCalled = true
+
+		s.Equal(task.ID, id)
+		s.Equal(req, p)
+
+		return task, nil
+	}
+
+	tm := &testModel{
+		method:       fiber.MethodPost,
+		route:        fmt.Sprintf("/Task/%s/$approve", task.ID),
+		req:          req,
+		dst:          new(fhirModel.Task),
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*fhirModel.Task)
+			s.True(ok)
+			s.Equal(task, body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+
+	s.testProfileError(req, tm)
+}
+
+func (s *handlerTestSuite) TestRejectReviewTask() {
+	var isCalled bool
+
+	req := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionTaskReviewRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "reason", ValueX: fhirModel.ValueX{ValueString: converto.StringPointer("forged document")}},
+		},
+	}
+
+	s.uc.rejectReviewTaskFunc = func(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+		isCalled = true
+
+		s.Equal(task.ID, id)
+		s.Equal(req, p)
+
+		return task, nil
+	}
+
+	tm := &testModel{
+		method:       fiber.MethodPost,
+		route:        fmt.Sprintf("/Task/%s/$reject", task.ID),
+		req:          req,
+		dst:          new(fhirModel.Task),
+		expectedCode: fiber.StatusOK,
+		assertFn: func(code int, resp interface{}) {
+			body, ok := resp.(*fhirModel.Task)
+			s.True(ok)
+			s.Equal(task, body)
+		}}
+
+	testByModel(s, tm)
+	s.True(isCalled)
+
+	s.testProfileError(req, tm)
+}
+
 type testModel struct {
 	method       string
 	route        string
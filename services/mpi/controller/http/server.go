@@ -2,16 +2,20 @@ package http
 
 import (
 	"encoding/json"
+	"net"
 
 	"wasfaty.api/pkg/env"
 	"wasfaty.api/pkg/middleware/headers"
 
 	"wasfaty.api/pkg/http/fiber"
+	"wasfaty.api/services/mpi/entity"
 
 	gofiber "github.com/gofiber/fiber/v2"
 )
 
-func NewServer(httpCfg env.HTTPServer, srvCfg env.Service, traceCfg *env.Trace, uc UseCase) *fiber.Server {
+// NewServer creates the service server, the api consumer header is accepted from the gateway networks only
+func NewServer(
+	httpCfg env.HTTPServer, srvCfg env.Service, traceCfg *env.Trace, gatewayNets []*net.IPNet, uc UseCase) *fiber.Server {
 	s := fiber.NewServer(&fiber.ServerConfig{
 		Service: srvCfg,
 		Server:  httpCfg,
@@ -25,13 +29,61 @@ func NewServer(httpCfg env.HTTPServer, srvCfg env.Service, traceCfg *env.Trace,
 	h := newHandler(uc)
 
 	s.Fiber().Use(headers.ValidateJSONContentType(gofiber.MethodGet, gofiber.MethodDelete))
+	s.Fiber().Use(saveConsumerID(gatewayNets))
 
 	s.Fiber().Post("/Patient/$create-request", h.createPatient)
+	s.Fiber().Post("/Patient/$create-request/$validate", h.validateCreatePatient)
 	s.Fiber().Post("/Patient/$confirm-request", h.confirmCreatePatient)
+	s.Fiber().Post("/Patient/$resend-otp", h.resendOTP)
+	s.Fiber().Post("/Patient/$match", h.matchPatients)
+	s.Fiber().Post("/Patient/$merge", h.mergePatients)
+	s.Fiber().Post("/Patient/$unmerge", h.unmergePatients)
+	s.Fiber().Post("/Patient/$link", h.linkPatients)
+	s.Fiber().Post("/Patient/$unlink", h.unlinkPatients)
 	s.Fiber().Post("/Patient/:id/$update", h.updatePatient)
 	s.Fiber().Post("/Patient/:id/$update-email", h.updatePatientEmail)
+	s.Fiber().Post("/Patient/:id/$confirm-email", h.confirmUpdatePatientEmail)
 	s.Fiber().Post("/Patient/:id/$update-identity", h.updatePatientIdentity)
 	s.Fiber().Post("/Patient/:id/$confirm-identity", h.confirmUpdatePatientIdentity)
+	s.Fiber().Post("/Patient/:id/$update-phone", h.updatePatientPhone)
+	s.Fiber().Post("/Patient/:id/$deactivate", h.deactivatePatient)
+	s.Fiber().Post("/Patient/:id/$reactivate", h.reactivatePatient)
+	s.Fiber().Post("/Patient/:id/$mark-deceased", h.markPatientDeceased)
+	s.Fiber().Post("/Patient/:id/$confirm-phone", h.confirmUpdatePatientPhone)
+	s.Fiber().Get("/Patient", h.searchPatients)
+	s.Fiber().Get("/Patient/:id", h.getPatient)
+	s.Fiber().Get("/Task", h.searchTasks)
+	// the static route goes before the task id one
+	s.Fiber().Get("/Task/$review-queue", h.searchReviewTasks)
+	s.Fiber().Get("/Task/:id", h.getTask)
+	s.Fiber().Post("/Task/:id/$cancel", h.cancelTask)
+	s.Fiber().Post("/Task/:id/$approve", h.approveReviewTask)
+	s.Fiber().Post("/Task/:id/$reject", h.rejectReviewTask)
 
 	return s
 }
+
+// saveConsumerID saves the api consumer in the request context, the otp generation is limited by the consumer
+// and the reviewer and administrator operations are allowed to the configured consumers. The header is set by
+// the api gateway after the authentication, so it is ignored on the requests which do not come from the gateway
+// networks and the gateway must overwrite or strip the header sent by the clients. Without the gateway networks
+// no consumer is saved and the reviewer and administrator operations are forbidden
+func saveConsumerID(gatewayNets []*net.IPNet) gofiber.Handler {
+	return func(ctx *gofiber.Ctx) error {
+		if v := ctx.Get(entity.HeaderConsumerID); v != "" && containsIP(gatewayNets, ctx.Context().RemoteIP()) {
+			ctx.Context().SetUserValue(entity.HeaderConsumerID, v)
+		}
+
+		return ctx.Next()
+	}
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
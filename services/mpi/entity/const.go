@@ -1,6 +1,8 @@
 package entity
 
 import (
+	"time"
+
 	"wasfaty.api/pkg/fhir/model"
 )
 
@@ -11,6 +13,197 @@ const (
 	TaskBusinessStatusOTPCodeSent                   = "OTP code sent"
 	TaskBusinessStatusPatientIdentityUpdated        = "Confirm Updating Identity & save Parameters"
 	TaskBusinessStatusConfirmPatientIdentityUpdated = "Patient Identity Updated"
+	TaskBusinessStatusCanceledByRequest             = "Canceled by request"
+	TaskBusinessStatusPatientPhoneUpdated           = "Patient Phone Updated"
+	TaskBusinessStatusPatientEmailUpdated           = "Patient Email Updated"
+	TaskBusinessStatusOTPAttemptsExhausted          = "OTP attempts exhausted"
+	TaskBusinessStatusExpired                       = "Expired"
+	TaskBusinessStatusPossibleDuplicate             = "Possible duplicate review"
+	TaskBusinessStatusFraudReview                   = "Fraud review"
+	TaskBusinessStatusRegistryMismatchReview        = "Registry mismatch review"
+	TaskBusinessStatusRejectedByReviewer            = "Rejected by reviewer"
+	TaskBusinessStatusPatientsMerged                = "Patients Merged"
+	TaskBusinessStatusPatientsUnmerged              = "Patients Unmerged"
+	TaskBusinessStatusPatientsLinked                = "Patients Linked"
+	TaskBusinessStatusPatientsUnlinked              = "Patients Unlinked"
+	TaskBusinessStatusPatientDeactivated            = "Patient Deactivated"
+	TaskBusinessStatusPatientReactivated            = "Patient Reactivated"
+	TaskBusinessStatusPatientDeceased               = "Patient Deceased"
+	TaskBusinessStatusCanceledPatientDeceased       = "Canceled as patient deceased"
+	ContactPointVerificationStatusPending           = "pending"
+	OTPProcessIDOldPhoneSuffix                      = "-old-phone"
+)
+
+const (
+	structureDefinitionBaseURL = "http://ksa-ehealth.sa/fhir/StructureDefinition/"
+
+	StructureDefinitionTaskCancelRequest                = structureDefinitionBaseURL + "ksa-ehealth-parameters-task-cancel-request"
+	StructureDefinitionTaskReviewRequest                = structureDefinitionBaseURL + "ksa-ehealth-parameters-task-review-request"
+	StructureDefinitionPatientResendOTPRequest          = structureDefinitionBaseURL + "ksa-ehealth-parameters-patient-resend-otp-request"
+	StructureDefinitionPatientMatchRequest              = structureDefinitionBaseURL + "ksa-ehealth-parameters-patient-match-request"
+	StructureDefinitionPatientMergeRequest              = structureDefinitionBaseURL + "ksa-ehealth-parameters-patient-merge-request"
+	StructureDefinitionTaskPatientMerge                 = structureDefinitionBaseURL + "ksa-ehealth-task-patient-merge"
+	StructureDefinitionPatientUnmergeRequest            = structureDefinitionBaseURL + "ksa-ehealth-parameters-patient-unmerge-request"
+	StructureDefinitionTaskPatientUnmerge               = structureDefinitionBaseURL + "ksa-ehealth-task-patient-unmerge"
+	StructureDefinitionPatientLinkRequest               = structureDefinitionBaseURL + "ksa-ehealth-parameters-patient-link-request"
+	StructureDefinitionPatientUnlinkRequest             = structureDefinitionBaseURL + "ksa-ehealth-parameters-patient-unlink-request"
+	StructureDefinitionTaskPatientLink                  = structureDefinitionBaseURL + "ksa-ehealth-task-patient-link"
+	StructureDefinitionTaskPatientUnlink                = structureDefinitionBaseURL + "ksa-ehealth-task-patient-unlink"
+	StructureDefinitionPatientDeactivateRequest         = structureDefinitionBaseURL + "ksa-ehealth-parameters-patient-deactivate-request"
+	StructureDefinitionPatientReactivateRequest         = structureDefinitionBaseURL + "ksa-ehealth-parameters-patient-reactivate-request"
+	StructureDefinitionTaskPatientDeactivate            = structureDefinitionBaseURL + "ksa-ehealth-task-patient-deactivate"
+	StructureDefinitionTaskPatientReactivate            = structureDefinitionBaseURL + "ksa-ehealth-task-patient-reactivate"
+	StructureDefinitionPatientMarkDeceasedRequest       = structureDefinitionBaseURL + "ksa-ehealth-parameters-patient-mark-deceased-request"
+	StructureDefinitionTaskPatientMarkDeceased          = structureDefinitionBaseURL + "ksa-ehealth-task-patient-mark-deceased"
+	StructureDefinitionTaskOTPResent                    = structureDefinitionBaseURL + "ksa-ehealth-task-otp-resent"
+	StructureDefinitionPatientUpdatePhoneRequest        = structureDefinitionBaseURL + "ksa-ehealth-parameters-patient-update-phone"
+	StructureDefinitionPatientConfirmUpdatePhoneRequest = structureDefinitionBaseURL + "ksa-ehealth-parameters-patient-confirm-update-phone"
+	StructureDefinitionTaskPatientUpdatePhone           = structureDefinitionBaseURL + "ksa-ehealth-task-patient-update-phone"
+	StructureDefinitionPatientConfirmUpdateEmailRequest = structureDefinitionBaseURL + "ksa-ehealth-parameters-patient-confirm-update-email"
+	StructureDefinitionContactPointVerificationStatus   = structureDefinitionBaseURL + "ksa-ehealth-contactpoint-verification-status"
+	StructureDefinitionTaskOTPDelivery                  = structureDefinitionBaseURL + "ksa-ehealth-task-otp-delivery"
+	StructureDefinitionTaskReview                       = structureDefinitionBaseURL + "ksa-ehealth-task-review"
+	StructureDefinitionTaskAdministration               = structureDefinitionBaseURL + "ksa-ehealth-task-administration"
+	StructureDefinitionOutcomeOTPRemainingAttempts      = structureDefinitionBaseURL + "ksa-ehealth-operationoutcome-otp-remaining-attempts"
+)
+
+const (
+	OTPResendCooldown = time.Minute
+	OTPResendMaxCount = 3
+)
+
+const (
+	LanguageArabic  = "ar"
+	LanguageEnglish = "en"
+
+	// the sms submission statuses reported by the senders, the delivery to the handset is not tracked
+	SMSStatusQueued = "queued"
+	SMSStatusSent   = "sent"
+	SMSStatusFailed = "failed"
+)
+
+// OTPMessageTemplates returns the otp sms texts by the language, the code is the only template argument
+func OTPMessageTemplates() map[string]string {
+	return map[string]string{
+		LanguageArabic:  "رمز التحقق الخاص بك هو %s. لا تشاركه مع أي شخص.",
+		LanguageEnglish: "Your verification code is %s. Do not share it with anyone.",
+	}
+}
+
+const (
+	SummaryTrue  = "true"
+	SummaryFalse = "false"
+	SummaryText  = "text"
+	SummaryData  = "data"
+
+	MetaTagSystemObservationValue = "http://terminology.hl7.org/CodeSystem/v3-ObservationValue"
+	MetaTagSubsetted              = "SUBSETTED"
+)
+
+const (
+	OutcomeIssueSeverityInformation = "information"
+	OutcomeIssueSeverityWarning     = "warning"
+	OutcomeIssueCodeInformational   = "informational"
+	OutcomeIssueCodeBusinessRule    = "business-rule"
+	OutcomeIssueCodeDuplicate       = "duplicate"
+)
+
+// the patient $match grades, the candidates graded as certainly-not are not returned
+const (
+	StructureDefinitionMatchGrade = "http://hl7.org/fhir/StructureDefinition/match-grade"
+	MatchGradeCertain             = "certain"
+	MatchGradeProbable            = "probable"
+	MatchGradePossible            = "possible"
+	MatchGradeCertainlyNot        = "certainly-not"
+	PatientMatchDefaultCount      = 10
+	// the candidates are searched by every identifier, phone and the birth date with the limit
+	PatientMatchCandidatesCount = 100
+)
+
+// the policies applied when the created patient is probably a duplicate of the registered one, the task refers
+// the candidates in the output and the hold policy also stops the request until the manual review
+const (
+	PossibleDuplicatePolicyOutput = "output"
+	PossibleDuplicatePolicyHold   = "hold"
+	PossibleDuplicatePolicyOff    = "off"
+)
+
+// the reasons the task is flagged or held for
+const (
+	CodeSystemTaskReason        = "http://ksa-ehealth.sa/fhir/CodeSystem/ksa-ehealth-task-reason"
+	TaskReasonPossibleDuplicate = "possible-duplicate"
+	TaskReasonFraudSuspected    = "fraud-suspected"
+	TaskReasonRegistryMismatch  = "registry-mismatch"
+)
+
+// the reasons the patient is deactivated or reactivated for
+const (
+	CodeSystemPatientActivityReason     = "http://ksa-ehealth.sa/fhir/CodeSystem/ksa-ehealth-patient-activity-reason"
+	PatientActivityReasonEnteredInError = "entered-in-error"
+	PatientActivityReasonDuplicate      = "duplicate"
+	PatientActivityReasonPatientRequest = "patient-request"
+	PatientActivityReasonOther          = "other"
+)
+
+// the sources of the patient death record, the civil registry deaths are verified by the document registry
+const (
+	CodeSystemDeathSource         = "http://ksa-ehealth.sa/fhir/CodeSystem/ksa-ehealth-death-source"
+	DeathSourceCivilRegistry      = "civil-registry"
+	DeathSourceHealthcareFacility = "healthcare-facility"
+	DeathSourceOther              = "other"
+)
+
+// the policies applied when too many patients share the phone of the confirmed patient
+const (
+	FraudPolicyReject = "reject"
+	FraudPolicyHold   = "hold"
+)
+
+// the decisions of the held task review
+const (
+	ReviewDecisionApproved = "approved"
+	ReviewDecisionRejected = "rejected"
+	ReviewTaskDefaultCount = 20
+	ReviewTaskMaxCount     = 100
+)
+
+// the survivorship rules of the merged patient values: the target values are kept, the source values missing
+// in the target are added or the source values replace the target ones of the same kind
+const (
+	SurvivorshipTarget = "target"
+	SurvivorshipUnion  = "union"
+	SurvivorshipSource = "source"
+
+	PatientLinkReplacedBy = "replaced-by"
+	PatientLinkReplaces   = "replaces"
+	PatientLinkSeeAlso    = "seealso"
+	PatientLinkRefer      = "refer"
+)
+
+// PatientLinkTypes returns the link types managed by the $link operation, the replaces links are set by the $merge only
+func PatientLinkTypes() []string {
+	return []string{PatientLinkSeeAlso, PatientLinkRefer}
+}
+
+// HeaderConsumerID is the api consumer set by the api gateway, it is saved in the request context with the same key
+const HeaderConsumerID = "X-Consumer-ID"
+
+// the policies applied when the patient demographics do not match the civil registry record
+const (
+	DemographicsPolicyReject      = "reject"
+	DemographicsPolicyWarn        = "warn"
+	DemographicsPolicyAutocorrect = "autocorrect"
+	DemographicsPolicyHold        = "hold"
+)
+
+const (
+	PatientSearchDefaultCount = 10
+	PatientSearchMaxCount     = 50
+	PatientSearchMaxResults   = 100
+	SearchEntryModeMatch      = "match"
+	BundleLinkSelf            = "self"
+	BundleLinkNext            = "next"
+	BundleLinkPrevious        = "previous"
 )
 
 func CreatePatientIdentPriority() []string {
@@ -66,6 +259,127 @@ func CreatePatientForbiddenParams() []string {
 	}
 }
 
+// ExtDocRegistryIdentTypes returns the identifier codes checked by the external document registry
+func ExtDocRegistryIdentTypes() []string {
+	return []string{
+		model.IdentNationalID,
+		model.IdentPermanentResidentCardNumber,
+		model.IdentBorderNumber,
+	}
+}
+
+// PatientSummaryElements returns the Patient elements marked as "summary" in the FHIR specification
+func PatientSummaryElements() []string {
+	return []string{
+		"identifier",
+		"active",
+		"name",
+		"telecom",
+		"gender",
+		"birthDate",
+		"deceasedBoolean",
+		"deceasedDateTime",
+		"address",
+		"managingOrganization",
+		"link",
+	}
+}
+
+// ResourceMandatoryElements returns the elements which are never removed from a subsetted resource
+func ResourceMandatoryElements() []string {
+	return []string{
+		"resourceType",
+		"id",
+		"meta",
+	}
+}
+
+// MPITaskProfiles returns the profiles of the Tasks created by MPI operations
+func MPITaskProfiles() []string {
+	return []string{
+		model.StructureDefinitionTaskPatientCreate,
+		model.StructureDefinitionTaskPatientUpdate,
+		model.StructureDefinitionTaskPatientUpdateEmail,
+		model.StructureDefinitionTaskPatientUpdateIdentity,
+		StructureDefinitionTaskPatientUpdatePhone,
+		StructureDefinitionTaskPatientMerge,
+		StructureDefinitionTaskPatientUnmerge,
+		StructureDefinitionTaskPatientLink,
+		StructureDefinitionTaskPatientUnlink,
+		StructureDefinitionTaskPatientDeactivate,
+		StructureDefinitionTaskPatientReactivate,
+		StructureDefinitionTaskPatientMarkDeceased,
+	}
+}
+
+// PendingTaskProfiles returns the profiles of the Tasks which wait for an OTP confirmation
+func PendingTaskProfiles() []string {
+	return []string{
+		model.StructureDefinitionTaskPatientCreate,
+		model.StructureDefinitionTaskPatientUpdateIdentity,
+		StructureDefinitionTaskPatientUpdatePhone,
+		model.StructureDefinitionTaskPatientUpdateEmail,
+	}
+}
+
+func TaskStatusList() []string {
+	return []string{
+		model.TaskStatusInProgress,
+		model.TaskStatusCompleted,
+		model.TaskStatusCanceled,
+		model.TaskStatusRejected,
+		model.TaskStatusOnHold,
+	}
+}
+
+// TaskReviewReasons returns the reasons the patient request is held for the manual review
+func TaskReviewReasons() []string {
+	return []string{
+		TaskReasonPossibleDuplicate,
+		TaskReasonFraudSuspected,
+		TaskReasonRegistryMismatch,
+	}
+}
+
+// ReviewTaskProfiles returns the profiles of the Tasks which could be held for the manual review
+func ReviewTaskProfiles() []string {
+	return []string{
+		model.StructureDefinitionTaskPatientCreate,
+		model.StructureDefinitionTaskPatientUpdateIdentity,
+		StructureDefinitionTaskPatientUpdatePhone,
+	}
+}
+
+// PatientActivityReasons returns the reason codes accepted by the patient $deactivate and $reactivate
+func PatientActivityReasons() []string {
+	return []string{
+		PatientActivityReasonEnteredInError,
+		PatientActivityReasonDuplicate,
+		PatientActivityReasonPatientRequest,
+		PatientActivityReasonOther,
+	}
+}
+
+// DemographicsPolicies returns the supported policies of the civil registry demographics mismatches
+func DemographicsPolicies() []string {
+	return []string{
+		DemographicsPolicyReject,
+		DemographicsPolicyWarn,
+		DemographicsPolicyAutocorrect,
+		DemographicsPolicyHold,
+	}
+}
+
+// PossibleDuplicatePolicies returns the supported policies of the possible duplicates on create
+func PossibleDuplicatePolicies() []string {
+	return []string{PossibleDuplicatePolicyOutput, PossibleDuplicatePolicyHold, PossibleDuplicatePolicyOff}
+}
+
+// DeathSources returns the death sources accepted by the patient $mark-deceased
+func DeathSources() []string {
+	return []string{DeathSourceCivilRegistry, DeathSourceHealthcareFacility, DeathSourceOther}
+}
+
 func IdentifierCodeForSANationality() []string {
 	return []string{
 		model.IdentNationalID,
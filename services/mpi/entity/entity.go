@@ -7,8 +7,29 @@ import (
 )
 
 type SearchTaskParams struct {
-	Telecom    *SearchTaskByTelecomParams
-	Identifier *SearchTaskByIdentifierParams
+	Telecom        *SearchTaskByTelecomParams
+	Identifier     *SearchTaskByIdentifierParams
+	PatientID      fhirModel.ID
+	AuthoredBefore *time.Time
+	// StatusReason is the token of the task status reason such as system|code, the system| matches any code
+	StatusReason string
+	Status       string
+	Profiles     []string
+	// Sort is the search parameter the tasks are sorted by, the stable order is required by the Offset paging
+	Sort   string
+	Count  int
+	Offset int
+}
+
+type SearchReviewTaskParams struct {
+	Reason string
+	Count  int
+	Offset int
+}
+
+type SweepTasksParams struct {
+	TTL       time.Duration
+	BatchSize int
 }
 
 type SearchTaskByTelecomParams struct {
@@ -37,6 +58,52 @@ type SearchTaskByIdentifierParams struct {
 type SearchPatientParams struct {
 	Phone      *SearchPatientByPhoneParams
 	Identifier *SearchPatientByIdentifierParams
+	// BirthDate could be the year only, it matches all the dates of the year then
+	BirthDate string
+	Family    string
+	Given     string
+	Count     int
+	Offset    int
+}
+
+// MatchWeights are the weights of the compared patient fields in the $match score
+type MatchWeights struct {
+	Name       float64
+	BirthDate  float64
+	Gender     float64
+	Phone      float64
+	Identifier float64
+}
+
+// PatientMatchPolicy scores the $match candidates, the score is graded by the minimal scores of the grades
+type PatientMatchPolicy struct {
+	Weights  MatchWeights
+	Certain  float64
+	Probable float64
+	Possible float64
+}
+
+func DefaultPatientMatchPolicy() *PatientMatchPolicy {
+	return &PatientMatchPolicy{
+		Weights:  MatchWeights{Name: 0.35, BirthDate: 0.25, Gender: 0.1, Phone: 0.15, Identifier: 0.15},
+		Certain:  0.95,
+		Probable: 0.85,
+		Possible: 0.7,
+	}
+}
+
+// Grade returns the match grade of the score
+func (p *PatientMatchPolicy) Grade(score float64) string {
+	switch {
+	case score >= p.Certain:
+		return MatchGradeCertain
+	case score >= p.Probable:
+		return MatchGradeProbable
+	case score >= p.Possible:
+		return MatchGradePossible
+	default:
+		return MatchGradeCertainlyNot
+	}
 }
 
 type SearchPatientByPhoneParams struct {
@@ -49,13 +116,44 @@ type SearchPatientByIdentifierParams struct {
 	Type  string
 }
 
+type GetPatientParams struct {
+	Elements []string
+	Summary  string
+}
+
 type UpdatePatientIdentityParameters struct {
 	ConfirmationMethod string
 	Patient            *fhirModel.Patient
+	PatientParamIndex  int
+}
+
+type UpdatePatientPhoneParameters struct {
+	Phone           string
+	ConfirmOldPhone bool
 }
 
 type ExtDocRegistrySearchResult struct {
-	IsValid bool
+	IsValid     bool
+	GivenName   string
+	FamilyName  string
+	BirthDate   string
+	Gender      string
+	Nationality string
+	Status      string
+	Deceased    bool
+	DeathDate   string
+}
+
+// PatientPairParameters are the source and the target patients of the $merge and the $link operations
+type PatientPairParameters struct {
+	SourceID fhirModel.ID
+	TargetID fhirModel.ID
+}
+
+// MergeSurvivorship sets the survivorship rules of the identifiers and the telecom copied from the merged patient
+type MergeSurvivorship struct {
+	Identifier string
+	Telecom    string
 }
 
 type ConfirmRequestParameters struct {
@@ -71,6 +169,58 @@ type OTP struct {
 	Value            string    `json:"value,omitempty"`
 }
 
+// RateLimit allows Count events in the sliding Window
+type RateLimit struct {
+	Count  int
+	Window time.Duration
+}
+
+// OTPRateLimits limits the otp generation by the phone or email, by the patient request and by the api consumer,
+// the zero count disables the limit
+type OTPRateLimits struct {
+	Value    RateLimit
+	Process  RateLimit
+	Consumer RateLimit
+}
+
+// RateLimitKey is the limited key such as the phone with its limit
+type RateLimitKey struct {
+	Key   string
+	Limit RateLimit
+}
+
+// RateLimitExceeded is the error payload telling when the limited request can be retried
+type RateLimitExceeded struct {
+	RetryAfter time.Duration
+}
+
+// OTPAttempts is the otp validation attempts state reported by the otp service on a wrong code
+type OTPAttempts struct {
+	Count    int
+	MaxCount int
+}
+
+func (a *OTPAttempts) Remaining() int {
+	if r := a.MaxCount - a.Count; r > 0 {
+		return r
+	}
+
+	return 0
+}
+
+type SMSMessage struct {
+	To       string
+	Text     string
+	Language string
+}
+
+type SMSDelivery struct {
+	MessageID string
+	// SubmissionStatus is the status of the message accepted by the sender, it is not the handset delivery report
+	SubmissionStatus string
+	SentAt           time.Time
+}
+
 type ValidateOTPParams struct {
 	Code      string `json:"code,omitempty"`
 	Value     string `json:"value,omitempty"`
@@ -0,0 +1,175 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/pkg/converto"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
+)
+
+// DeactivatePatient sets the active patient inactive, so it is not updated and not found as a duplicate anymore
+func (uc *UseCase) DeactivatePatient(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (
+	*fhirModel.Task, error) {
+	if _, err := uc.fhir.ValidateParameters(ctx, p); err != nil {
+		return nil, err
+	}
+
+	administrator, err := uc.authorizeAdministrator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reason, err := uc.extractActivityReason(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	patient, err := uc.fhir.GetPatientByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.validatePatientByInternalRules(ctx, patient); err != nil {
+		return nil, err
+	}
+
+	patient.Active = converto.BoolPointer(false)
+
+	task := prepareActivityPatientTask(p, id, entity.StructureDefinitionTaskPatientDeactivate,
+		entity.TaskBusinessStatusPatientDeactivated, reason)
+	addTaskAdministrator(task, administrator)
+
+	_, err = uc.fhir.CreateBundle(ctx, prepareUpdatePatientBundle(p, task, patient))
+	if err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// ReactivatePatient sets the inactive patient active again, the patient is checked for the duplicates first
+// because the same person could be registered while it was inactive
+func (uc *UseCase) ReactivatePatient(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (
+	*fhirModel.Task, error) {
+	if _, err := uc.fhir.ValidateParameters(ctx, p); err != nil {
+		return nil, err
+	}
+
+	administrator, err := uc.authorizeAdministrator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reason, err := uc.extractActivityReason(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	patient, err := uc.fhir.GetPatientByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.validateReactivatedPatient(ctx, patient); err != nil {
+		return nil, err
+	}
+
+	if err := uc.validatePatientDupls(ctx, patient); err != nil {
+		return nil, err
+	}
+
+	patient.Active = converto.BoolPointer(true)
+
+	task := prepareActivityPatientTask(p, id, entity.StructureDefinitionTaskPatientReactivate,
+		entity.TaskBusinessStatusPatientReactivated, reason)
+	addTaskAdministrator(task, administrator)
+
+	_, err = uc.fhir.CreateBundle(ctx, prepareUpdatePatientBundle(p, task, patient))
+	if err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+func (uc *UseCase) extractActivityReason(ctx context.Context, p *fhirModel.Parameters) (string, error) {
+	for _, param := range p.Parameter {
+		if param.Name != "reason" {
+			continue
+		}
+
+		reason := converto.StringValue(param.ValueCode)
+		if !contains(entity.PatientActivityReasons(), reason) {
+			return "", cerror.NewValidationError(
+				ctx, map[string]string{"Parameters.parameter": "unsupported reason parameter value"}).LogError()
+		}
+
+		return reason, nil
+	}
+
+	return "", cerror.NewValidationError(
+		ctx, map[string]string{"Parameters.parameter": "missing reason parameter"}).LogError()
+}
+
+// validateReactivatedPatient checks that the patient was deactivated, the merged one is restored by the $unmerge
+func (uc *UseCase) validateReactivatedPatient(ctx context.Context, p *fhirModel.Patient) error {
+	if converto.BoolValue(p.Active) {
+		return cerror.NewF(ctx, cerror.KindBadValidation, "patient has active status").LogError()
+	}
+
+	if isPatientDeceased(p) {
+		return cerror.NewF(ctx, cerror.KindBadValidation, "patient has deceased status").LogError()
+	}
+
+	for _, l := range p.Link {
+		if l.Type == entity.PatientLinkReplacedBy {
+			return cerror.NewF(ctx, cerror.KindBadValidation, "patient is merged into another one").LogError()
+		}
+	}
+
+	return nil
+}
+
+func prepareActivityPatientTask(
+	p *fhirModel.Parameters, patientID fhirModel.ID, profile, businessStatus, reason string) *fhirModel.Task {
+	return &fhirModel.Task{
+		DomainResource: fhirModel.DomainResource{
+			Resource: fhirModel.Resource{
+				ID:           fhirModel.ID(uuid.NewV4().String()),
+				ResourceType: fhirModel.ResourceTask,
+				Meta:         &fhirModel.Meta{Profile: []string{profile}},
+			},
+		},
+		Status: fhirModel.TaskStatusCompleted,
+		StatusReason: &fhirModel.CodeableConcept{
+			Codings: []*fhirModel.Coding{{System: entity.CodeSystemPatientActivityReason, Code: reason}},
+		},
+		BusinessStatus: &fhirModel.CodeableConcept{Text: businessStatus},
+		Intent:         fhirModel.TaskIntentOrder,
+		For:            patientReference(patientID),
+		AuthoredOn:     (*fhirModel.DateTime)(converto.TimePointer(time.Now().UTC())),
+		Input: []*fhirModel.TaskInput{
+			{
+				Type: &fhirModel.CodeableConcept{
+					Codings: []*fhirModel.Coding{
+						{
+							Code:   fhirModel.ResourceParameters,
+							System: fhirModel.CodingSystemResourceTypes,
+						},
+					},
+				},
+				ValueX: fhirModel.ValueX{
+					ValueReference: &fhirModel.Reference{
+						Reference: fmt.Sprintf("%s/%s", fhirModel.ResourceParameters, p.ID),
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"context"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/pkg/converto"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
+)
+
+func (uc *UseCase) CancelTask(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+	if _, err := uc.fhir.ValidateParameters(ctx, p); err != nil {
+		return nil, err
+	}
+
+	reason, err := uc.extractCancelTaskReason(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := uc.getTaskByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasTaskProfile(t, entity.PendingTaskProfiles()...) {
+		return nil, cerror.NewF(ctx, cerror.KindBadValidation, "such patient request can not be canceled").LogError()
+	}
+
+	err = uc.validateConfirmRequestTask(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Status = fhirModel.TaskStatusCanceled
+	t.BusinessStatus = &fhirModel.CodeableConcept{Text: entity.TaskBusinessStatusCanceledByRequest}
+	t.StatusReason = &fhirModel.CodeableConcept{Text: reason}
+
+	if err := uc.saveTaskBundle(ctx, t, p); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (uc *UseCase) extractCancelTaskReason(ctx context.Context, p *fhirModel.Parameters) (string, error) {
+	for _, param := range p.Parameter {
+		if param.Name == "reason" {
+			if reason := converto.StringValue(param.ValueString); reason != "" {
+				return reason, nil
+			}
+		}
+	}
+
+	return "", cerror.NewValidationError(
+		ctx, map[string]string{"Parameters.parameter": "missing reason parameter"}).LogError()
+}
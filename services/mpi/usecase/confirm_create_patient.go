@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -50,7 +51,7 @@ func (uc *UseCase) ConfirmCreatePatient(ctx context.Context, p *fhirModel.Parame
 
 	err = uc.validateOTP(ctx, op.TaskID, op.OTPCode, telecom.Value)
 	if err != nil {
-		return nil, err
+		return nil, uc.rejectOTPExhaustedTask(ctx, t, p, err)
 	}
 
 	err = uc.validatePatientDupls(ctx, patient)
@@ -62,11 +63,30 @@ func (uc *UseCase) ConfirmCreatePatient(ctx context.Context, p *fhirModel.Parame
 		return nil, err
 	}
 
-	err = uc.validatePatientFrauds(ctx, patient, telecom)
+	suspected, err := uc.isFraudSuspected(ctx, patient, telecom)
 	if err != nil {
 		return nil, err
 	}
 
+	if suspected {
+		if uc.fraudPolicy != entity.FraudPolicyHold {
+			return nil, errTooManyPatientsWithPhone(ctx)
+		}
+
+		markTaskForReview(t, entity.TaskReasonFraudSuspected)
+	}
+
+	// the otp is already confirmed, so the approved request creates the patient without a new otp
+	if reason := taskHoldReason(t); reason != "" {
+		holdTask(t, reason, reviewBusinessStatus(reason))
+
+		if err := uc.saveTaskBundle(ctx, t, p); err != nil {
+			return nil, err
+		}
+
+		return t, nil
+	}
+
 	uc.updateConfirmCreatePatientTask(t, patient, p)
 
 	_, err = uc.saveConfirmCreatePatientBundle(ctx, t, patient, p)
@@ -84,6 +104,7 @@ func (uc *UseCase) updateConfirmCreatePatientTask(
 	otpParams *fhirModel.Parameters) {
 	t.Status = fhirModel.TaskStatusCompleted
 	t.BusinessStatus = &fhirModel.CodeableConcept{Text: entity.TaskBusinessStatusPatientCreated}
+	t.For = patientReference(patient.ID)
 	t.Input = append(t.Input, &fhirModel.TaskInput{
 		Type: &fhirModel.CodeableConcept{
 			Codings: []*fhirModel.Coding{
@@ -159,6 +180,12 @@ func (uc *UseCase) saveConfirmCreatePatientBundle(
 
 func (uc *UseCase) rejectTask(ctx context.Context, t *fhirModel.Task, p *fhirModel.Parameters) error {
 	t.Status = fhirModel.TaskStatusRejected
+
+	return uc.saveTaskBundle(ctx, t, p)
+}
+
+// saveTaskBundle saves the operation parameters and updates the task referencing them
+func (uc *UseCase) saveTaskBundle(ctx context.Context, t *fhirModel.Task, p *fhirModel.Parameters) error {
 	t.Input = append(t.Input, &fhirModel.TaskInput{
 		Type: &fhirModel.CodeableConcept{
 			Codings: []*fhirModel.Coding{
@@ -199,11 +226,22 @@ func (uc *UseCase) rejectTask(ctx context.Context, t *fhirModel.Task, p *fhirMod
 		},
 	}
 
-	_, err := uc.fhir.CreateBundle(ctx, b)
+	patientEntry, err := uc.endedEmailTaskPatientEntry(ctx, t)
+	if err != nil {
+		return err
+	}
+
+	if patientEntry != nil {
+		b.Entry = append(b.Entry, patientEntry)
+	}
+
+	_, err = uc.fhir.CreateBundle(ctx, b)
 
 	return err
 }
 
+const errMsgPatientExists = "such person already exists"
+
 func (uc *UseCase) validatePatientDupls(ctx context.Context, p *fhirModel.Patient) error {
 	patients, err := uc.searchPatientsByIdent(ctx, p.Identifier...)
 	if err != nil {
@@ -211,23 +249,23 @@ func (uc *UseCase) validatePatientDupls(ctx context.Context, p *fhirModel.Patien
 	}
 
 	if len(patients) > 0 {
-		return cerror.NewF(ctx, cerror.KindBadValidation, "such person already exists").LogError()
+		return cerror.NewF(ctx, cerror.KindBadValidation, errMsgPatientExists).LogError()
 	}
 
 	return nil
 }
 
-func (uc *UseCase) validatePatientFrauds(ctx context.Context, p *fhirModel.Patient, t *fhirModel.ContactPoint) error {
+func (uc *UseCase) isFraudSuspected(ctx context.Context, p *fhirModel.Patient, t *fhirModel.ContactPoint) (bool, error) {
 	patients, err := uc.searchPatientsByPhone(ctx, p, t)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	if len(patients) >= entity.MaxPatientsWithSamePhone {
-		return cerror.NewF(ctx, cerror.KindBadValidation, "too many persons with same phone").LogError()
-	}
+	return len(patients) >= entity.MaxPatientsWithSamePhone, nil
+}
 
-	return nil
+func errTooManyPatientsWithPhone(ctx context.Context) error {
+	return cerror.NewF(ctx, cerror.KindBadValidation, "too many persons with same phone").LogError()
 }
 
 func (uc *UseCase) searchPatientsByPhone(ctx context.Context, p *fhirModel.Patient, t *fhirModel.ContactPoint) (
@@ -284,13 +322,49 @@ func (uc *UseCase) validateOTP(ctx context.Context, taskID fhirModel.ID, otp, ph
 		ProcessID: string(taskID),
 	})
 
-	if err != nil && cerror.ErrKind(err) == cerror.KindNotExist {
+	if err == nil {
+		return nil
+	}
+
+	if a, ok := otpAttempts(err); ok {
+		if a.Remaining() == 0 {
+			return cerror.NewF(ctx, cerror.KindBadValidation, "otp attempts for such patient request are exhausted").
+				WithPayload(a).LogError()
+		}
+
+		return cerror.NewF(ctx, cerror.KindBadValidation, "otp code is not valid").WithPayload(a).LogError()
+	}
+
+	if cerror.ErrKind(err) == cerror.KindNotExist {
 		return cerror.NewF(ctx, cerror.KindNotExist, "otp for such patient request does not exist")
 	}
 
 	return err
 }
 
+// rejectOTPExhaustedTask rejects the task when the otp validation error reports that the attempts are exhausted,
+// the validation error is returned as is
+func (uc *UseCase) rejectOTPExhaustedTask(
+	ctx context.Context, t *fhirModel.Task, p *fhirModel.Parameters, otpErr error) error {
+	if a, ok := otpAttempts(otpErr); ok && a.Remaining() == 0 {
+		t.BusinessStatus = &fhirModel.CodeableConcept{Text: entity.TaskBusinessStatusOTPAttemptsExhausted}
+		_ = uc.rejectTask(ctx, t, p)
+	}
+
+	return otpErr
+}
+
+func otpAttempts(err error) (*entity.OTPAttempts, bool) {
+	var cErr *cerror.CError
+	if !errors.As(err, &cErr) {
+		return nil, false
+	}
+
+	a, ok := cErr.Payload().(*entity.OTPAttempts)
+
+	return a, ok
+}
+
 func (uc *UseCase) extractTelecomFromCreatePatientParams(ctx context.Context, p *fhirModel.Patient) (
 	*fhirModel.ContactPoint, error) {
 	for _, telecom := range p.Telecom {
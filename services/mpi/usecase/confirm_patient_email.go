@@ -0,0 +1,99 @@
+package usecase
+
+import (
+	"context"
+
+	"wasfaty.api/pkg/cerror"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
+)
+
+func (uc *UseCase) ConfirmUpdatePatientEmail(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (
+	*fhirModel.Task, error) {
+	if _, err := uc.fhir.ValidateParameters(ctx, p); err != nil {
+		return nil, err
+	}
+
+	op, err := uc.extractConfirmRequestParams(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := uc.getTaskByID(ctx, op.TaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasTaskProfile(t, fhirModel.StructureDefinitionTaskPatientUpdateEmail) ||
+		t.For == nil || t.For.Reference != patientReference(id).Reference {
+		return nil, cerror.NewF(ctx, cerror.KindNotExist, "such patient request does not exist")
+	}
+
+	err = uc.validateConfirmRequestTask(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+
+	dbPatient, err := uc.fhir.GetPatientByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = uc.validatePatientByInternalRules(ctx, dbPatient)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending *fhirModel.ContactPoint
+
+	for _, telecom := range dbPatient.Telecom {
+		if telecom.System == fhirModel.TelecomSystemEmail && isPendingContactPoint(telecom) {
+			pending = telecom
+			break
+		}
+	}
+
+	if pending == nil {
+		return nil, cerror.NewF(ctx, cerror.KindBadValidation, "patient has no email to confirm").LogError()
+	}
+
+	err = uc.validateOTP(ctx, op.TaskID, op.OTPCode, pending.Value)
+	if err != nil {
+		return nil, uc.rejectOTPExhaustedTask(ctx, t, p, err)
+	}
+
+	confirmPatientPendingEmail(dbPatient, pending)
+
+	uc.updateConfirmUpdatePatientTask(t, entity.TaskBusinessStatusPatientEmailUpdated, dbPatient, p)
+
+	_, err = uc.saveConfirmUpdatePatientIdentityBundle(ctx, t, dbPatient, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// confirmPatientPendingEmail replaces the current email of the patient with the pending one
+func confirmPatientPendingEmail(p *fhirModel.Patient, pending *fhirModel.ContactPoint) {
+	telecom := make([]*fhirModel.ContactPoint, 0, len(p.Telecom))
+	replaced := false
+
+	for _, t := range p.Telecom {
+		switch {
+		case t == pending:
+			continue
+		case !replaced && t.System == fhirModel.TelecomSystemEmail && !isPendingContactPoint(t):
+			t.Value = pending.Value
+			replaced = true
+		}
+
+		telecom = append(telecom, t)
+	}
+
+	if !replaced {
+		telecom = append(telecom, &fhirModel.ContactPoint{System: fhirModel.TelecomSystemEmail, Value: pending.Value})
+	}
+
+	p.Telecom = telecom
+}
@@ -58,7 +58,7 @@ func (uc *UseCase) ConfirmUpdatePatientIdentity(ctx context.Context, id fhirMode
 
 	err = uc.validateOTP(ctx, op.TaskID, op.OTPCode, patientParams.ConfirmationMethod)
 	if err != nil {
-		return nil, err
+		return nil, uc.rejectOTPExhaustedTask(ctx, t, p, err)
 	}
 
 	err = uc.validatePatientDupls(ctx, patientParams.Patient)
@@ -70,12 +70,23 @@ func (uc *UseCase) ConfirmUpdatePatientIdentity(ctx context.Context, id fhirMode
 		return nil, err
 	}
 
+	// the otp is already confirmed, so the approved request updates the patient without a new otp
+	if reason := taskHoldReason(t); reason != "" {
+		holdTask(t, reason, reviewBusinessStatus(reason))
+
+		if err := uc.saveTaskBundle(ctx, t, p); err != nil {
+			return nil, err
+		}
+
+		return t, nil
+	}
+
 	patient, err := uc.mergePatient(ctx, dbPatient, patientParams.Patient)
 	if err != nil {
 		return nil, err
 	}
 
-	uc.updateConfirmUpdatePatientIdentityTask(t, patient, p)
+	uc.updateConfirmUpdatePatientTask(t, entity.TaskBusinessStatusConfirmPatientIdentityUpdated, patient, p)
 
 	_, err = uc.saveConfirmUpdatePatientIdentityBundle(ctx, t, patient, p)
 	if err != nil {
@@ -85,13 +96,16 @@ func (uc *UseCase) ConfirmUpdatePatientIdentity(ctx context.Context, id fhirMode
 	return t, nil
 }
 
+// updateConfirmUpdatePatientTask completes the otp confirmed task updating the patient identity, phone or email
+//
 //nolint:dupl
-func (uc *UseCase) updateConfirmUpdatePatientIdentityTask(
+func (uc *UseCase) updateConfirmUpdatePatientTask(
 	t *fhirModel.Task,
+	businessStatus string,
 	patient *fhirModel.Patient,
 	otpParams *fhirModel.Parameters) {
 	t.Status = fhirModel.TaskStatusCompleted
-	t.BusinessStatus = &fhirModel.CodeableConcept{Text: entity.TaskBusinessStatusConfirmPatientIdentityUpdated}
+	t.BusinessStatus = &fhirModel.CodeableConcept{Text: businessStatus}
 	t.Input = append(t.Input, &fhirModel.TaskInput{
 		Type: &fhirModel.CodeableConcept{
 			Codings: []*fhirModel.Coding{
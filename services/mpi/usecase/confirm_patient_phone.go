@@ -0,0 +1,135 @@
+package usecase
+
+import (
+	"context"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/pkg/converto"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
+)
+
+func (uc *UseCase) ConfirmUpdatePatientPhone(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (
+	*fhirModel.Task, error) {
+	if _, err := uc.fhir.ValidateParameters(ctx, p); err != nil {
+		return nil, err
+	}
+
+	op, err := uc.extractConfirmRequestParams(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := uc.getTaskByID(ctx, op.TaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasTaskProfile(t, entity.StructureDefinitionTaskPatientUpdatePhone) ||
+		t.For == nil || t.For.Reference != patientReference(id).Reference {
+		return nil, cerror.NewF(ctx, cerror.KindNotExist, "such patient request does not exist")
+	}
+
+	err = uc.validateConfirmRequestTask(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceParams, err := uc.getTaskParameters(ctx, t, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	phoneParams, err := uc.extractUpdatePatientPhoneParams(ctx, resourceParams)
+	if err != nil {
+		return nil, err
+	}
+
+	dbPatient, err := uc.fhir.GetPatientByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = uc.validatePatientByInternalRules(ctx, dbPatient)
+	if err != nil {
+		return nil, err
+	}
+
+	err = uc.validateOTP(ctx, op.TaskID, op.OTPCode, phoneParams.Phone)
+	if err != nil {
+		return nil, uc.rejectOTPExhaustedTask(ctx, t, p, err)
+	}
+
+	if phoneParams.ConfirmOldPhone {
+		err = uc.validateOldPhoneOTP(ctx, t.ID, p, dbPatient)
+		if err != nil {
+			return nil, uc.rejectOTPExhaustedTask(ctx, t, p, err)
+		}
+	}
+
+	suspected, err := uc.isFraudSuspected(ctx, dbPatient, mobilePhone(phoneParams.Phone))
+	if err != nil {
+		if cerror.ErrKind(err) == cerror.KindBadValidation {
+			_ = uc.rejectTask(ctx, t, p)
+		}
+
+		return nil, err
+	}
+
+	if suspected {
+		if uc.fraudPolicy != entity.FraudPolicyHold {
+			_ = uc.rejectTask(ctx, t, p)
+			return nil, errTooManyPatientsWithPhone(ctx)
+		}
+
+		markTaskForReview(t, entity.TaskReasonFraudSuspected)
+	}
+
+	// the otp is already confirmed, so the approved request sets the phone without a new otp
+	if reason := taskHoldReason(t); reason != "" {
+		holdTask(t, reason, reviewBusinessStatus(reason))
+
+		if err := uc.saveTaskBundle(ctx, t, p); err != nil {
+			return nil, err
+		}
+
+		return t, nil
+	}
+
+	setPatientPhone(dbPatient, phoneParams.Phone)
+
+	uc.updateConfirmUpdatePatientTask(t, entity.TaskBusinessStatusPatientPhoneUpdated, dbPatient, p)
+
+	_, err = uc.saveConfirmUpdatePatientIdentityBundle(ctx, t, dbPatient, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (uc *UseCase) validateOldPhoneOTP(
+	ctx context.Context,
+	taskID fhirModel.ID,
+	p *fhirModel.Parameters,
+	dbPatient *fhirModel.Patient) error {
+	var otp string
+
+	for _, param := range p.Parameter {
+		if param.Name == "oldPhoneOtp" {
+			otp = converto.StringValue(param.ValueString)
+		}
+	}
+
+	if otp == "" {
+		return cerror.NewValidationError(
+			ctx, map[string]string{"Parameters.parameter": "missing oldPhoneOtp parameter"}).LogError()
+	}
+
+	oldTelecom := findMobilePhone(dbPatient)
+	if oldTelecom == nil {
+		return cerror.NewF(ctx, cerror.KindBadValidation, "patient has no phone to confirm").LogError()
+	}
+
+	return uc.validateOTP(ctx, fhirModel.ID(oldPhoneProcessID(taskID)), otp, oldTelecom.Value)
+}
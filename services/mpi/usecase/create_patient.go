@@ -14,7 +14,6 @@ import (
 	"wasfaty.api/pkg/cerror"
 	"wasfaty.api/pkg/converto"
 	fhirModel "wasfaty.api/pkg/fhir/model"
-	"wasfaty.api/pkg/log"
 	"wasfaty.api/services/mpi/entity"
 )
 
@@ -25,20 +24,28 @@ func (uc *UseCase) CreatePatient(ctx context.Context, params *fhirModel.Paramete
 		return nil, err
 	}
 
-	if vErr := uc.validateCreatePatientParameters(ctx, params, patient); vErr != nil {
-		return nil, vErr
+	issues := make(validationIssues)
+
+	for _, vErr := range []error{
+		uc.validateCreatePatientParameters(ctx, params, patient),
+		uc.validatePatientProfile(ctx, params.Meta, patient.Meta),
+		uc.validateCreatePatientByInternalRules(ctx, patient),
+	} {
+		if err := issues.add(vErr); err != nil {
+			return nil, err
+		}
 	}
 
-	if pErr := uc.validatePatientProfile(ctx, params.Meta, patient.Meta); pErr != nil {
-		return nil, pErr
+	if err := issues.err(ctx); err != nil {
+		return nil, err
 	}
 
-	err = uc.validateCreatePatientByInternalRules(ctx, patient)
+	record, err := uc.validateByExtDocRegistry(ctx, patient)
 	if err != nil {
 		return nil, err
 	}
 
-	err = uc.validateByExtDocRegistry(ctx, patient)
+	demographicIssues, err := uc.crossCheckDemographics(ctx, params, 0, patient, record)
 	if err != nil {
 		return nil, err
 	}
@@ -53,14 +60,27 @@ func (uc *UseCase) CreatePatient(ctx context.Context, params *fhirModel.Paramete
 		return nil, err
 	}
 
+	possibleDupls, err := uc.searchPossibleDuplicates(ctx, patient)
+	if err != nil {
+		return nil, err
+	}
+
 	task := prepareCreatePatientTask(params)
 
+	// the marked request is held for the review when its otp is confirmed, so the approval does not skip the otp
+	if uc.demographicsPolicy == entity.DemographicsPolicyHold && len(demographicIssues) > 0 {
+		markTaskForReview(task, entity.TaskReasonRegistryMismatch)
+	}
+
+	uc.flagPossibleDuplicates(task, possibleDupls)
+	addTaskOutcome(task, append(demographicIssues, possibleDuplicateIssues(0, possibleDupls)...))
+
 	otp, err := uc.generateOTP(ctx, task.ID, patient)
 	if err != nil {
 		return nil, err
 	}
 
-	err = uc.sendOTP(ctx, otp)
+	err = uc.sendOTP(ctx, task, otp, patientLanguage(patient))
 	if err != nil {
 		return nil, err
 	}
@@ -238,13 +258,14 @@ func (uc *UseCase) validateCreatePatientParameters(ctx context.Context, params *
 		return err
 	}
 
+	issues := make(validationIssues)
+
 	if len(params.Parameter) != 1 {
-		return cerror.NewValidationError(ctx, map[string]string{"Parameters": "expected to have 1 element"}).LogError()
+		issues["Parameters.parameter"] = "expected to have 1 element"
 	}
 
 	if !converto.BoolValue(patient.Active) {
-		return cerror.NewValidationError(
-			ctx, map[string]string{"Parameters.parameter[0].resource.active": "should have true value"}).LogError()
+		issues["Parameters.parameter[0].resource.active"] = "should have true value"
 	}
 
 	paramsMap, err := interfaceToMap(ctx, params.Parameter[0].Resource, "Parameters.parameter[0].resource")
@@ -252,20 +273,13 @@ func (uc *UseCase) validateCreatePatientParameters(ctx context.Context, params *
 		return err
 	}
 
-	var forbiddenParams []string
-
 	for _, fp := range entity.CreatePatientForbiddenParams() {
 		if _, ok := paramsMap[fp]; ok {
-			forbiddenParams = append(forbiddenParams, fp)
+			issues[fmt.Sprintf("Parameters.parameter[0].resource.%s", fp)] = "forbidden parameter"
 		}
 	}
 
-	if len(forbiddenParams) > 0 {
-		errMsg := fmt.Sprintf("forbidden parameters: %s", strings.Join(forbiddenParams, ","))
-		return cerror.NewValidationError(ctx, map[string]string{"Parameters.parameter[0].resource": errMsg}).LogError()
-	}
-
-	return nil
+	return issues.err(ctx)
 }
 
 func (uc *UseCase) validateCreatePatientByInternalRules(ctx context.Context, patient *fhirModel.Patient) error {
@@ -273,28 +287,30 @@ func (uc *UseCase) validateCreatePatientByInternalRules(ctx context.Context, pat
 }
 
 func (uc *UseCase) validateIdents(ctx context.Context, p *fhirModel.Patient, patientParamIndex int) error {
+	issues := make(validationIssues)
+
 	n := uc.getPatientNationalityCode(p)
 	if n == "" {
-		return cerror.NewValidationError(
-			ctx, map[string]string{fmt.Sprintf("Parameters.parameter[%d]", patientParamIndex): "nationality is not passed"}).
-			LogError()
+		issues[fmt.Sprintf("Parameters.parameter[%d].resource.extension", patientParamIndex)] = "nationality is not passed"
 	}
 
 	for identIndex, i := range p.Identifier {
-		if err := uc.validateIdentExpirationDate(ctx, i, patientParamIndex, identIndex); err != nil {
+		if err := issues.add(uc.validateIdentExpirationDate(ctx, i, patientParamIndex, identIndex)); err != nil {
 			return err
 		}
 
-		if err := uc.validateIdentValueFormat(ctx, i, patientParamIndex, identIndex); err != nil {
+		if err := issues.add(uc.validateIdentValueFormat(ctx, i, patientParamIndex, identIndex)); err != nil {
 			return err
 		}
 	}
 
-	if err := uc.validateRequiredIdentType(ctx, n, p.Identifier...); err != nil {
-		return err
+	if n != "" {
+		if err := issues.add(uc.validateRequiredIdentType(ctx, n, patientParamIndex, p.Identifier...)); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return issues.err(ctx)
 }
 
 func (uc *UseCase) getPatientNationalityCode(p *fhirModel.Patient) string {
@@ -402,7 +418,7 @@ func (uc *UseCase) generateOTP(ctx context.Context, taskID fhirModel.ID, p *fhir
 			ctx, map[string]string{"Telecom": "no mobile phone found"}).LogError()
 	}
 
-	otp, err := uc.otp.GenerateByPhone(ctx, phone, string(taskID))
+	otp, err := uc.generateOTPByPhone(ctx, phone, string(taskID))
 	if err != nil {
 		return nil, err
 	}
@@ -410,37 +426,36 @@ func (uc *UseCase) generateOTP(ctx context.Context, taskID fhirModel.ID, p *fhir
 	return otp, nil
 }
 
-func (uc *UseCase) sendOTP(ctx context.Context, otp *entity.OTP) error {
-	if otp.Code == "" {
-		return cerror.NewF(ctx, cerror.KindInternal, "otp is empty").LogError()
-	}
-
-	log.InfoF(ctx, "generated otp %s for number %s", otp.Code, otp.Value)
+// validateByExtDocRegistry checks the patient documents in the external registry
+// and returns the registry record of the first checked document
+func (uc *UseCase) validateByExtDocRegistry(ctx context.Context, p *fhirModel.Patient) (
+	*entity.ExtDocRegistrySearchResult, error) {
+	var record *entity.ExtDocRegistrySearchResult
 
-	return nil
-}
-
-func (uc *UseCase) validateByExtDocRegistry(ctx context.Context, p *fhirModel.Patient) error {
 	for i, ident := range p.Identifier {
 		if len(ident.Type.Codings) == 0 {
 			continue
 		}
 
-		if ident.Type.Codings[0].Code == fhirModel.IdentNationalID {
+		if contains(entity.ExtDocRegistryIdentTypes(), ident.Type.Codings[0].Code) {
 			r, err := uc.docReg.Search(ctx, ident)
 			if err != nil {
-				return err
+				return nil, err
 			}
 
 			if !r.IsValid {
-				return cerror.NewValidationError(ctx, map[string]string{
+				return nil, cerror.NewValidationError(ctx, map[string]string{
 					fmt.Sprintf("Identifiers[%d]", i): "document is not valid",
 				}).LogError()
 			}
+
+			if record == nil {
+				record = r
+			}
 		}
 	}
 
-	return nil
+	return record, nil
 }
 
 func (uc *UseCase) validateIdentExpirationDate(ctx context.Context, i *fhirModel.Identifier, paramIndex, identIndex int) error {
@@ -474,7 +489,11 @@ func (uc *UseCase) validateIdentValueFormat(ctx context.Context, i *fhirModel.Id
 	return nil
 }
 
-func (uc *UseCase) validateRequiredIdentType(ctx context.Context, nationalityCode string, idents ...*fhirModel.Identifier) error {
+func (uc *UseCase) validateRequiredIdentType(
+	ctx context.Context,
+	nationalityCode string,
+	paramIndex int,
+	idents ...*fhirModel.Identifier) error {
 	allowedCodes := entity.IdentifierCodeForSANationality()
 	if nationalityCode != fhirModel.NationalityCodeSA {
 		allowedCodes = entity.IdentifierCodeForOtherNationality()
@@ -488,8 +507,11 @@ func (uc *UseCase) validateRequiredIdentType(ctx context.Context, nationalityCod
 		}
 	}
 
-	return cerror.NewF(ctx, cerror.KindBadValidation,
-		"identifier code should be one of %s", strings.Join(allowedCodes, ",")).LogError()
+	field := fmt.Sprintf("Parameters.parameter[%d].resource.identifier", paramIndex)
+
+	return cerror.NewValidationError(ctx, map[string]string{
+		field: fmt.Sprintf("identifier code should be one of %s", strings.Join(allowedCodes, ",")),
+	}).LogError()
 }
 
 func (uc *UseCase) validatePatientProfile(ctx context.Context, paramsMeta, patientMeta *fhirModel.Meta) error {
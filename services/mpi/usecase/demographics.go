@@ -0,0 +1,235 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	uuid "github.com/satori/go.uuid"
+
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
+)
+
+const dateLayout = "2006-01-02"
+
+const (
+	demographicName        = "name"
+	demographicBirthDate   = "birthDate"
+	demographicGender      = "gender"
+	demographicNationality = "extension"
+)
+
+// crossCheckDemographics compares the submitted patient with the civil registry record and applies
+// the demographics policy to the mismatches, the returned issues are meant for the response outcome
+func (uc *UseCase) crossCheckDemographics(
+	ctx context.Context,
+	params *fhirModel.Parameters,
+	patientParamIndex int,
+	p *fhirModel.Patient,
+	r *entity.ExtDocRegistrySearchResult) ([]*fhirModel.OperationOutcomeIssue, error) {
+	if r == nil {
+		return nil, nil
+	}
+
+	mismatches := uc.compareDemographics(p, r)
+	if len(mismatches) == 0 {
+		return nil, nil
+	}
+
+	switch uc.demographicsPolicy {
+	case entity.DemographicsPolicyReject:
+		issues := make(validationIssues)
+		for _, m := range mismatches {
+			issues[demographicExpression(patientParamIndex, m)] = "does not match the civil registry record"
+		}
+
+		return nil, issues.err(ctx)
+	case entity.DemographicsPolicyAutocorrect:
+		correctDemographics(p, r, mismatches)
+
+		paramKey := fmt.Sprintf("Parameters.parameter[%d].resource", patientParamIndex)
+
+		paramsMap, err := interfaceToMap(ctx, params.Parameter[patientParamIndex].Resource, paramKey)
+		if err != nil {
+			return nil, err
+		}
+
+		patientMap := mapFromStruct(p)
+		for _, m := range mismatches {
+			paramsMap[m] = patientMap[m]
+		}
+
+		params.Parameter[patientParamIndex].Resource = paramsMap
+
+		return demographicIssues(patientParamIndex, mismatches, entity.OutcomeIssueSeverityInformation,
+			entity.OutcomeIssueCodeInformational, "corrected from the civil registry record"), nil
+	default:
+		return demographicIssues(patientParamIndex, mismatches, entity.OutcomeIssueSeverityWarning,
+			entity.OutcomeIssueCodeBusinessRule, "does not match the civil registry record"), nil
+	}
+}
+
+// compareDemographics returns the mismatched fields, a field is compared only when both sides have a value
+func (uc *UseCase) compareDemographics(p *fhirModel.Patient, r *entity.ExtDocRegistrySearchResult) []string {
+	var mismatches []string
+
+	// the patient may have names in several languages, one of them is expected to match the registry
+	if len(p.Name) != 0 && (r.FamilyName != "" || r.GivenName != "") && !hasRegistryName(p, r) {
+		mismatches = append(mismatches, demographicName)
+	}
+
+	if p.BirthDate != nil && r.BirthDate != "" && p.BirthDate.String() != r.BirthDate {
+		if _, err := time.Parse(dateLayout, r.BirthDate); err == nil {
+			mismatches = append(mismatches, demographicBirthDate)
+		}
+	}
+
+	if p.Gender != "" && r.Gender != "" && !equalFoldTrimmed(p.Gender, r.Gender) {
+		mismatches = append(mismatches, demographicGender)
+	}
+
+	if n := uc.getPatientNationalityCode(p); n != "" && r.Nationality != "" && !equalFoldTrimmed(n, r.Nationality) {
+		mismatches = append(mismatches, demographicNationality)
+	}
+
+	return mismatches
+}
+
+func hasRegistryName(p *fhirModel.Patient, r *entity.ExtDocRegistrySearchResult) bool {
+	for _, n := range p.Name {
+		var given string
+		if len(n.Given) != 0 {
+			given = n.Given[0]
+		}
+
+		if equalFoldTrimmed(n.Family, r.FamilyName) && equalFoldTrimmed(given, r.GivenName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func correctDemographics(p *fhirModel.Patient, r *entity.ExtDocRegistrySearchResult, mismatches []string) {
+	for _, m := range mismatches {
+		switch m {
+		case demographicName:
+			i := registryScriptNameIndex(p, r)
+			if i == -1 {
+				p.Name = append(p.Name, &fhirModel.HumanName{Family: r.FamilyName, Given: []string{r.GivenName}})
+				continue
+			}
+
+			p.Name[i].Family = r.FamilyName
+
+			if len(p.Name[i].Given) == 0 {
+				p.Name[i].Given = []string{r.GivenName}
+			} else {
+				p.Name[i].Given[0] = r.GivenName
+			}
+		case demographicBirthDate:
+			t, _ := time.Parse(dateLayout, r.BirthDate)
+			p.BirthDate = (*fhirModel.Date)(&t)
+		case demographicGender:
+			p.Gender = strings.ToLower(r.Gender)
+		case demographicNationality:
+			setPatientNationalityCode(p, r.Nationality)
+		}
+	}
+}
+
+// registryScriptNameIndex returns the index of the patient name written in the script of the registry name,
+// so the correction does not replace the name in the other language. It is -1 when there is no such name
+func registryScriptNameIndex(p *fhirModel.Patient, r *entity.ExtDocRegistrySearchResult) int {
+	arabic := isArabicScript(r.FamilyName + r.GivenName)
+
+	for i, n := range p.Name {
+		if isArabicScript(n.Family+strings.Join(n.Given, "")) == arabic {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func isArabicScript(s string) bool {
+	for _, c := range s {
+		if unicode.Is(unicode.Arabic, c) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func setPatientNationalityCode(p *fhirModel.Patient, code string) {
+	for _, e := range p.Extension {
+		if e.URL == fhirModel.StructureDefinitionPatientNationality {
+			for _, ee := range e.Extension {
+				if ee.URL == "code" && ee.ValueCodeableConcept != nil && len(ee.ValueCodeableConcept.Codings) != 0 {
+					ee.ValueCodeableConcept.Codings[0].Code = code
+				}
+			}
+		}
+	}
+}
+
+func demographicIssues(
+	patientParamIndex int, mismatches []string, severity, code, diagnostics string) []*fhirModel.OperationOutcomeIssue {
+	issues := make([]*fhirModel.OperationOutcomeIssue, 0, len(mismatches))
+
+	for _, m := range mismatches {
+		issues = append(issues, &fhirModel.OperationOutcomeIssue{
+			Severity:    severity,
+			Code:        code,
+			Diagnostics: diagnostics,
+			Expression:  []string{demographicExpression(patientParamIndex, m)},
+		})
+	}
+
+	return issues
+}
+
+func demographicExpression(patientParamIndex int, field string) string {
+	return fmt.Sprintf("Parameters.parameter[%d].resource.%s", patientParamIndex, field)
+}
+
+// addTaskOutcome keeps the demographics issues in the task as a contained outcome referenced by the task output
+func addTaskOutcome(t *fhirModel.Task, issues []*fhirModel.OperationOutcomeIssue) {
+	if len(issues) == 0 {
+		return
+	}
+
+	id := fhirModel.ID(uuid.NewV4().String())
+
+	t.Contained = append(t.Contained, &fhirModel.OperationOutcome{
+		DomainResource: fhirModel.DomainResource{
+			Resource: fhirModel.Resource{
+				ID:           id,
+				ResourceType: fhirModel.ResourceOperationOutcome,
+			},
+		},
+		Issue: issues,
+	})
+
+	t.Output = append(t.Output, &fhirModel.TaskOutput{
+		Type: &fhirModel.CodeableConcept{
+			Codings: []*fhirModel.Coding{
+				{
+					Code:   fhirModel.ResourceOperationOutcome,
+					System: fhirModel.CodingSystemResourceTypes,
+				},
+			},
+		},
+		ValueX: fhirModel.ValueX{
+			ValueReference: &fhirModel.Reference{Reference: "#" + string(id)},
+		},
+	})
+}
+
+func equalFoldTrimmed(a, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
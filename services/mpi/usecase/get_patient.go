@@ -0,0 +1,89 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+
+	"wasfaty.api/pkg/cerror"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
+)
+
+func (uc *UseCase) GetPatient(ctx context.Context, id fhirModel.ID, params *entity.GetPatientParams) (
+	map[string]interface{}, error) {
+	if err := uc.validateGetPatientParams(ctx, params); err != nil {
+		return nil, err
+	}
+
+	patient, err := uc.fhir.GetPatientByID(ctx, id)
+	if err != nil {
+		if cerror.ErrKind(err) == cerror.KindNotExist {
+			return nil, cerror.NewF(ctx, cerror.KindNotExist, "such patient does not exist")
+		}
+
+		return nil, err
+	}
+
+	return subsetResource(mapFromStruct(patient), params), nil
+}
+
+func (uc *UseCase) validateGetPatientParams(ctx context.Context, params *entity.GetPatientParams) error {
+	switch params.Summary {
+	case "", entity.SummaryFalse, entity.SummaryTrue, entity.SummaryText, entity.SummaryData:
+	default:
+		return cerror.NewValidationError(ctx, map[string]string{"_summary": "unsupported value"}).LogError()
+	}
+
+	if len(params.Elements) > 0 && params.Summary != "" && params.Summary != entity.SummaryFalse {
+		return cerror.NewValidationError(
+			ctx, map[string]string{"_elements": "can not be combined with _summary"}).LogError()
+	}
+
+	for _, e := range params.Elements {
+		if e == "" || strings.Contains(e, ".") {
+			return cerror.NewValidationError(
+				ctx, map[string]string{"_elements": "only top level element names are supported"}).LogError()
+		}
+	}
+
+	return nil
+}
+
+// subsetResource keeps only the resource elements requested by _elements or _summary
+// and marks the result with the SUBSETTED meta tag
+func subsetResource(res map[string]interface{}, params *entity.GetPatientParams) map[string]interface{} {
+	var keep func(string) bool
+
+	switch {
+	case len(params.Elements) > 0:
+		keep = func(e string) bool { return contains(params.Elements, e) }
+	case params.Summary == entity.SummaryTrue:
+		keep = func(e string) bool { return contains(entity.PatientSummaryElements(), e) }
+	case params.Summary == entity.SummaryText:
+		keep = func(e string) bool { return e == "text" }
+	case params.Summary == entity.SummaryData:
+		keep = func(e string) bool { return e != "text" }
+	default:
+		return res
+	}
+
+	for e := range res {
+		if !contains(entity.ResourceMandatoryElements(), e) && !keep(e) {
+			delete(res, e)
+		}
+	}
+
+	meta, _ := res["meta"].(map[string]interface{})
+	if meta == nil {
+		meta = make(map[string]interface{})
+	}
+
+	tags, _ := meta["tag"].([]interface{})
+	meta["tag"] = append(tags, mapFromStruct(&fhirModel.Coding{
+		System: entity.MetaTagSystemObservationValue,
+		Code:   entity.MetaTagSubsetted,
+	}))
+	res["meta"] = meta
+
+	return res
+}
@@ -0,0 +1,99 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	uuid "github.com/satori/go.uuid"
+
+	"wasfaty.api/pkg/cerror"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
+)
+
+func (uc *UseCase) GetTask(ctx context.Context, id fhirModel.ID) (*fhirModel.Task, error) {
+	t, err := uc.getTaskByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasTaskProfile(t, entity.MPITaskProfiles()...) {
+		return nil, cerror.NewF(ctx, cerror.KindNotExist, "such patient request does not exist")
+	}
+
+	return t, nil
+}
+
+func (uc *UseCase) SearchTasks(ctx context.Context, params *entity.SearchTaskParams) (*fhirModel.Bundle, error) {
+	if err := uc.validateSearchTaskParams(ctx, params); err != nil {
+		return nil, err
+	}
+
+	if len(params.Profiles) == 0 {
+		params.Profiles = entity.MPITaskProfiles()
+	}
+
+	tasks, err := uc.fhir.SearchTaskByParams(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &fhirModel.Bundle{
+		Resource: fhirModel.Resource{
+			ID:           fhirModel.ID(uuid.NewV4().String()),
+			ResourceType: fhirModel.ResourceBundle,
+		},
+		Type:  fhirModel.BundleTypeSearchset,
+		Entry: []*fhirModel.BundleEntry{},
+	}
+
+	for _, t := range tasks {
+		if !hasTaskProfile(t, params.Profiles...) {
+			continue
+		}
+
+		b.Entry = append(b.Entry, &fhirModel.BundleEntry{
+			FullURL:  fmt.Sprintf("%s/%s", fhirModel.ResourceTask, t.ID),
+			Resource: t,
+			Search:   &fhirModel.BundleEntrySearch{Mode: entity.SearchEntryModeMatch},
+		})
+	}
+
+	return b, nil
+}
+
+func (uc *UseCase) validateSearchTaskParams(ctx context.Context, params *entity.SearchTaskParams) error {
+	if params.PatientID == "" {
+		return cerror.NewValidationError(ctx, map[string]string{"patient": "value is required"}).LogError()
+	}
+
+	if params.Status != "" && !contains(entity.TaskStatusList(), params.Status) {
+		return cerror.NewValidationError(ctx, map[string]string{"status": "unsupported value"}).LogError()
+	}
+
+	for _, p := range params.Profiles {
+		if !contains(entity.MPITaskProfiles(), p) {
+			return cerror.NewValidationError(ctx, map[string]string{"profile": "unsupported value"}).LogError()
+		}
+	}
+
+	return nil
+}
+
+func hasTaskProfile(t *fhirModel.Task, profiles ...string) bool {
+	if t.Meta == nil {
+		return false
+	}
+
+	for _, p := range t.Meta.Profile {
+		if contains(profiles, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func patientReference(id fhirModel.ID) *fhirModel.Reference {
+	return &fhirModel.Reference{Reference: fmt.Sprintf("%s/%s", fhirModel.ResourcePatient, id)}
+}
@@ -0,0 +1,155 @@
+package usecase
+
+import (
+	"context"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/pkg/converto"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
+)
+
+// LinkPatients links two active patients by the link of the requested type, the target gets the seealso link back
+// so the relationship is found from either record, the refer link is kept on the source only as it points
+// to the record which should be used instead
+func (uc *UseCase) LinkPatients(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+	if _, err := uc.fhir.ValidateParameters(ctx, p); err != nil {
+		return nil, err
+	}
+
+	administrator, err := uc.authorizeAdministrator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pp, err := uc.extractPatientPairParams(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	linkType, err := uc.extractLinkType(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := uc.getLinkedPatient(ctx, pp.SourceID, "source")
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := uc.getLinkedPatient(ctx, pp.TargetID, "target")
+	if err != nil {
+		return nil, err
+	}
+
+	if isPatientLinked(source, target.ID) || isPatientLinked(target, source.ID) {
+		return nil, cerror.NewF(ctx, cerror.KindBadValidation, "patients are already linked").LogError()
+	}
+
+	source.Link = append(source.Link, &fhirModel.PatientLink{Other: patientReference(target.ID), Type: linkType})
+	target.Link = append(target.Link,
+		&fhirModel.PatientLink{Other: patientReference(source.ID), Type: entity.PatientLinkSeeAlso})
+
+	task := preparePatientsTask(p, entity.StructureDefinitionTaskPatientLink, entity.TaskBusinessStatusPatientsLinked,
+		source.ID, target.ID)
+	addTaskAdministrator(task, administrator)
+
+	_, err = uc.fhir.CreateBundle(ctx, preparePatientsBundle(p, task, source, target))
+	if err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// UnlinkPatients removes the links set by the $link from both patients, the patients are not required to be active
+// so the links of the deactivated records could be cleaned up too
+func (uc *UseCase) UnlinkPatients(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+	if _, err := uc.fhir.ValidateParameters(ctx, p); err != nil {
+		return nil, err
+	}
+
+	administrator, err := uc.authorizeAdministrator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pp, err := uc.extractPatientPairParams(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := uc.getPatientByRole(ctx, pp.SourceID, "source")
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := uc.getPatientByRole(ctx, pp.TargetID, "target")
+	if err != nil {
+		return nil, err
+	}
+
+	if !isPatientLinked(source, target.ID) && !isPatientLinked(target, source.ID) {
+		return nil, cerror.NewF(ctx, cerror.KindBadValidation, "patients are not linked").LogError()
+	}
+
+	for _, linkType := range entity.PatientLinkTypes() {
+		source.Link = removePatientLink(source.Link, linkType, target.ID)
+		target.Link = removePatientLink(target.Link, linkType, source.ID)
+	}
+
+	task := preparePatientsTask(p, entity.StructureDefinitionTaskPatientUnlink, entity.TaskBusinessStatusPatientsUnlinked,
+		source.ID, target.ID)
+	addTaskAdministrator(task, administrator)
+
+	_, err = uc.fhir.CreateBundle(ctx, preparePatientsBundle(p, task, source, target))
+	if err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+func (uc *UseCase) extractLinkType(ctx context.Context, p *fhirModel.Parameters) (string, error) {
+	for _, param := range p.Parameter {
+		if param.Name != "type" {
+			continue
+		}
+
+		linkType := converto.StringValue(param.ValueCode)
+		if !contains(entity.PatientLinkTypes(), linkType) {
+			return "", cerror.NewValidationError(
+				ctx, map[string]string{"Parameters.parameter": "unsupported type parameter value"}).LogError()
+		}
+
+		return linkType, nil
+	}
+
+	return "", cerror.NewValidationError(
+		ctx, map[string]string{"Parameters.parameter": "missing type parameter"}).LogError()
+}
+
+// getLinkedPatient returns the patient which could be linked, it should be active and not deceased
+func (uc *UseCase) getLinkedPatient(ctx context.Context, id fhirModel.ID, role string) (*fhirModel.Patient, error) {
+	p, err := uc.getPatientByRole(ctx, id, role)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.validatePatientByInternalRules(ctx, p); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// isPatientLinked checks that the patient has a link of the $link types to the other patient
+func isPatientLinked(p *fhirModel.Patient, otherID fhirModel.ID) bool {
+	for _, linkType := range entity.PatientLinkTypes() {
+		if hasPatientLink(p.Link, linkType, otherID) {
+			return true
+		}
+	}
+
+	return false
+}
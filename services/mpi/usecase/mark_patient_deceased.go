@@ -0,0 +1,195 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/pkg/converto"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
+)
+
+const (
+	deathDateLayout      = "2006-01-02"
+	patientTasksPageSize = 100
+)
+
+// MarkPatientDeceased sets the date of the patient death, the civil registry deaths are verified by the document
+// registry. The in-progress and on-hold tasks of the patient are canceled in the same bundle because they can not
+// be confirmed, the pending email of the canceled email change is dropped like by the other task cancellations
+func (uc *UseCase) MarkPatientDeceased(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (
+	*fhirModel.Task, error) {
+	if _, err := uc.fhir.ValidateParameters(ctx, p); err != nil {
+		return nil, err
+	}
+
+	administrator, err := uc.authorizeAdministrator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	date, source, err := uc.extractDeathParams(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	patient, err := uc.fhir.GetPatientByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.validatePatientByInternalRules(ctx, patient); err != nil {
+		return nil, err
+	}
+
+	if source == entity.DeathSourceCivilRegistry {
+		if err := uc.verifyDeathByExtDocRegistry(ctx, patient, date); err != nil {
+			return nil, err
+		}
+	}
+
+	tasks, err := uc.searchPatientInProgressTasks(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	patient.DeceasedBoolean = nil
+	patient.DeceasedDateTime = date
+
+	task := prepareActivityPatientTask(p, id, entity.StructureDefinitionTaskPatientMarkDeceased,
+		entity.TaskBusinessStatusPatientDeceased, source)
+	task.StatusReason.Codings[0].System = entity.CodeSystemDeathSource
+	addTaskAdministrator(task, administrator)
+
+	b := prepareUpdatePatientBundle(p, task, patient)
+	now := time.Now().UTC()
+
+	for _, t := range tasks {
+		t.Status = fhirModel.TaskStatusCanceled
+		t.BusinessStatus = &fhirModel.CodeableConcept{Text: entity.TaskBusinessStatusCanceledPatientDeceased}
+		t.LastModified = (*fhirModel.DateTime)(converto.TimePointer(now))
+
+		// the patient is already put by the bundle
+		if hasTaskProfile(t, fhirModel.StructureDefinitionTaskPatientUpdateEmail) {
+			removePatientPendingEmail(patient)
+		}
+
+		b.Entry = append(b.Entry, &fhirModel.BundleEntry{
+			Resource: t,
+			Request: &fhirModel.BundleEntryRequest{
+				Method: http.MethodPut,
+				URL:    fmt.Sprintf("%s/%s", fhirModel.ResourceTask, t.ID),
+			},
+		})
+	}
+
+	_, err = uc.fhir.CreateBundle(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+func (uc *UseCase) extractDeathParams(ctx context.Context, p *fhirModel.Parameters) (
+	*fhirModel.DateTime, string, error) {
+	var (
+		date   *fhirModel.DateTime
+		source string
+	)
+
+	issues := validationIssues{}
+
+	for _, param := range p.Parameter {
+		switch param.Name {
+		case "date":
+			date = param.ValueDateTime
+		case "source":
+			source = converto.StringValue(param.ValueCode)
+		}
+	}
+
+	switch {
+	case date == nil:
+		issues["Parameters.parameter.date"] = "missing date parameter"
+	case date.Time().After(time.Now().UTC()):
+		issues["Parameters.parameter.date"] = "date parameter is in the future"
+	}
+
+	switch {
+	case source == "":
+		issues["Parameters.parameter.source"] = "missing source parameter"
+	case !contains(entity.DeathSources(), source):
+		issues["Parameters.parameter.source"] = "unsupported source parameter value"
+	}
+
+	if err := issues.err(ctx); err != nil {
+		return nil, "", err
+	}
+
+	return date, source, nil
+}
+
+// verifyDeathByExtDocRegistry checks that the registry record of the first patient document is deceased,
+// the death date is compared when the registry has it, in the offset of the requested date as the registry keeps
+// the local dates
+func (uc *UseCase) verifyDeathByExtDocRegistry(ctx context.Context, p *fhirModel.Patient, date *fhirModel.DateTime) error {
+	for _, ident := range p.Identifier {
+		if !contains(entity.ExtDocRegistryIdentTypes(), identifierTypeCode(ident)) {
+			continue
+		}
+
+		r, err := uc.deathReg.Search(ctx, ident)
+		if err != nil {
+			return err
+		}
+
+		if !r.Deceased {
+			return cerror.NewF(ctx, cerror.KindBadValidation, "death is not registered in civil registry").LogError()
+		}
+
+		if r.DeathDate != "" && r.DeathDate != date.Time().Format(deathDateLayout) {
+			return cerror.NewF(ctx, cerror.KindBadValidation,
+				"death date does not match civil registry record").LogError()
+		}
+
+		return nil
+	}
+
+	return cerror.NewF(ctx, cerror.KindBadValidation, "patient has no document to verify death by").LogError()
+}
+
+// searchPatientInProgressTasks returns the in-progress and on-hold tasks of the patient of any profile,
+// all the result pages are read as the tasks are canceled after the search
+func (uc *UseCase) searchPatientInProgressTasks(ctx context.Context, patientID fhirModel.ID) (
+	[]*fhirModel.Task, error) {
+	ref := patientReference(patientID).Reference
+	inProgress := []*fhirModel.Task{}
+
+	for offset := 0; ; offset += patientTasksPageSize {
+		tasks, err := uc.fhir.SearchTaskByParams(ctx, &entity.SearchTaskParams{
+			PatientID: patientID,
+			Status:    fmt.Sprintf("%s,%s", fhirModel.TaskStatusInProgress, fhirModel.TaskStatusOnHold),
+			Sort:      taskSortAuthoredOn,
+			Count:     patientTasksPageSize,
+			Offset:    offset,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range tasks {
+			if (t.Status == fhirModel.TaskStatusInProgress || t.Status == fhirModel.TaskStatusOnHold) &&
+				t.For != nil && t.For.Reference == ref {
+				inProgress = append(inProgress, t)
+			}
+		}
+
+		if len(tasks) < patientTasksPageSize {
+			return inProgress, nil
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/pkg/converto"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
+)
+
+// phoneSuffixLength is the compared phone suffix, so the numbers written with and without the country code match
+const phoneSuffixLength = 9
+
+type matchParams struct {
+	patient            *fhirModel.Patient
+	count              int
+	onlyCertainMatches bool
+}
+
+type patientMatch struct {
+	patient *fhirModel.Patient
+	score   float64
+	grade   string
+}
+
+// MatchPatients finds the patients who are probably the same person as the passed one,
+// unlike the search the candidates are scored by the similarity of the demographics, phones and identifiers
+func (uc *UseCase) MatchPatients(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Bundle, error) {
+	if _, err := uc.fhir.ValidateParameters(ctx, p); err != nil {
+		return nil, err
+	}
+
+	params, err := extractMatchParams(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := uc.searchMatchCandidates(ctx, params.patient)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]*patientMatch, 0, len(candidates))
+
+	for _, c := range candidates {
+		score := uc.matchScore(params.patient, c)
+		grade := uc.matchPolicy.Grade(score)
+
+		if grade == entity.MatchGradeCertainlyNot || (params.onlyCertainMatches && grade != entity.MatchGradeCertain) {
+			continue
+		}
+
+		matches = append(matches, &patientMatch{patient: c, score: score, grade: grade})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+	if len(matches) > params.count {
+		matches = matches[:params.count]
+	}
+
+	return prepareMatchBundle(matches), nil
+}
+
+func extractMatchParams(ctx context.Context, p *fhirModel.Parameters) (*matchParams, error) {
+	params := &matchParams{count: entity.PatientMatchDefaultCount}
+
+	for i, param := range p.Parameter {
+		switch param.Name {
+		case "resource":
+			params.patient = new(fhirModel.Patient)
+			if err := interfaceToStruct(
+				ctx, param.Resource, params.patient, fmt.Sprintf("Parameters.parameter[%d].resource", i)); err != nil {
+				return nil, err
+			}
+		case "onlyCertainMatches":
+			params.onlyCertainMatches = converto.BoolValue(param.ValueBoolean)
+		case "count":
+			if param.ValueInteger != nil && *param.ValueInteger > 0 {
+				params.count = *param.ValueInteger
+			}
+		}
+	}
+
+	if params.patient == nil {
+		return nil, cerror.NewValidationError(
+			ctx, map[string]string{"Parameters.parameter": "missing resource parameter"}).LogError()
+	}
+
+	if len(params.patient.Name) == 0 || params.patient.BirthDate == nil {
+		return nil, cerror.NewValidationError(
+			ctx, map[string]string{"Parameters.parameter.resource": "name and birthDate are required"}).LogError()
+	}
+
+	return params, nil
+}
+
+// searchMatchCandidates finds the patients having any of the identifiers, any of the mobile phones
+// or the same name and birth date, the birth date typos are found by the full name born the same year
+func (uc *UseCase) searchMatchCandidates(ctx context.Context, p *fhirModel.Patient) ([]*fhirModel.Patient, error) {
+	searches := nameMatchSearches(p)
+
+	for _, ident := range p.Identifier {
+		if ident.Value != "" {
+			searches = append(searches, &entity.SearchPatientParams{
+				Identifier: &entity.SearchPatientByIdentifierParams{Value: ident.Value},
+			})
+		}
+	}
+
+	for _, t := range p.Telecom {
+		if t.System == fhirModel.TelecomSystemPhone && t.Value != "" {
+			searches = append(searches, &entity.SearchPatientParams{
+				Phone: &entity.SearchPatientByPhoneParams{Phone: t.Value},
+			})
+		}
+	}
+
+	return uc.searchCandidates(ctx, p, searches)
+}
+
+// searchCandidates returns the patients found by any of the searches once, the passed patient is skipped
+func (uc *UseCase) searchCandidates(ctx context.Context, p *fhirModel.Patient, searches []*entity.SearchPatientParams) (
+	[]*fhirModel.Patient, error) {
+	candidates := make([]*fhirModel.Patient, 0)
+	found := make(map[fhirModel.ID]bool)
+
+	for _, s := range searches {
+		s.Count = entity.PatientMatchCandidatesCount
+
+		patients, err := uc.fhir.SearchPatientByParams(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range patients {
+			// the passed patient may be the registered one
+			if found[c.ID] || (p.ID != "" && c.ID == p.ID) {
+				continue
+			}
+
+			found[c.ID] = true
+			candidates = append(candidates, c)
+		}
+	}
+
+	return candidates, nil
+}
+
+// nameMatchSearches searches the family with the birth date and the family with the first given name
+// with the birth year, the patient birth date is searched alone when it has no family names
+func nameMatchSearches(p *fhirModel.Patient) []*entity.SearchPatientParams {
+	birthDate := p.BirthDate.String()
+	searches := make([]*entity.SearchPatientParams, 0)
+	searched := make(map[string]bool)
+
+	for _, n := range p.Name {
+		if n.Family == "" || searched[strings.ToLower(n.Family)] {
+			continue
+		}
+
+		searched[strings.ToLower(n.Family)] = true
+		searches = append(searches, &entity.SearchPatientParams{Family: n.Family, BirthDate: birthDate})
+
+		if len(n.Given) > 0 && len(birthDate) >= len("2006") {
+			searches = append(searches, &entity.SearchPatientParams{
+				Family: n.Family, Given: n.Given[0], BirthDate: birthDate[:len("2006")],
+			})
+		}
+	}
+
+	if len(searches) == 0 {
+		searches = append(searches, &entity.SearchPatientParams{BirthDate: birthDate})
+	}
+
+	return searches
+}
+
+// matchScore returns the weighted similarity of the fields filled for both patients, the patients having
+// the different documents of the same type are different persons and are scored by zero
+func (uc *UseCase) matchScore(p, c *fhirModel.Patient) float64 {
+	if similarity, ok := matchIdentifiers(p, c); ok && similarity == 0 {
+		return 0
+	}
+
+	w := uc.matchPolicy.Weights
+
+	var score, total float64
+
+	add := func(weight float64, match func(a, b *fhirModel.Patient) (float64, bool)) {
+		if similarity, ok := match(p, c); ok && weight > 0 {
+			score += weight * similarity
+			total += weight
+		}
+	}
+
+	add(w.Name, matchNames)
+	add(w.BirthDate, matchBirthDates)
+	add(w.Gender, matchGenders)
+	add(w.Phone, matchPhones)
+	add(w.Identifier, matchIdentifiers)
+
+	if total == 0 {
+		return 0
+	}
+
+	return math.Round(score/total*10000) / 10000
+}
+
+// matchNames compares the arabic names with the arabic ones and the latin names with the latin ones
+func matchNames(a, b *fhirModel.Patient) (float64, bool) {
+	var (
+		best float64
+		ok   bool
+	)
+
+	for _, na := range a.Name {
+		for _, nb := range b.Name {
+			sa, sb := humanNameString(na), humanNameString(nb)
+			if sa == "" || sb == "" || isArabicName(sa) != isArabicName(sb) {
+				continue
+			}
+
+			ok = true
+
+			if s := nameSimilarity(sa, sb); s > best {
+				best = s
+			}
+		}
+	}
+
+	return best, ok
+}
+
+func humanNameString(n *fhirModel.HumanName) string {
+	if len(n.Given) == 0 && n.Family == "" {
+		return normalizeName(n.Text)
+	}
+
+	return normalizeName(strings.Join(append(append([]string{}, n.Given...), n.Family), " "))
+}
+
+// matchBirthDates scores the swapped day and month and the one different date part as the typos
+func matchBirthDates(a, b *fhirModel.Patient) (float64, bool) {
+	if a.BirthDate == nil || b.BirthDate == nil {
+		return 0, false
+	}
+
+	ta, tb := a.BirthDate.String(), b.BirthDate.String()
+	if ta == tb {
+		return 1, true
+	}
+
+	pa, pb := strings.Split(ta, "-"), strings.Split(tb, "-")
+	if len(pa) != 3 || len(pb) != 3 {
+		return 0, true
+	}
+
+	if pa[0] == pb[0] && pa[1] == pb[2] && pa[2] == pb[1] {
+		return 0.8, true
+	}
+
+	same := 0
+
+	for i := range pa {
+		if pa[i] == pb[i] {
+			same++
+		}
+	}
+
+	if same == 2 {
+		return 0.5, true
+	}
+
+	return 0, true
+}
+
+func matchGenders(a, b *fhirModel.Patient) (float64, bool) {
+	if a.Gender == "" || b.Gender == "" || a.Gender == "unknown" || b.Gender == "unknown" {
+		return 0, false
+	}
+
+	if a.Gender == b.Gender {
+		return 1, true
+	}
+
+	return 0, true
+}
+
+func matchPhones(a, b *fhirModel.Patient) (float64, bool) {
+	pa, pb := phoneSuffixes(a.Telecom), phoneSuffixes(b.Telecom)
+	if len(pa) == 0 || len(pb) == 0 {
+		return 0, false
+	}
+
+	for p := range pa {
+		if pb[p] {
+			return 1, true
+		}
+	}
+
+	return 0, true
+}
+
+func phoneSuffixes(telecom []*fhirModel.ContactPoint) map[string]bool {
+	suffixes := make(map[string]bool)
+
+	for _, t := range telecom {
+		if t.System != fhirModel.TelecomSystemPhone {
+			continue
+		}
+
+		digits := strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+
+			return -1
+		}, t.Value)
+
+		if len(digits) > phoneSuffixLength {
+			digits = digits[len(digits)-phoneSuffixLength:]
+		}
+
+		if digits != "" {
+			suffixes[digits] = true
+		}
+	}
+
+	return suffixes
+}
+
+// matchIdentifiers matches the same document, the documents of the different types are not compared
+func matchIdentifiers(a, b *fhirModel.Patient) (float64, bool) {
+	var ok bool
+
+	for _, ia := range a.Identifier {
+		for _, ib := range b.Identifier {
+			ta, tb := identifierTypeCode(ia), identifierTypeCode(ib)
+			if ia.Value == "" || ib.Value == "" || ta != tb {
+				continue
+			}
+
+			ok = true
+
+			if strings.EqualFold(ia.Value, ib.Value) {
+				return 1, true
+			}
+		}
+	}
+
+	return 0, ok
+}
+
+func identifierTypeCode(i *fhirModel.Identifier) string {
+	if i.Type == nil || len(i.Type.Codings) == 0 {
+		return ""
+	}
+
+	return i.Type.Codings[0].Code
+}
+
+func prepareMatchBundle(matches []*patientMatch) *fhirModel.Bundle {
+	total := len(matches)
+
+	b := &fhirModel.Bundle{
+		Resource: fhirModel.Resource{
+			ID:           fhirModel.ID(uuid.NewV4().String()),
+			ResourceType: fhirModel.ResourceBundle,
+		},
+		Type:  fhirModel.BundleTypeSearchset,
+		Total: &total,
+		Entry: make([]*fhirModel.BundleEntry, 0, len(matches)),
+	}
+
+	for _, m := range matches {
+		score := m.score
+
+		b.Entry = append(b.Entry, &fhirModel.BundleEntry{
+			FullURL:  fmt.Sprintf("%s/%s", fhirModel.ResourcePatient, m.patient.ID),
+			Resource: m.patient,
+			Search: &fhirModel.BundleEntrySearch{
+				Mode:  entity.SearchEntryModeMatch,
+				Score: &score,
+				Extension: []*fhirModel.Extension{{
+					URL:    entity.StructureDefinitionMatchGrade,
+					ValueX: fhirModel.ValueX{ValueCode: converto.StringPointer(m.grade)},
+				}},
+			},
+		})
+	}
+
+	return b
+}
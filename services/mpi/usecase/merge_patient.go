@@ -0,0 +1,328 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/pkg/converto"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
+)
+
+// the contained patient snapshots of the merge task, the $unmerge restores the patients from the pre-merge ones
+// and compares the target with the merged one
+const (
+	mergeSnapshotSource = "source"
+	mergeSnapshotTarget = "target"
+	mergeSnapshotMerged = "merged"
+)
+
+// MergePatients merges the source patient into the target one like the fhir $merge, the source is deactivated
+// and linked to the target, the identifiers and the telecom are copied by the survivorship rules
+func (uc *UseCase) MergePatients(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+	if _, err := uc.fhir.ValidateParameters(ctx, p); err != nil {
+		return nil, err
+	}
+
+	administrator, err := uc.authorizeAdministrator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mp, err := uc.extractPatientPairParams(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := uc.getMergedPatient(ctx, mp.SourceID, "source")
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := uc.getMergedPatient(ctx, mp.TargetID, "target")
+	if err != nil {
+		return nil, err
+	}
+
+	task := preparePatientsTask(p, entity.StructureDefinitionTaskPatientMerge, entity.TaskBusinessStatusPatientsMerged,
+		target.ID, source.ID)
+	addTaskAdministrator(task, administrator)
+	addMergeSnapshot(task, mergeSnapshotSource, source)
+	addMergeSnapshot(task, mergeSnapshotTarget, target)
+
+	uc.mergeIntoTarget(source, target)
+	addMergeSnapshot(task, mergeSnapshotMerged, target)
+
+	_, err = uc.fhir.CreateBundle(ctx, preparePatientsBundle(p, task, source, target))
+	if err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+func (uc *UseCase) extractPatientPairParams(ctx context.Context, p *fhirModel.Parameters) (
+	*entity.PatientPairParameters, error) {
+	mp := new(entity.PatientPairParameters)
+
+	for _, param := range p.Parameter {
+		if param.ValueReference == nil {
+			continue
+		}
+
+		switch param.Name {
+		case "source-patient":
+			mp.SourceID, _ = param.ValueReference.ParseID()
+		case "target-patient":
+			mp.TargetID, _ = param.ValueReference.ParseID()
+		}
+	}
+
+	if mp.SourceID == "" {
+		return nil, cerror.NewValidationError(
+			ctx, map[string]string{"Parameters.parameter": "missing source-patient parameter"}).LogError()
+	}
+
+	if mp.TargetID == "" {
+		return nil, cerror.NewValidationError(
+			ctx, map[string]string{"Parameters.parameter": "missing target-patient parameter"}).LogError()
+	}
+
+	if mp.SourceID == mp.TargetID {
+		return nil, cerror.NewValidationError(
+			ctx, map[string]string{"Parameters.parameter": "source and target patients are the same"}).LogError()
+	}
+
+	return mp, nil
+}
+
+// getMergedPatient returns the active patient which was not merged before
+func (uc *UseCase) getMergedPatient(ctx context.Context, id fhirModel.ID, role string) (*fhirModel.Patient, error) {
+	p, err := uc.getPatientByRole(ctx, id, role)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.validatePatientByInternalRules(ctx, p); err != nil {
+		return nil, err
+	}
+
+	for _, l := range p.Link {
+		if l.Type == entity.PatientLinkReplacedBy {
+			return nil, cerror.NewF(ctx, cerror.KindBadValidation, "%s patient is already merged", role).LogError()
+		}
+	}
+
+	return p, nil
+}
+
+func (uc *UseCase) getPatientByRole(ctx context.Context, id fhirModel.ID, role string) (*fhirModel.Patient, error) {
+	p, err := uc.fhir.GetPatientByID(ctx, id)
+	if err != nil && cerror.ErrKind(err) == cerror.KindNotExist {
+		return nil, cerror.NewF(ctx, cerror.KindNotExist, "%s patient does not exist", role)
+	}
+
+	return p, err
+}
+
+func (uc *UseCase) mergeIntoTarget(source, target *fhirModel.Patient) {
+	target.Identifier = mergeIdentifiers(uc.mergeSurvivorship.Identifier, target.Identifier, source.Identifier)
+	target.Telecom = mergeTelecom(uc.mergeSurvivorship.Telecom, target.Telecom, source.Telecom)
+	target.Link = append(target.Link, &fhirModel.PatientLink{
+		Other: patientReference(source.ID),
+		Type:  entity.PatientLinkReplaces,
+	})
+
+	source.Active = converto.BoolPointer(false)
+	source.Link = append(source.Link, &fhirModel.PatientLink{
+		Other: patientReference(target.ID),
+		Type:  entity.PatientLinkReplacedBy,
+	})
+}
+
+// mergeIdentifiers keeps one identifier of every type, the union rule adds the source types missing in the target
+func mergeIdentifiers(rule string, target, source []*fhirModel.Identifier) []*fhirModel.Identifier {
+	if rule != entity.SurvivorshipUnion && rule != entity.SurvivorshipSource {
+		return target
+	}
+
+	merged := append([]*fhirModel.Identifier(nil), target...)
+
+	for _, s := range source {
+		i := indexOfIdentifierType(merged, identifierTypeCode(s))
+
+		switch {
+		case i < 0:
+			merged = append(merged, s)
+		case rule == entity.SurvivorshipSource:
+			merged[i] = s
+		}
+	}
+
+	return merged
+}
+
+func indexOfIdentifierType(idents []*fhirModel.Identifier, code string) int {
+	for i, ident := range idents {
+		if identifierTypeCode(ident) == code {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// mergeTelecom adds the source contact points missing in the target, the source rule also replaces
+// the target contact points of the same system and use
+func mergeTelecom(rule string, target, source []*fhirModel.ContactPoint) []*fhirModel.ContactPoint {
+	if rule != entity.SurvivorshipUnion && rule != entity.SurvivorshipSource {
+		return target
+	}
+
+	merged := append([]*fhirModel.ContactPoint(nil), target...)
+
+	for _, s := range source {
+		i := indexOfContactPoint(merged, func(t *fhirModel.ContactPoint) bool {
+			if rule == entity.SurvivorshipSource {
+				return t.System == s.System && t.Use == s.Use
+			}
+
+			return t.System == s.System && t.Value == s.Value
+		})
+
+		switch {
+		case i < 0:
+			merged = append(merged, s)
+		case rule == entity.SurvivorshipSource:
+			merged[i] = s
+		}
+	}
+
+	return merged
+}
+
+func indexOfContactPoint(telecom []*fhirModel.ContactPoint, match func(t *fhirModel.ContactPoint) bool) int {
+	for i, t := range telecom {
+		if match(t) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// preparePatientsTask prepares the completed task of the operation on two patients, the task is for the first one
+// and the second one is the task output
+func preparePatientsTask(
+	p *fhirModel.Parameters, profile, businessStatus string, forID, outputID fhirModel.ID) *fhirModel.Task {
+	return &fhirModel.Task{
+		DomainResource: fhirModel.DomainResource{
+			Resource: fhirModel.Resource{
+				ID:           fhirModel.ID(uuid.NewV4().String()),
+				ResourceType: fhirModel.ResourceTask,
+				Meta:         &fhirModel.Meta{Profile: []string{profile}},
+			},
+		},
+		Status:         fhirModel.TaskStatusCompleted,
+		BusinessStatus: &fhirModel.CodeableConcept{Text: businessStatus},
+		Intent:         fhirModel.TaskIntentOrder,
+		For:            patientReference(forID),
+		AuthoredOn:     (*fhirModel.DateTime)(converto.TimePointer(time.Now().UTC())),
+		Input: []*fhirModel.TaskInput{
+			{
+				Type: &fhirModel.CodeableConcept{
+					Codings: []*fhirModel.Coding{
+						{
+							Code:   fhirModel.ResourceParameters,
+							System: fhirModel.CodingSystemResourceTypes,
+						},
+					},
+				},
+				ValueX: fhirModel.ValueX{
+					ValueReference: &fhirModel.Reference{
+						Reference: fmt.Sprintf("%s/%s", fhirModel.ResourceParameters, p.ID),
+					},
+				},
+			},
+		},
+		Output: []*fhirModel.TaskOutput{
+			{
+				Type: &fhirModel.CodeableConcept{
+					Codings: []*fhirModel.Coding{
+						{
+							Code:   fhirModel.ResourcePatient,
+							System: fhirModel.CodingSystemResourceTypes,
+						},
+					},
+				},
+				ValueX: fhirModel.ValueX{ValueReference: patientReference(outputID)},
+			},
+		},
+	}
+}
+
+// addMergeSnapshot keeps the patient values changed by the merge in the task and refers them in the task input
+func addMergeSnapshot(t *fhirModel.Task, id string, p *fhirModel.Patient) {
+	t.Contained = append(t.Contained, &fhirModel.Patient{
+		DomainResource: fhirModel.DomainResource{
+			Resource: fhirModel.Resource{ID: fhirModel.ID(id), ResourceType: fhirModel.ResourcePatient},
+		},
+		Active:     p.Active,
+		Identifier: append([]*fhirModel.Identifier(nil), p.Identifier...),
+		Telecom:    append([]*fhirModel.ContactPoint(nil), p.Telecom...),
+		Link:       append([]*fhirModel.PatientLink(nil), p.Link...),
+	})
+
+	t.Input = append(t.Input, &fhirModel.TaskInput{
+		Type: &fhirModel.CodeableConcept{
+			Codings: []*fhirModel.Coding{
+				{
+					Code:   fhirModel.ResourcePatient,
+					System: fhirModel.CodingSystemResourceTypes,
+				},
+			},
+		},
+		ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "#" + id}},
+	})
+}
+
+func preparePatientsBundle(
+	p *fhirModel.Parameters, t *fhirModel.Task, source, target *fhirModel.Patient) *fhirModel.Bundle {
+	//nolint:dupl
+	return &fhirModel.Bundle{
+		Resource: fhirModel.Resource{
+			ID:           fhirModel.ID(uuid.NewV4().String()),
+			ResourceType: fhirModel.ResourceBundle,
+		},
+		Type: fhirModel.BundleTypeTransaction,
+		Entry: []*fhirModel.BundleEntry{
+			{
+				Resource: p,
+				Request:  &fhirModel.BundleEntryRequest{Method: http.MethodPost, URL: fhirModel.ResourceParameters},
+			},
+			{
+				Resource: t,
+				Request:  &fhirModel.BundleEntryRequest{Method: http.MethodPost, URL: fhirModel.ResourceTask},
+			},
+			{
+				Resource: source,
+				Request: &fhirModel.BundleEntryRequest{
+					Method: http.MethodPut,
+					URL:    fmt.Sprintf("%s/%s", fhirModel.ResourcePatient, source.ID),
+				},
+			},
+			{
+				Resource: target,
+				Request: &fhirModel.BundleEntryRequest{
+					Method: http.MethodPut,
+					URL:    fmt.Sprintf("%s/%s", fhirModel.ResourcePatient, target.ID),
+				},
+			},
+		},
+	}
+}
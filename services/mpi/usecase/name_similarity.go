@@ -0,0 +1,179 @@
+package usecase
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// arabicLetterVariants maps the letters written differently in the same name to one letter
+var arabicLetterVariants = map[rune]rune{
+	'أ': 'ا',
+	'إ': 'ا',
+	'آ': 'ا',
+	'ٱ': 'ا',
+	'ة': 'ه',
+	'ى': 'ي',
+	'ؤ': 'و',
+	'ئ': 'ي',
+}
+
+// normalizeName lower cases the latin name, drops the arabic diacritics and tatweel, unifies the arabic letter
+// variants and keeps the letters separated by single spaces
+func normalizeName(name string) string {
+	var b strings.Builder
+
+	space := false
+
+	for _, r := range name {
+		switch {
+		case isArabicDiacritic(r) || r == 'ـ':
+			continue
+		case unicode.IsLetter(r):
+			if space && b.Len() > 0 {
+				b.WriteRune(' ')
+			}
+
+			space = false
+
+			if v, ok := arabicLetterVariants[r]; ok {
+				r = v
+			}
+
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			space = true
+		}
+	}
+
+	return b.String()
+}
+
+func isArabicDiacritic(r rune) bool {
+	return (r >= 'ً' && r <= 'ْ') || r == 'ٰ'
+}
+
+func isArabicName(name string) bool {
+	for _, r := range name {
+		if unicode.Is(unicode.Arabic, r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nameSimilarity compares the normalized names as is and with the sorted parts,
+// so the given and family names swapped by the registrar still match
+func nameSimilarity(a, b string) float64 {
+	s := jaroWinkler(a, b)
+
+	if sorted := jaroWinkler(sortNameParts(a), sortNameParts(b)); sorted > s {
+		return sorted
+	}
+
+	return s
+}
+
+func sortNameParts(name string) string {
+	parts := strings.Fields(name)
+	sort.Strings(parts)
+
+	return strings.Join(parts, " ")
+}
+
+// jaroWinkler returns the Jaro-Winkler similarity of the strings from 0 to 1
+func jaroWinkler(a, b string) float64 {
+	const (
+		prefixScale = 0.1
+		maxPrefix   = 4
+	)
+
+	ra, rb := []rune(a), []rune(b)
+
+	j := jaro(ra, rb)
+
+	prefix := 0
+	for prefix < len(ra) && prefix < len(rb) && prefix < maxPrefix && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+
+	return j + float64(prefix)*prefixScale*(1-j)
+}
+
+func jaro(a, b []rune) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	matchDistance := maxInt(len(a), len(b))/2 - 1
+	if matchDistance < 0 {
+		matchDistance = 0
+	}
+
+	aMatches := make([]bool, len(a))
+	bMatches := make([]bool, len(b))
+
+	matches := 0
+
+	for i := range a {
+		from, to := maxInt(0, i-matchDistance), minInt(len(b), i+matchDistance+1)
+
+		for k := from; k < to; k++ {
+			if bMatches[k] || a[i] != b[k] {
+				continue
+			}
+
+			aMatches[i], bMatches[k] = true, true
+			matches++
+
+			break
+		}
+	}
+
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions, k := 0, 0
+
+	for i := range a {
+		if !aMatches[i] {
+			continue
+		}
+
+		for !bMatches[k] {
+			k++
+		}
+
+		if a[i] != b[k] {
+			transpositions++
+		}
+
+		k++
+	}
+
+	m := float64(matches)
+
+	return (m/float64(len(a)) + m/float64(len(b)) + (m-float64(transpositions)/2)/m) / 3
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,65 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/services/mpi/entity"
+)
+
+func (uc *UseCase) generateOTPByPhone(ctx context.Context, phone, processID string) (*entity.OTP, error) {
+	if err := uc.limitOTP(ctx, "phone:"+phone, processID); err != nil {
+		return nil, err
+	}
+
+	return uc.otp.GenerateByPhone(ctx, phone, processID)
+}
+
+func (uc *UseCase) generateOTPByEmail(ctx context.Context, email, processID string) (*entity.OTP, error) {
+	if err := uc.limitOTP(ctx, "email:"+email, processID); err != nil {
+		return nil, err
+	}
+
+	return uc.otp.GenerateByEmail(ctx, email, processID)
+}
+
+// limitOTP counts the otp generation for the value, the patient request and the api consumer,
+// so a client calling the operations in a loop can not flood one number with the sms
+func (uc *UseCase) limitOTP(ctx context.Context, valueKey, processID string) error {
+	if uc.rateLimiter == nil {
+		return nil
+	}
+
+	keys := make([]*entity.RateLimitKey, 0, 3)
+	keys = appendRateLimitKey(keys, valueKey, uc.otpRateLimits.Value)
+	keys = appendRateLimitKey(keys, "process:"+processID, uc.otpRateLimits.Process)
+
+	if consumer, ok := ctx.Value(entity.HeaderConsumerID).(string); ok && consumer != "" {
+		keys = appendRateLimitKey(keys, "consumer:"+consumer, uc.otpRateLimits.Consumer)
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	retryAfter, err := uc.rateLimiter.Allow(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	if retryAfter > 0 {
+		return cerror.NewF(ctx, cerror.KindFromHTTPCode(http.StatusTooManyRequests), "too many otp requests").
+			WithPayload(&entity.RateLimitExceeded{RetryAfter: retryAfter}).LogError()
+	}
+
+	return nil
+}
+
+func appendRateLimitKey(keys []*entity.RateLimitKey, key string, l entity.RateLimit) []*entity.RateLimitKey {
+	if l.Count <= 0 || l.Window <= 0 {
+		return keys
+	}
+
+	return append(keys, &entity.RateLimitKey{Key: key, Limit: l})
+}
@@ -0,0 +1,84 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
+)
+
+// searchPossibleDuplicates scores the registered patients like the $match and returns the ones graded
+// at least as possible, the exact identifier duplicates are rejected before, so the candidates are searched
+// by the names only
+func (uc *UseCase) searchPossibleDuplicates(ctx context.Context, p *fhirModel.Patient) ([]*patientMatch, error) {
+	if uc.duplicatePolicy == "" || uc.duplicatePolicy == entity.PossibleDuplicatePolicyOff ||
+		p.BirthDate == nil || len(p.Name) == 0 {
+		return nil, nil
+	}
+
+	candidates, err := uc.searchCandidates(ctx, p, nameMatchSearches(p))
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]*patientMatch, 0)
+
+	for _, c := range candidates {
+		score := uc.matchScore(p, c)
+
+		if grade := uc.matchPolicy.Grade(score); grade != entity.MatchGradeCertainlyNot {
+			matches = append(matches, &patientMatch{patient: c, score: score, grade: grade})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+	return matches, nil
+}
+
+func possibleDuplicateIssues(patientParamIndex int, matches []*patientMatch) []*fhirModel.OperationOutcomeIssue {
+	issues := make([]*fhirModel.OperationOutcomeIssue, 0, len(matches))
+
+	for _, m := range matches {
+		issues = append(issues, &fhirModel.OperationOutcomeIssue{
+			Severity: entity.OutcomeIssueSeverityWarning,
+			Code:     entity.OutcomeIssueCodeDuplicate,
+			Diagnostics: fmt.Sprintf("possible duplicate of %s/%s, match grade %s, score %.2f",
+				fhirModel.ResourcePatient, m.patient.ID, m.grade, m.score),
+			Expression: []string{fmt.Sprintf("Parameters.parameter[%d].resource", patientParamIndex)},
+		})
+	}
+
+	return issues
+}
+
+// flagPossibleDuplicates refers the candidates in the task output, the hold policy also marks the task
+// to be held for the review once the otp is confirmed
+func (uc *UseCase) flagPossibleDuplicates(t *fhirModel.Task, matches []*patientMatch) {
+	if len(matches) == 0 {
+		return
+	}
+
+	for _, m := range matches {
+		t.Output = append(t.Output, &fhirModel.TaskOutput{
+			Type: taskReasonConcept(entity.TaskReasonPossibleDuplicate),
+			ValueX: fhirModel.ValueX{
+				ValueReference: &fhirModel.Reference{
+					Reference: fmt.Sprintf("%s/%s", fhirModel.ResourcePatient, m.patient.ID),
+				},
+			},
+		})
+	}
+
+	if uc.duplicatePolicy == entity.PossibleDuplicatePolicyHold {
+		markTaskForReview(t, entity.TaskReasonPossibleDuplicate)
+	}
+}
+
+func taskReasonConcept(code string) *fhirModel.CodeableConcept {
+	return &fhirModel.CodeableConcept{
+		Codings: []*fhirModel.Coding{{System: entity.CodeSystemTaskReason, Code: code}},
+	}
+}
@@ -0,0 +1,257 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/pkg/converto"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
+)
+
+func (uc *UseCase) ResendOTP(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+	if _, err := uc.fhir.ValidateParameters(ctx, p); err != nil {
+		return nil, err
+	}
+
+	taskID, err := uc.extractResendOTPParams(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := uc.getTaskByID(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasTaskProfile(t, entity.PendingTaskProfiles()...) {
+		return nil, cerror.NewF(ctx, cerror.KindBadValidation, "otp for such patient request can not be resent").LogError()
+	}
+
+	err = uc.validateConfirmRequestTask(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	err = uc.validateOTPResend(ctx, t, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasTaskProfile(t, fhirModel.StructureDefinitionTaskPatientUpdateEmail) {
+		err = uc.resendEmailOTP(ctx, t)
+	} else {
+		err = uc.resendPhoneOTP(ctx, t)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	t.Extension = append(t.Extension, &fhirModel.Extension{
+		URL:    entity.StructureDefinitionTaskOTPResent,
+		ValueX: fhirModel.ValueX{ValueDateTime: (*fhirModel.DateTime)(converto.TimePointer(now))},
+	})
+
+	if err := uc.saveTaskBundle(ctx, t, p); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (uc *UseCase) extractResendOTPParams(ctx context.Context, p *fhirModel.Parameters) (fhirModel.ID, error) {
+	var taskRef *fhirModel.Reference
+
+	for _, param := range p.Parameter {
+		if param.Name == "task_id" {
+			taskRef = param.ValueReference
+		}
+	}
+
+	if taskRef == nil {
+		return "", cerror.NewValidationError(
+			ctx, map[string]string{"Parameters.parameter": "missing task_id parameter"}).LogError()
+	}
+
+	taskID, ok := taskRef.ParseID()
+	if !ok {
+		return "", cerror.NewValidationError(
+			ctx, map[string]string{"Parameters.parameter": "invalid task_id parameter format"}).LogError()
+	}
+
+	return taskID, nil
+}
+
+// validateOTPResend checks the resend limit and the cooldown since the last otp sent for the task
+func (uc *UseCase) validateOTPResend(ctx context.Context, t *fhirModel.Task, now time.Time) error {
+	var (
+		count    int
+		lastSent time.Time
+	)
+
+	if t.AuthoredOn != nil {
+		lastSent = t.AuthoredOn.Time()
+	}
+
+	for _, e := range t.Extension {
+		if e.URL != entity.StructureDefinitionTaskOTPResent || e.ValueDateTime == nil {
+			continue
+		}
+
+		count++
+
+		if sent := e.ValueDateTime.Time(); sent.After(lastSent) {
+			lastSent = sent
+		}
+	}
+
+	if count >= entity.OTPResendMaxCount {
+		return cerror.NewF(ctx, cerror.KindBadValidation, "otp resend limit for such patient request is reached").LogError()
+	}
+
+	if wait := lastSent.Add(entity.OTPResendCooldown).Sub(now); wait > 0 {
+		return cerror.NewF(
+			ctx,
+			cerror.KindBadValidation,
+			"otp can be resent in %d seconds",
+			int(wait.Round(time.Second).Seconds())).
+			LogError()
+	}
+
+	return nil
+}
+
+func (uc *UseCase) resendPhoneOTP(ctx context.Context, t *fhirModel.Task) error {
+	phone, err := uc.getTaskPhone(ctx, t)
+	if err != nil {
+		return err
+	}
+
+	otp, err := uc.generateOTPByPhone(ctx, phone, string(t.ID))
+	if err != nil {
+		return err
+	}
+
+	language := taskOTPLanguage(t)
+
+	err = uc.sendOTP(ctx, t, otp, language)
+	if err != nil {
+		return err
+	}
+
+	return uc.resendOldPhoneOTP(ctx, t, language)
+}
+
+// resendEmailOTP generates the otp of the pending patient email again, it is delivered by the otp service
+func (uc *UseCase) resendEmailOTP(ctx context.Context, t *fhirModel.Task) error {
+	var patientID fhirModel.ID
+	if t.For != nil {
+		patientID, _ = t.For.ParseID()
+	}
+
+	patient, err := uc.fhir.GetPatientByID(ctx, patientID)
+	if err != nil {
+		return err
+	}
+
+	for _, telecom := range patient.Telecom {
+		if telecom.System != fhirModel.TelecomSystemEmail || !isPendingContactPoint(telecom) {
+			continue
+		}
+
+		otp, err := uc.generateOTPByEmail(ctx, telecom.Value, string(t.ID))
+		if err != nil {
+			return err
+		}
+
+		return uc.checkGeneratedOTP(ctx, otp)
+	}
+
+	return cerror.NewF(ctx, cerror.KindBadValidation, "patient has no email to confirm").LogError()
+}
+
+// resendOldPhoneOTP resends the otp of the current patient phone when the phone change task requires its confirmation,
+// otherwise the task could not be confirmed after the first old phone otp expires
+func (uc *UseCase) resendOldPhoneOTP(ctx context.Context, t *fhirModel.Task, language string) error {
+	if !hasTaskProfile(t, entity.StructureDefinitionTaskPatientUpdatePhone) {
+		return nil
+	}
+
+	params, err := uc.getTaskParameters(ctx, t, 0)
+	if err != nil {
+		return err
+	}
+
+	phoneParams, err := uc.extractUpdatePatientPhoneParams(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	if !phoneParams.ConfirmOldPhone {
+		return nil
+	}
+
+	var patientID fhirModel.ID
+	if t.For != nil {
+		patientID, _ = t.For.ParseID()
+	}
+
+	patient, err := uc.fhir.GetPatientByID(ctx, patientID)
+	if err != nil {
+		return err
+	}
+
+	oldTelecom := findMobilePhone(patient)
+	if oldTelecom == nil {
+		return cerror.NewF(ctx, cerror.KindBadValidation, "patient has no phone to confirm").LogError()
+	}
+
+	otp, err := uc.generateOTPByPhone(ctx, oldTelecom.Value, oldPhoneProcessID(t.ID))
+	if err != nil {
+		return err
+	}
+
+	return uc.sendOTP(ctx, t, otp, language)
+}
+
+// getTaskPhone returns the phone number the otp of the task was sent to
+func (uc *UseCase) getTaskPhone(ctx context.Context, t *fhirModel.Task) (string, error) {
+	params, err := uc.getTaskParameters(ctx, t, 0)
+	if err != nil {
+		return "", err
+	}
+
+	if hasTaskProfile(t, entity.StructureDefinitionTaskPatientUpdatePhone) {
+		phoneParams, err := uc.extractUpdatePatientPhoneParams(ctx, params)
+		if err != nil {
+			return "", err
+		}
+
+		return phoneParams.Phone, nil
+	}
+
+	if hasTaskProfile(t, fhirModel.StructureDefinitionTaskPatientUpdateIdentity) {
+		identityParams, err := uc.extractUpdatePatientIdentityParams(ctx, params)
+		if err != nil {
+			return "", err
+		}
+
+		return identityParams.ConfirmationMethod, nil
+	}
+
+	patient, err := uc.unmarshalPatientParam(ctx, params, 0)
+	if err != nil {
+		return "", err
+	}
+
+	telecom, err := uc.extractTelecomFromCreatePatientParams(ctx, patient)
+	if err != nil {
+		return "", err
+	}
+
+	return telecom.Value, nil
+}
@@ -0,0 +1,451 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/pkg/converto"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
+)
+
+// SearchReviewTasks returns the patient requests held for the manual review, all the reasons are searched by default
+func (uc *UseCase) SearchReviewTasks(ctx context.Context, params *entity.SearchReviewTaskParams) (
+	*fhirModel.Bundle, error) {
+	if _, err := uc.authorizeReviewer(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := uc.validateSearchReviewTaskParams(ctx, params); err != nil {
+		return nil, err
+	}
+
+	if params.Count == 0 {
+		params.Count = entity.ReviewTaskDefaultCount
+	}
+
+	// one extra task is requested to find out whether the next page exists
+	tasks, err := uc.fhir.SearchTaskByParams(ctx, &entity.SearchTaskParams{
+		StatusReason: fmt.Sprintf("%s|%s", entity.CodeSystemTaskReason, params.Reason),
+		Status:       fhirModel.TaskStatusOnHold,
+		Profiles:     entity.ReviewTaskProfiles(),
+		Sort:         taskSortAuthoredOn,
+		Count:        params.Count + 1,
+		Offset:       params.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hasNext := len(tasks) > params.Count
+	if hasNext {
+		tasks = tasks[:params.Count]
+	}
+
+	b := &fhirModel.Bundle{
+		Resource: fhirModel.Resource{
+			ID:           fhirModel.ID(uuid.NewV4().String()),
+			ResourceType: fhirModel.ResourceBundle,
+		},
+		Type:  fhirModel.BundleTypeSearchset,
+		Entry: []*fhirModel.BundleEntry{},
+		Link: []*fhirModel.BundleLink{
+			{Relation: entity.BundleLinkSelf, URL: searchReviewTaskPageURL(params, params.Offset)},
+		},
+	}
+
+	if hasNext {
+		b.Link = append(b.Link, &fhirModel.BundleLink{
+			Relation: entity.BundleLinkNext,
+			URL:      searchReviewTaskPageURL(params, params.Offset+params.Count),
+		})
+	}
+
+	if params.Offset > 0 {
+		prevOffset := params.Offset - params.Count
+		if prevOffset < 0 {
+			prevOffset = 0
+		}
+
+		b.Link = append(b.Link, &fhirModel.BundleLink{
+			Relation: entity.BundleLinkPrevious,
+			URL:      searchReviewTaskPageURL(params, prevOffset),
+		})
+	}
+
+	for _, t := range tasks {
+		if !isReviewTask(t) || (params.Reason != "" && taskHoldReason(t) != params.Reason) {
+			continue
+		}
+
+		b.Entry = append(b.Entry, &fhirModel.BundleEntry{
+			FullURL:  fmt.Sprintf("%s/%s", fhirModel.ResourceTask, t.ID),
+			Resource: t,
+			Search:   &fhirModel.BundleEntrySearch{Mode: entity.SearchEntryModeMatch},
+		})
+	}
+
+	return b, nil
+}
+
+func searchReviewTaskPageURL(params *entity.SearchReviewTaskParams, offset int) string {
+	q := url.Values{}
+
+	if params.Reason != "" {
+		q.Set("reason", params.Reason)
+	}
+
+	q.Set("_count", strconv.Itoa(params.Count))
+	q.Set("_offset", strconv.Itoa(offset))
+
+	return fmt.Sprintf("%s/$review-queue?%s", fhirModel.ResourceTask, q.Encode())
+}
+
+// ApproveReviewTask completes the held request the same way the otp confirmation does
+func (uc *UseCase) ApproveReviewTask(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (
+	*fhirModel.Task, error) {
+	if _, err := uc.fhir.ValidateParameters(ctx, p); err != nil {
+		return nil, err
+	}
+
+	reviewer, err := uc.authorizeReviewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := uc.getReviewTask(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	patientParams, err := uc.getTaskParameters(ctx, t, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case hasTaskProfile(t, entity.StructureDefinitionTaskPatientUpdatePhone):
+		err = uc.approveUpdatePatientPhone(ctx, t, reviewer, patientParams, p)
+	case hasTaskProfile(t, fhirModel.StructureDefinitionTaskPatientUpdateIdentity):
+		err = uc.approveUpdatePatientIdentity(ctx, t, reviewer, patientParams, p)
+	default:
+		err = uc.approveCreatePatient(ctx, t, reviewer, patientParams, p)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (uc *UseCase) approveCreatePatient(
+	ctx context.Context,
+	t *fhirModel.Task,
+	reviewer string,
+	patientParams, p *fhirModel.Parameters) error {
+	patient, err := uc.unmarshalPatientParam(ctx, patientParams, 0)
+	if err != nil {
+		return err
+	}
+
+	// the same person could be registered by another request while this one was held
+	err = uc.validatePatientDupls(ctx, patient)
+	if err != nil {
+		if cerror.ErrKind(err) == cerror.KindBadValidation {
+			uc.rejectDuplicateReviewTask(ctx, t, reviewer, p)
+		}
+
+		return err
+	}
+
+	// the decision is recorded once the request passed the checks
+	addTaskReview(t, reviewer, entity.ReviewDecisionApproved, extractReviewComment(p))
+
+	t.StatusReason = nil
+	uc.updateConfirmCreatePatientTask(t, patient, p)
+
+	_, err = uc.saveConfirmCreatePatientBundle(ctx, t, patient, p)
+
+	return err
+}
+
+// approveUpdatePatientIdentity updates the patient of the held request, the same identifiers could be registered
+// by another request while this one was held
+func (uc *UseCase) approveUpdatePatientIdentity(
+	ctx context.Context,
+	t *fhirModel.Task,
+	reviewer string,
+	resourceParams, p *fhirModel.Parameters) error {
+	patientParams, err := uc.extractUpdatePatientIdentityParams(ctx, resourceParams)
+	if err != nil {
+		return err
+	}
+
+	dbPatient, err := uc.fhir.GetPatientByID(ctx, patientParams.Patient.ID)
+	if err != nil {
+		return err
+	}
+
+	err = uc.validatePatientByInternalRules(ctx, dbPatient)
+	if err != nil {
+		return err
+	}
+
+	err = uc.validatePatientDupls(ctx, patientParams.Patient)
+	if err != nil {
+		if cerror.ErrKind(err) == cerror.KindBadValidation {
+			uc.rejectDuplicateReviewTask(ctx, t, reviewer, p)
+		}
+
+		return err
+	}
+
+	addTaskReview(t, reviewer, entity.ReviewDecisionApproved, extractReviewComment(p))
+
+	t.StatusReason = nil
+
+	patient, err := uc.mergePatient(ctx, dbPatient, patientParams.Patient)
+	if err != nil {
+		return err
+	}
+
+	uc.updateConfirmUpdatePatientTask(t, entity.TaskBusinessStatusConfirmPatientIdentityUpdated, patient, p)
+
+	_, err = uc.saveConfirmUpdatePatientIdentityBundle(ctx, t, patient, p)
+
+	return err
+}
+
+// rejectDuplicateReviewTask rejects the held request of the person registered while the request was held
+func (uc *UseCase) rejectDuplicateReviewTask(
+	ctx context.Context, t *fhirModel.Task, reviewer string, p *fhirModel.Parameters) {
+	addTaskReview(t, reviewer, entity.ReviewDecisionRejected, errMsgPatientExists)
+	t.StatusReason = &fhirModel.CodeableConcept{Text: errMsgPatientExists}
+	_ = uc.rejectTask(ctx, t, p)
+}
+
+// approveUpdatePatientPhone sets the phone of the held request, the approval fails if the patient was deactivated
+// or merged while the request was held, such request is rejected by the reviewer
+func (uc *UseCase) approveUpdatePatientPhone(
+	ctx context.Context,
+	t *fhirModel.Task,
+	reviewer string,
+	phoneParams, p *fhirModel.Parameters) error {
+	params, err := uc.extractUpdatePatientPhoneParams(ctx, phoneParams)
+	if err != nil {
+		return err
+	}
+
+	patientID, _ := t.For.ParseID()
+
+	dbPatient, err := uc.fhir.GetPatientByID(ctx, patientID)
+	if err != nil {
+		return err
+	}
+
+	err = uc.validatePatientByInternalRules(ctx, dbPatient)
+	if err != nil {
+		return err
+	}
+
+	addTaskReview(t, reviewer, entity.ReviewDecisionApproved, extractReviewComment(p))
+
+	t.StatusReason = nil
+
+	setPatientPhone(dbPatient, params.Phone)
+	uc.updateConfirmUpdatePatientTask(t, entity.TaskBusinessStatusPatientPhoneUpdated, dbPatient, p)
+
+	_, err = uc.saveConfirmUpdatePatientIdentityBundle(ctx, t, dbPatient, p)
+
+	return err
+}
+
+// RejectReviewTask rejects the held request, the reason is required like for the request cancellation
+func (uc *UseCase) RejectReviewTask(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (
+	*fhirModel.Task, error) {
+	if _, err := uc.fhir.ValidateParameters(ctx, p); err != nil {
+		return nil, err
+	}
+
+	reviewer, err := uc.authorizeReviewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reason, err := uc.extractCancelTaskReason(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := uc.getReviewTask(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	addTaskReview(t, reviewer, entity.ReviewDecisionRejected, reason)
+
+	t.BusinessStatus = &fhirModel.CodeableConcept{Text: entity.TaskBusinessStatusRejectedByReviewer}
+	t.StatusReason = &fhirModel.CodeableConcept{Text: reason}
+
+	if err := uc.rejectTask(ctx, t, p); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// authorizeReviewer returns the api consumer of the request if it is allowed to review the held requests
+func (uc *UseCase) authorizeReviewer(ctx context.Context) (string, error) {
+	return authorizeConsumer(ctx, uc.reviewers, "the api consumer is not allowed to review patient requests")
+}
+
+// authorizeAdministrator returns the api consumer of the request if it is allowed to change the patient records
+// by the operations which skip the patient otp confirmation
+func (uc *UseCase) authorizeAdministrator(ctx context.Context) (string, error) {
+	return authorizeConsumer(ctx, uc.administrators, "the api consumer is not allowed to administer patients")
+}
+
+func authorizeConsumer(ctx context.Context, allowed []string, forbiddenMsg string) (string, error) {
+	consumer, _ := ctx.Value(entity.HeaderConsumerID).(string)
+	if consumer != "" && contains(allowed, consumer) {
+		return consumer, nil
+	}
+
+	return "", cerror.NewF(ctx, cerror.KindFromHTTPCode(http.StatusForbidden), forbiddenMsg).LogError()
+}
+
+func (uc *UseCase) validateSearchReviewTaskParams(ctx context.Context, params *entity.SearchReviewTaskParams) error {
+	if params.Reason != "" && !contains(entity.TaskReviewReasons(), params.Reason) {
+		return cerror.NewValidationError(ctx, map[string]string{"reason": "unsupported value"}).LogError()
+	}
+
+	if params.Count < 0 || params.Count > entity.ReviewTaskMaxCount {
+		return cerror.NewValidationError(ctx, map[string]string{
+			"_count": fmt.Sprintf("expected to be from 0 to %d", entity.ReviewTaskMaxCount),
+		}).LogError()
+	}
+
+	if params.Offset < 0 {
+		return cerror.NewValidationError(ctx, map[string]string{"_offset": "should not be negative"}).LogError()
+	}
+
+	return nil
+}
+
+func (uc *UseCase) getReviewTask(ctx context.Context, id fhirModel.ID) (*fhirModel.Task, error) {
+	t, err := uc.getTaskByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isReviewTask(t) {
+		return nil, cerror.NewF(ctx, cerror.KindBadValidation, "such patient request is not held for review").LogError()
+	}
+
+	return t, nil
+}
+
+func isReviewTask(t *fhirModel.Task) bool {
+	return t.Status == fhirModel.TaskStatusOnHold && hasTaskProfile(t, entity.ReviewTaskProfiles()...) &&
+		taskHoldReason(t) != ""
+}
+
+// holdTask stops the request until the manual review, the first hold reason is kept
+func holdTask(t *fhirModel.Task, reason, businessStatus string) {
+	if t.Status == fhirModel.TaskStatusOnHold {
+		return
+	}
+
+	t.Status = fhirModel.TaskStatusOnHold
+	t.StatusReason = taskReasonConcept(reason)
+	t.BusinessStatus = &fhirModel.CodeableConcept{Text: businessStatus}
+}
+
+// markTaskForReview keeps the reason in the in-progress task, the task is held when its otp is confirmed
+// because the reviewer approves the requests confirmed by the patient only. The first reason is kept
+func markTaskForReview(t *fhirModel.Task, reason string) {
+	if taskHoldReason(t) != "" {
+		return
+	}
+
+	t.StatusReason = taskReasonConcept(reason)
+}
+
+func reviewBusinessStatus(reason string) string {
+	switch reason {
+	case entity.TaskReasonRegistryMismatch:
+		return entity.TaskBusinessStatusRegistryMismatchReview
+	case entity.TaskReasonPossibleDuplicate:
+		return entity.TaskBusinessStatusPossibleDuplicate
+	default:
+		return entity.TaskBusinessStatusFraudReview
+	}
+}
+
+func taskHoldReason(t *fhirModel.Task) string {
+	if t.StatusReason == nil {
+		return ""
+	}
+
+	for _, c := range t.StatusReason.Codings {
+		if c.System == entity.CodeSystemTaskReason {
+			return c.Code
+		}
+	}
+
+	return ""
+}
+
+// addTaskReview records the reviewer and the decision in the task, the hold reason is kept there
+// because the status reason is replaced when the review is done
+func addTaskReview(t *fhirModel.Task, reviewer, decision, comment string) {
+	e := &fhirModel.Extension{
+		URL: entity.StructureDefinitionTaskReview,
+		Extension: []*fhirModel.Extension{
+			{URL: "reviewer", ValueX: fhirModel.ValueX{ValueString: converto.StringPointer(reviewer)}},
+			{URL: "decision", ValueX: fhirModel.ValueX{ValueCode: converto.StringPointer(decision)}},
+			{URL: "reason", ValueX: fhirModel.ValueX{ValueCode: converto.StringPointer(taskHoldReason(t))}},
+			{URL: "reviewedAt", ValueX: fhirModel.ValueX{
+				ValueDateTime: (*fhirModel.DateTime)(converto.TimePointer(time.Now().UTC())),
+			}},
+		},
+	}
+
+	if comment != "" {
+		e.Extension = append(e.Extension,
+			&fhirModel.Extension{URL: "comment", ValueX: fhirModel.ValueX{ValueString: converto.StringPointer(comment)}})
+	}
+
+	t.Extension = append(t.Extension, e)
+}
+
+// addTaskAdministrator records the administrator who changed the patient records by the task
+func addTaskAdministrator(t *fhirModel.Task, administrator string) {
+	t.Extension = append(t.Extension, &fhirModel.Extension{
+		URL: entity.StructureDefinitionTaskAdministration,
+		Extension: []*fhirModel.Extension{
+			{URL: "administrator", ValueX: fhirModel.ValueX{ValueString: converto.StringPointer(administrator)}},
+			{URL: "performedAt", ValueX: fhirModel.ValueX{
+				ValueDateTime: (*fhirModel.DateTime)(converto.TimePointer(time.Now().UTC())),
+			}},
+		},
+	})
+}
+
+func extractReviewComment(p *fhirModel.Parameters) string {
+	for _, param := range p.Parameter {
+		if param.Name == "comment" {
+			return converto.StringValue(param.ValueString)
+		}
+	}
+
+	return ""
+}
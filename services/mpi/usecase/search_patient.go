@@ -0,0 +1,145 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	uuid "github.com/satori/go.uuid"
+
+	"wasfaty.api/pkg/cerror"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
+)
+
+func (uc *UseCase) SearchPatients(ctx context.Context, params *entity.SearchPatientParams) (*fhirModel.Bundle, error) {
+	if err := uc.validateSearchPatientParams(ctx, params); err != nil {
+		return nil, err
+	}
+
+	if params.Count == 0 {
+		params.Count = entity.PatientSearchDefaultCount
+	}
+
+	if params.Count > entity.PatientSearchMaxCount {
+		params.Count = entity.PatientSearchMaxCount
+	}
+
+	if params.Offset+params.Count > entity.PatientSearchMaxResults {
+		params.Count = entity.PatientSearchMaxResults - params.Offset
+	}
+
+	// one extra patient is requested to find out whether the next page exists
+	pageParams := *params
+	pageParams.Count++
+
+	patients, err := uc.fhir.SearchPatientByParams(ctx, &pageParams)
+	if err != nil {
+		return nil, err
+	}
+
+	hasNext := len(patients) > params.Count
+	if hasNext {
+		patients = patients[:params.Count]
+	}
+
+	// the next page offset would be rejected, the results beyond the max are not available
+	if params.Offset+params.Count >= entity.PatientSearchMaxResults {
+		hasNext = false
+	}
+
+	return prepareSearchPatientBundle(params, patients, hasNext), nil
+}
+
+func (uc *UseCase) validateSearchPatientParams(ctx context.Context, params *entity.SearchPatientParams) error {
+	if (params.Identifier == nil) == (params.Phone == nil) {
+		return cerror.NewValidationError(
+			ctx, map[string]string{"identifier": "either identifier or phone with birthdate should be passed"}).LogError()
+	}
+
+	if params.Identifier != nil && params.Identifier.Value == "" {
+		return cerror.NewValidationError(ctx, map[string]string{"identifier": "value is required"}).LogError()
+	}
+
+	if params.Phone != nil && (params.Phone.Phone == "" || params.Phone.BirthDate == "") {
+		return cerror.NewValidationError(
+			ctx, map[string]string{"phone": "phone and birthdate should be passed together"}).LogError()
+	}
+
+	if params.Count < 0 {
+		return cerror.NewValidationError(ctx, map[string]string{"_count": "should not be negative"}).LogError()
+	}
+
+	if params.Offset < 0 || params.Offset >= entity.PatientSearchMaxResults {
+		return cerror.NewValidationError(ctx, map[string]string{
+			"_offset": fmt.Sprintf("should be in range [0, %d)", entity.PatientSearchMaxResults),
+		}).LogError()
+	}
+
+	return nil
+}
+
+func prepareSearchPatientBundle(
+	params *entity.SearchPatientParams,
+	patients []*fhirModel.Patient,
+	hasNext bool) *fhirModel.Bundle {
+	b := &fhirModel.Bundle{
+		Resource: fhirModel.Resource{
+			ID:           fhirModel.ID(uuid.NewV4().String()),
+			ResourceType: fhirModel.ResourceBundle,
+		},
+		Type:  fhirModel.BundleTypeSearchset,
+		Entry: []*fhirModel.BundleEntry{},
+		Link: []*fhirModel.BundleLink{
+			{Relation: entity.BundleLinkSelf, URL: searchPatientPageURL(params, params.Offset)},
+		},
+	}
+
+	if hasNext {
+		b.Link = append(b.Link, &fhirModel.BundleLink{
+			Relation: entity.BundleLinkNext,
+			URL:      searchPatientPageURL(params, params.Offset+params.Count),
+		})
+	}
+
+	if params.Offset > 0 {
+		prevOffset := params.Offset - params.Count
+		if prevOffset < 0 {
+			prevOffset = 0
+		}
+
+		b.Link = append(b.Link, &fhirModel.BundleLink{
+			Relation: entity.BundleLinkPrevious,
+			URL:      searchPatientPageURL(params, prevOffset),
+		})
+	}
+
+	for _, p := range patients {
+		b.Entry = append(b.Entry, &fhirModel.BundleEntry{
+			FullURL:  fmt.Sprintf("%s/%s", fhirModel.ResourcePatient, p.ID),
+			Resource: p,
+			Search:   &fhirModel.BundleEntrySearch{Mode: entity.SearchEntryModeMatch},
+		})
+	}
+
+	return b
+}
+
+func searchPatientPageURL(params *entity.SearchPatientParams, offset int) string {
+	q := url.Values{}
+
+	if params.Identifier != nil {
+		q.Set("identifier", params.Identifier.Value)
+	}
+
+	if params.Phone != nil {
+		q.Set("phone", params.Phone.Phone)
+		q.Set("birthdate", params.Phone.BirthDate)
+	}
+
+	q.Set("_count", strconv.Itoa(params.Count))
+	q.Set("_offset", strconv.Itoa(offset))
+
+	return fmt.Sprintf("%s?%s", fhirModel.ResourcePatient, q.Encode())
+}
@@ -0,0 +1,113 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/pkg/converto"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
+)
+
+// sendOTP sends the otp code by sms and keeps the submission status of the sms in the task, the code itself is never logged
+func (uc *UseCase) sendOTP(ctx context.Context, t *fhirModel.Task, otp *entity.OTP, language string) error {
+	if err := uc.checkGeneratedOTP(ctx, otp); err != nil {
+		return err
+	}
+
+	templates := entity.OTPMessageTemplates()
+
+	template, ok := templates[language]
+	if !ok {
+		language = entity.LanguageArabic
+		template = templates[language]
+	}
+
+	d, err := uc.sms.Send(ctx, &entity.SMSMessage{
+		To:       otp.Value,
+		Text:     fmt.Sprintf(template, otp.Code),
+		Language: language,
+	})
+	if err != nil {
+		return err
+	}
+
+	t.Extension = append(t.Extension, otpDeliveryExtension(d, language, otp.ExpiresAt))
+
+	return nil
+}
+
+func (uc *UseCase) checkGeneratedOTP(ctx context.Context, otp *entity.OTP) error {
+	if otp.Code == "" {
+		return cerror.NewF(ctx, cerror.KindInternal, "otp is empty").LogError()
+	}
+
+	return nil
+}
+
+func otpDeliveryExtension(d *entity.SMSDelivery, language string, expiresAt time.Time) *fhirModel.Extension {
+	e := &fhirModel.Extension{
+		URL: entity.StructureDefinitionTaskOTPDelivery,
+		Extension: []*fhirModel.Extension{
+			{URL: "submissionStatus", ValueX: fhirModel.ValueX{ValueCode: converto.StringPointer(d.SubmissionStatus)}},
+			{URL: "language", ValueX: fhirModel.ValueX{ValueCode: converto.StringPointer(language)}},
+			{URL: "sentAt", ValueX: fhirModel.ValueX{ValueDateTime: (*fhirModel.DateTime)(converto.TimePointer(d.SentAt))}},
+		},
+	}
+
+	if d.MessageID != "" {
+		e.Extension = append(e.Extension, &fhirModel.Extension{
+			URL:    "messageId",
+			ValueX: fhirModel.ValueX{ValueString: converto.StringPointer(d.MessageID)},
+		})
+	}
+
+	if !expiresAt.IsZero() {
+		e.Extension = append(e.Extension, &fhirModel.Extension{
+			URL:    "expiresAt",
+			ValueX: fhirModel.ValueX{ValueDateTime: (*fhirModel.DateTime)(converto.TimePointer(expiresAt))},
+		})
+	}
+
+	return e
+}
+
+// patientLanguage returns the preferred patient language the sms templates exist for
+func patientLanguage(p *fhirModel.Patient) string {
+	templates := entity.OTPMessageTemplates()
+
+	for _, c := range p.Communication {
+		if !converto.BoolValue(c.Preferred) || c.Language == nil {
+			continue
+		}
+
+		for _, coding := range c.Language.Codings {
+			if _, ok := templates[coding.Code]; ok {
+				return coding.Code
+			}
+		}
+	}
+
+	return entity.LanguageArabic
+}
+
+// taskOTPLanguage returns the language of the last otp sms sent for the task
+func taskOTPLanguage(t *fhirModel.Task) string {
+	language := entity.LanguageArabic
+
+	for _, e := range t.Extension {
+		if e.URL != entity.StructureDefinitionTaskOTPDelivery {
+			continue
+		}
+
+		for _, ee := range e.Extension {
+			if ee.URL == "language" && ee.ValueCode != nil {
+				language = *ee.ValueCode
+			}
+		}
+	}
+
+	return language
+}
@@ -0,0 +1,130 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/pkg/converto"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
+)
+
+const taskSortAuthoredOn = "authored-on"
+
+// SweepExpiredTasks cancels the pending tasks nobody confirmed within the ttl, so they are not found as duplicates
+// anymore, the tasks whose last otp has not expired yet are kept. The number of the canceled tasks is returned
+func (uc *UseCase) SweepExpiredTasks(ctx context.Context, params *entity.SweepTasksParams) (int, error) {
+	if params.TTL <= 0 || params.BatchSize <= 0 {
+		return 0, cerror.NewF(ctx, cerror.KindInternal, "sweep ttl and batch size should be positive").LogError()
+	}
+
+	now := time.Now().UTC()
+	authoredBefore := now.Add(-params.TTL)
+
+	// the canceled tasks leave the search results, the kept ones stay there and are skipped by the offset
+	var canceled, kept int
+
+	for {
+		tasks, err := uc.fhir.SearchTaskByParams(ctx, &entity.SearchTaskParams{
+			AuthoredBefore: &authoredBefore,
+			Status:         fhirModel.TaskStatusInProgress,
+			Profiles:       entity.PendingTaskProfiles(),
+			Sort:           taskSortAuthoredOn,
+			Count:          params.BatchSize,
+			Offset:         kept,
+		})
+		if err != nil {
+			return canceled, err
+		}
+
+		expired := make([]*fhirModel.Task, 0, len(tasks))
+
+		for _, t := range tasks {
+			if len(expired) < params.BatchSize && isTaskExpired(t, authoredBefore, now) {
+				expired = append(expired, t)
+			} else {
+				kept++
+			}
+		}
+
+		if len(expired) > 0 {
+			if err := uc.saveExpiredTasksBundle(ctx, expired); err != nil {
+				return canceled, err
+			}
+
+			canceled += len(expired)
+		}
+
+		if len(tasks) < params.BatchSize {
+			return canceled, nil
+		}
+	}
+}
+
+// isTaskExpired checks that the pending task was authored before the ttl and that its last otp has expired
+func isTaskExpired(t *fhirModel.Task, authoredBefore, now time.Time) bool {
+	if t.Status != fhirModel.TaskStatusInProgress || !hasTaskProfile(t, entity.PendingTaskProfiles()...) {
+		return false
+	}
+
+	if t.AuthoredOn == nil || !t.AuthoredOn.Time().Before(authoredBefore) {
+		return false
+	}
+
+	for _, e := range t.Extension {
+		if e.URL != entity.StructureDefinitionTaskOTPDelivery {
+			continue
+		}
+
+		for _, ee := range e.Extension {
+			if ee.URL == "expiresAt" && ee.ValueDateTime != nil && ee.ValueDateTime.Time().After(now) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (uc *UseCase) saveExpiredTasksBundle(ctx context.Context, tasks []*fhirModel.Task) error {
+	b := &fhirModel.Bundle{
+		Resource: fhirModel.Resource{
+			ID:           fhirModel.ID(uuid.NewV4().String()),
+			ResourceType: fhirModel.ResourceBundle,
+		},
+		Type:  fhirModel.BundleTypeTransaction,
+		Entry: make([]*fhirModel.BundleEntry, 0, len(tasks)),
+	}
+
+	for _, t := range tasks {
+		t.Status = fhirModel.TaskStatusCanceled
+		t.BusinessStatus = &fhirModel.CodeableConcept{Text: entity.TaskBusinessStatusExpired}
+		t.LastModified = (*fhirModel.DateTime)(converto.TimePointer(time.Now().UTC()))
+
+		b.Entry = append(b.Entry, &fhirModel.BundleEntry{
+			Resource: t,
+			Request: &fhirModel.BundleEntryRequest{
+				Method: http.MethodPut,
+				URL:    fmt.Sprintf("%s/%s", fhirModel.ResourceTask, t.ID),
+			},
+		})
+
+		patientEntry, err := uc.endedEmailTaskPatientEntry(ctx, t)
+		if err != nil {
+			return err
+		}
+
+		if patientEntry != nil {
+			b.Entry = append(b.Entry, patientEntry)
+		}
+	}
+
+	_, err := uc.fhir.CreateBundle(ctx, b)
+
+	return err
+}
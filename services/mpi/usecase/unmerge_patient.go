@@ -0,0 +1,317 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/pkg/converto"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
+)
+
+// UnmergePatients reverses the patient $merge by the pre-merge snapshots of the merge task, the unmerge is rejected
+// when the patients changed since the merge as the restore would lose the changes. The restored source is checked
+// for the duplicates because the same person could be registered while it was merged
+func (uc *UseCase) UnmergePatients(ctx context.Context, p *fhirModel.Parameters) (*fhirModel.Task, error) {
+	if _, err := uc.fhir.ValidateParameters(ctx, p); err != nil {
+		return nil, err
+	}
+
+	administrator, err := uc.authorizeAdministrator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mergeTaskID, err := uc.extractUnmergeParams(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	mergeTask, err := uc.getMergeTask(ctx, mergeTaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceSnapshot, targetSnapshot, mergedSnapshot, err := mergeSnapshots(ctx, mergeTask)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceID, targetID, err := mergeTaskPatients(ctx, mergeTask)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := uc.getPatientByRole(ctx, sourceID, "source")
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := uc.getPatientByRole(ctx, targetID, "target")
+	if err != nil {
+		return nil, err
+	}
+
+	if !isSourceUnmergeable(source, sourceSnapshot, targetID) {
+		return nil, cerror.NewF(ctx, cerror.KindBadValidation, "source patient changed since the merge").LogError()
+	}
+
+	if !isTargetUnmergeable(target, mergedSnapshot, sourceID) {
+		return nil, cerror.NewF(ctx, cerror.KindBadValidation, "target patient changed since the merge").LogError()
+	}
+
+	if err := uc.validateRestoredPatientDupls(ctx, sourceSnapshot, sourceID, targetID); err != nil {
+		return nil, err
+	}
+
+	source.Active = sourceSnapshot.Active
+	source.Identifier = sourceSnapshot.Identifier
+	source.Link = sourceSnapshot.Link
+
+	target.Identifier = targetSnapshot.Identifier
+	target.Telecom = targetSnapshot.Telecom
+	target.Link = removePatientLink(target.Link, entity.PatientLinkReplaces, sourceID)
+
+	mergeTask.BusinessStatus = &fhirModel.CodeableConcept{Text: entity.TaskBusinessStatusPatientsUnmerged}
+	mergeTask.LastModified = (*fhirModel.DateTime)(converto.TimePointer(time.Now().UTC()))
+
+	task := prepareUnmergePatientTask(p, mergeTask.ID, sourceID, targetID)
+	addTaskAdministrator(task, administrator)
+
+	_, err = uc.fhir.CreateBundle(ctx, prepareUnmergePatientBundle(p, task, mergeTask, source, target))
+	if err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+func (uc *UseCase) extractUnmergeParams(ctx context.Context, p *fhirModel.Parameters) (fhirModel.ID, error) {
+	for _, param := range p.Parameter {
+		if param.Name != "merge-task" || param.ValueReference == nil {
+			continue
+		}
+
+		id, ok := param.ValueReference.ParseID()
+		if !ok {
+			return "", cerror.NewValidationError(
+				ctx, map[string]string{"Parameters.parameter": "invalid merge-task parameter format"}).LogError()
+		}
+
+		return id, nil
+	}
+
+	return "", cerror.NewValidationError(
+		ctx, map[string]string{"Parameters.parameter": "missing merge-task parameter"}).LogError()
+}
+
+func (uc *UseCase) getMergeTask(ctx context.Context, id fhirModel.ID) (*fhirModel.Task, error) {
+	t, err := uc.fhir.GetTaskByID(ctx, id)
+	if err != nil {
+		if cerror.ErrKind(err) == cerror.KindNotExist {
+			return nil, cerror.NewF(ctx, cerror.KindNotExist, "such merge does not exist")
+		}
+
+		return nil, err
+	}
+
+	if !hasTaskProfile(t, entity.StructureDefinitionTaskPatientMerge) || t.Status != fhirModel.TaskStatusCompleted {
+		return nil, cerror.NewF(ctx, cerror.KindNotExist, "such merge does not exist")
+	}
+
+	if t.BusinessStatus == nil || t.BusinessStatus.Text != entity.TaskBusinessStatusPatientsMerged {
+		return nil, cerror.NewF(ctx, cerror.KindBadValidation, "such merge is already reversed").LogError()
+	}
+
+	return t, nil
+}
+
+// mergeSnapshots returns the contained pre-merge snapshots of the source and the target patients
+// and the snapshot of the merged target
+func mergeSnapshots(ctx context.Context, t *fhirModel.Task) (source, target, merged *fhirModel.Patient, err error) {
+	for i, r := range t.Contained {
+		p := new(fhirModel.Patient)
+		if err := interfaceToStruct(ctx, r, p, fmt.Sprintf("Task.contained[%d]", i)); err != nil {
+			return nil, nil, nil, err
+		}
+
+		if p.ResourceType != fhirModel.ResourcePatient {
+			continue
+		}
+
+		switch p.ID {
+		case mergeSnapshotSource:
+			source = p
+		case mergeSnapshotTarget:
+			target = p
+		case mergeSnapshotMerged:
+			merged = p
+		}
+	}
+
+	if source == nil || target == nil || merged == nil {
+		return nil, nil, nil, cerror.NewF(ctx, cerror.KindBadValidation, "merge task has no merged patients snapshots").
+			LogError()
+	}
+
+	return source, target, merged, nil
+}
+
+// mergeTaskPatients returns the source patient from the merge task output and the target one from the task subject
+func mergeTaskPatients(ctx context.Context, t *fhirModel.Task) (sourceID, targetID fhirModel.ID, err error) {
+	if t.For != nil {
+		targetID, _ = t.For.ParseID()
+	}
+
+	for _, o := range t.Output {
+		if o.ValueReference != nil {
+			sourceID, _ = o.ValueReference.ParseID()
+		}
+	}
+
+	if sourceID == "" || targetID == "" {
+		return "", "", cerror.NewF(ctx, cerror.KindBadValidation, "merge task has no merged patients").LogError()
+	}
+
+	return sourceID, targetID, nil
+}
+
+// isSourceUnmergeable checks that the source is still merged into the target and got no new identifiers
+func isSourceUnmergeable(source, snapshot *fhirModel.Patient, targetID fhirModel.ID) bool {
+	if converto.BoolValue(source.Active) || !hasPatientLink(source.Link, entity.PatientLinkReplacedBy, targetID) {
+		return false
+	}
+
+	return containsIdentifiers(snapshot.Identifier, source.Identifier)
+}
+
+// isTargetUnmergeable checks that the target still replaces the source and has the same identifiers and telecom
+// as right after the merge, the added and the removed values would be lost by the restore
+func isTargetUnmergeable(target, mergedSnapshot *fhirModel.Patient, sourceID fhirModel.ID) bool {
+	if !hasPatientLink(target.Link, entity.PatientLinkReplaces, sourceID) {
+		return false
+	}
+
+	return containsIdentifiers(mergedSnapshot.Identifier, target.Identifier) &&
+		containsIdentifiers(target.Identifier, mergedSnapshot.Identifier) &&
+		containsTelecom(mergedSnapshot.Telecom, target.Telecom) &&
+		containsTelecom(target.Telecom, mergedSnapshot.Telecom)
+}
+
+// validateRestoredPatientDupls checks that no other active patient got the identifiers of the restored source,
+// the target keeps the copied source identifiers until the restore, so it is not a duplicate
+func (uc *UseCase) validateRestoredPatientDupls(
+	ctx context.Context, sourceSnapshot *fhirModel.Patient, sourceID, targetID fhirModel.ID) error {
+	for _, ident := range sourceSnapshot.Identifier {
+		patients, err := uc.searchPatientsByIdent(ctx, ident)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range patients {
+			if p.ID != sourceID && p.ID != targetID {
+				return cerror.NewF(ctx, cerror.KindBadValidation, errMsgPatientExists).LogError()
+			}
+		}
+	}
+
+	return nil
+}
+
+func containsIdentifiers(all, idents []*fhirModel.Identifier) bool {
+	for _, i := range idents {
+		found := false
+
+		for _, a := range all {
+			if identifierTypeCode(a) == identifierTypeCode(i) && a.Value == i.Value {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsTelecom(all, telecom []*fhirModel.ContactPoint) bool {
+	for _, t := range telecom {
+		if indexOfContactPoint(all, func(a *fhirModel.ContactPoint) bool {
+			return a.System == t.System && a.Value == t.Value
+		}) < 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hasPatientLink(links []*fhirModel.PatientLink, linkType string, id fhirModel.ID) bool {
+	for _, l := range links {
+		if l.Type == linkType && l.Other != nil && l.Other.Reference == patientReference(id).Reference {
+			return true
+		}
+	}
+
+	return false
+}
+
+func removePatientLink(links []*fhirModel.PatientLink, linkType string, id fhirModel.ID) []*fhirModel.PatientLink {
+	kept := make([]*fhirModel.PatientLink, 0, len(links))
+
+	for _, l := range links {
+		if l.Type == linkType && l.Other != nil && l.Other.Reference == patientReference(id).Reference {
+			continue
+		}
+
+		kept = append(kept, l)
+	}
+
+	return kept
+}
+
+func prepareUnmergePatientTask(
+	p *fhirModel.Parameters, mergeTaskID, sourceID, targetID fhirModel.ID) *fhirModel.Task {
+	t := preparePatientsTask(p, entity.StructureDefinitionTaskPatientUnmerge, entity.TaskBusinessStatusPatientsUnmerged,
+		sourceID, targetID)
+
+	t.Input = append(t.Input, &fhirModel.TaskInput{
+		Type: &fhirModel.CodeableConcept{
+			Codings: []*fhirModel.Coding{
+				{
+					Code:   fhirModel.ResourceTask,
+					System: fhirModel.CodingSystemResourceTypes,
+				},
+			},
+		},
+		ValueX: fhirModel.ValueX{
+			ValueReference: &fhirModel.Reference{
+				Reference: fmt.Sprintf("%s/%s", fhirModel.ResourceTask, mergeTaskID),
+			},
+		},
+	})
+
+	return t
+}
+
+func prepareUnmergePatientBundle(p *fhirModel.Parameters, t, mergeTask *fhirModel.Task,
+	source, target *fhirModel.Patient) *fhirModel.Bundle {
+	b := preparePatientsBundle(p, t, source, target)
+
+	// the merge task is marked as reversed, so it is not unmerged twice
+	b.Entry = append(b.Entry, &fhirModel.BundleEntry{
+		Resource: mergeTask,
+		Request: &fhirModel.BundleEntryRequest{
+			Method: http.MethodPut,
+			URL:    fmt.Sprintf("%s/%s", fhirModel.ResourceTask, mergeTask.ID),
+		},
+	})
+
+	return b
+}
@@ -20,23 +20,26 @@ func (uc *UseCase) UpdatePatient(ctx context.Context, id fhirModel.ID, params *f
 		return nil, err
 	}
 
-	err = uc.validateParametersCount(ctx, patient)
-	if err != nil {
-		return nil, err
+	issues := make(validationIssues)
+
+	for _, vErr := range []error{
+		uc.validateParametersCount(ctx, patient),
+		uc.validatePatientProfile(ctx, params.Meta, patient.Meta),
+		validateURLPatientID(ctx, id, patient.ID, 0),
+	} {
+		if err := issues.add(vErr); err != nil {
+			return nil, err
+		}
 	}
 
-	if pErr := uc.validatePatientProfile(ctx, params.Meta, patient.Meta); pErr != nil {
-		return nil, pErr
+	if err := issues.err(ctx); err != nil {
+		return nil, err
 	}
 
 	if _, err = uc.fhir.ValidateParameters(ctx, params); err != nil {
 		return nil, err
 	}
 
-	if patient.ID != id {
-		return nil, cerror.NewF(ctx, cerror.KindBadValidation, "url id and patient id are not equal").LogError()
-	}
-
 	dbPatient, err := uc.fhir.GetPatientByID(ctx, id)
 	if err != nil {
 		return nil, err
@@ -49,7 +52,7 @@ func (uc *UseCase) UpdatePatient(ctx context.Context, id fhirModel.ID, params *f
 
 	setPatientParams(dbPatient, patient)
 
-	task := prepareUpdatePatientTask(params)
+	task := prepareUpdatePatientTask(params, id)
 
 	b := prepareUpdatePatientBundle(params, task, dbPatient)
 
@@ -68,14 +71,30 @@ func (uc *UseCase) validatePatientByInternalRules(
 		return cerror.NewF(ctx, cerror.KindBadValidation, "patient has inactive status").LogError()
 	}
 
-	if converto.BoolValue(dbPatient.DeceasedBoolean) {
+	if isPatientDeceased(dbPatient) {
 		return cerror.NewF(ctx, cerror.KindBadValidation, "patient has deceased status").LogError()
 	}
 
 	return nil
 }
 
-func prepareUpdatePatientTask(p *fhirModel.Parameters) *fhirModel.Task {
+// validateURLPatientID checks that the patient of the parameters is the one of the operation url
+func validateURLPatientID(ctx context.Context, id, patientID fhirModel.ID, patientParamIndex int) error {
+	if patientID == id {
+		return nil
+	}
+
+	return cerror.NewValidationError(ctx, map[string]string{
+		fmt.Sprintf("Parameters.parameter[%d].resource.id", patientParamIndex): "url id and patient id are not equal",
+	}).LogError()
+}
+
+// isPatientDeceased checks both deceased choices, the $mark-deceased sets the date of the death
+func isPatientDeceased(p *fhirModel.Patient) bool {
+	return converto.BoolValue(p.DeceasedBoolean) || p.DeceasedDateTime != nil
+}
+
+func prepareUpdatePatientTask(p *fhirModel.Parameters, patientID fhirModel.ID) *fhirModel.Task {
 	return &fhirModel.Task{
 		DomainResource: fhirModel.DomainResource{
 			Resource: fhirModel.Resource{
@@ -86,6 +105,7 @@ func prepareUpdatePatientTask(p *fhirModel.Parameters) *fhirModel.Task {
 		},
 		Status:     fhirModel.TaskStatusCompleted,
 		Intent:     fhirModel.TaskIntentOrder,
+		For:        patientReference(patientID),
 		AuthoredOn: (*fhirModel.DateTime)(converto.TimePointer(time.Now().UTC())),
 		Input: []*fhirModel.TaskInput{
 			{
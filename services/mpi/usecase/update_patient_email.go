@@ -3,9 +3,11 @@ package usecase
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"strings"
 
 	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/pkg/converto"
 	fhirModel "wasfaty.api/pkg/fhir/model"
 	"wasfaty.api/services/mpi/entity"
 )
@@ -43,9 +45,17 @@ func (uc *UseCase) UpdatePatientEmail(ctx context.Context, id fhirModel.ID, p *f
 		return nil, err
 	}
 
+	if isEmailVerificationRequested(p) {
+		if !uc.emailVerification {
+			return nil, cerror.NewF(ctx, cerror.KindBadValidation, "email verification is not available").LogError()
+		}
+
+		return uc.updatePatientEmailWithVerification(ctx, id, p, patient, dbPatient)
+	}
+
 	setPatientTelecomParams(dbPatient, patient)
 
-	task := prepareUpdatePatientTask(p)
+	task := prepareUpdatePatientTask(p, id)
 
 	b := prepareUpdatePatientBundle(p, task, dbPatient)
 
@@ -74,7 +84,7 @@ func setPatientTelecomParams(dbPatient, patient *fhirModel.Patient) {
 			isExist := false
 
 			for j := range dbPatient.Telecom {
-				if dbPatient.Telecom[j].System == param.System {
+				if dbPatient.Telecom[j].System == param.System && !isPendingContactPoint(dbPatient.Telecom[j]) {
 					dbPatient.Telecom[j].Value = param.Value
 					isExist = true
 
@@ -88,3 +98,158 @@ func setPatientTelecomParams(dbPatient, patient *fhirModel.Patient) {
 		}
 	}
 }
+
+// updatePatientEmailWithVerification stores the new email as pending and sends an otp to it,
+// the email is applied by the $confirm-email operation
+func (uc *UseCase) updatePatientEmailWithVerification(
+	ctx context.Context,
+	id fhirModel.ID,
+	p *fhirModel.Parameters,
+	patient, dbPatient *fhirModel.Patient) (*fhirModel.Task, error) {
+	var email string
+
+	for _, telecom := range patient.Telecom {
+		if telecom.System == fhirModel.TelecomSystemEmail {
+			email = telecom.Value
+			break
+		}
+	}
+
+	if email == "" {
+		return nil, cerror.NewValidationError(
+			ctx, map[string]string{"Telecom": "no email found"}).LogError()
+	}
+
+	duplTasks, err := uc.searchPatientActiveTasks(ctx, id, fhirModel.StructureDefinitionTaskPatientUpdateEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	setPatientPendingEmail(dbPatient, email)
+
+	task := prepareUpdatePatientTask(p, id)
+	task.Meta.Profile = []string{fhirModel.StructureDefinitionTaskPatientUpdateEmail}
+	task.Status = fhirModel.TaskStatusInProgress
+	task.BusinessStatus = &fhirModel.CodeableConcept{Text: entity.TaskBusinessStatusOTPCodeSent}
+
+	otp, err := uc.generateOTPByEmail(ctx, email, string(task.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	// the email otp is sent by the remote otp service, the verification is enabled only where it sends the email codes
+	err = uc.checkGeneratedOTP(ctx, otp)
+	if err != nil {
+		return nil, err
+	}
+
+	b := prepareUpdatePatientBundle(p, task, dbPatient)
+
+	for _, d := range duplTasks {
+		d.Status = fhirModel.TaskStatusCanceled
+		b.Entry = append(b.Entry, &fhirModel.BundleEntry{
+			Resource: d,
+			Request: &fhirModel.BundleEntryRequest{
+				Method: http.MethodPut,
+				URL:    fmt.Sprintf("%s/%s", fhirModel.ResourceTask, d.ID),
+			},
+		})
+	}
+
+	_, err = uc.fhir.CreateBundle(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+func isEmailVerificationRequested(p *fhirModel.Parameters) bool {
+	for _, param := range p.Parameter {
+		if param.Name == "verify" {
+			return converto.BoolValue(param.ValueBoolean)
+		}
+	}
+
+	return false
+}
+
+// setPatientPendingEmail replaces the pending email of the patient, the current email is kept until the confirmation
+func setPatientPendingEmail(p *fhirModel.Patient, email string) {
+	telecom := make([]*fhirModel.ContactPoint, 0, len(p.Telecom)+1)
+
+	for _, t := range p.Telecom {
+		if !isPendingContactPoint(t) {
+			telecom = append(telecom, t)
+		}
+	}
+
+	p.Telecom = append(telecom, &fhirModel.ContactPoint{
+		System: fhirModel.TelecomSystemEmail,
+		Value:  email,
+		Extension: []*fhirModel.Extension{
+			{
+				URL:    entity.StructureDefinitionContactPointVerificationStatus,
+				ValueX: fhirModel.ValueX{ValueCode: converto.StringPointer(entity.ContactPointVerificationStatusPending)},
+			},
+		},
+	})
+}
+
+func isPendingContactPoint(t *fhirModel.ContactPoint) bool {
+	for _, e := range t.Extension {
+		if e.URL == entity.StructureDefinitionContactPointVerificationStatus &&
+			converto.StringValue(e.ValueCode) == entity.ContactPointVerificationStatusPending {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removePatientPendingEmail drops the pending email of the patient, it is reported whether the email was found
+func removePatientPendingEmail(p *fhirModel.Patient) bool {
+	telecom := make([]*fhirModel.ContactPoint, 0, len(p.Telecom))
+
+	for _, t := range p.Telecom {
+		if !isPendingContactPoint(t) {
+			telecom = append(telecom, t)
+		}
+	}
+
+	if len(telecom) == len(p.Telecom) {
+		return false
+	}
+
+	p.Telecom = telecom
+
+	return true
+}
+
+// endedEmailTaskPatientEntry returns the bundle entry of the patient without the pending email when the email change
+// task is canceled, rejected or expired, nil is returned for the other tasks
+func (uc *UseCase) endedEmailTaskPatientEntry(ctx context.Context, t *fhirModel.Task) (*fhirModel.BundleEntry, error) {
+	if t.Status == fhirModel.TaskStatusInProgress || t.Status == fhirModel.TaskStatusCompleted ||
+		!hasTaskProfile(t, fhirModel.StructureDefinitionTaskPatientUpdateEmail) || t.For == nil {
+		return nil, nil
+	}
+
+	id, _ := t.For.ParseID()
+
+	p, err := uc.fhir.GetPatientByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !removePatientPendingEmail(p) {
+		return nil, nil
+	}
+
+	return &fhirModel.BundleEntry{
+		Resource: p,
+		Request: &fhirModel.BundleEntryRequest{
+			Method: http.MethodPut,
+			URL:    fmt.Sprintf("%s/%s", fhirModel.ResourcePatient, p.ID),
+		},
+	}, nil
+}
@@ -25,12 +25,20 @@ func (uc *UseCase) UpdatePatientIdentity(ctx context.Context, id fhirModel.ID, p
 		return nil, err
 	}
 
-	if pErr := uc.validatePatientProfile(ctx, p.Meta, params.Patient.Meta); pErr != nil {
-		return nil, pErr
+	issues := make(validationIssues)
+
+	for _, vErr := range []error{
+		uc.validatePatientProfile(ctx, p.Meta, params.Patient.Meta),
+		validateURLPatientID(ctx, id, params.Patient.ID, params.PatientParamIndex),
+		uc.validateIdents(ctx, params.Patient, params.PatientParamIndex),
+	} {
+		if err := issues.add(vErr); err != nil {
+			return nil, err
+		}
 	}
 
-	if params.Patient.ID != id {
-		return nil, cerror.NewF(ctx, cerror.KindBadValidation, "url id and patient id are not equal").LogError()
+	if err := issues.err(ctx); err != nil {
+		return nil, err
 	}
 
 	dbPatient, err := uc.fhir.GetPatientByID(ctx, id)
@@ -38,7 +46,17 @@ func (uc *UseCase) UpdatePatientIdentity(ctx context.Context, id fhirModel.ID, p
 		return nil, err
 	}
 
-	err = uc.validateUpdatePatientIdentityByInternalRules(ctx, params.Patient, dbPatient)
+	err = uc.validatePatientByInternalRules(ctx, dbPatient)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := uc.validateByExtDocRegistry(ctx, params.Patient)
+	if err != nil {
+		return nil, err
+	}
+
+	demographicIssues, err := uc.crossCheckDemographics(ctx, p, params.PatientParamIndex, params.Patient, record)
 	if err != nil {
 		return nil, err
 	}
@@ -53,14 +71,21 @@ func (uc *UseCase) UpdatePatientIdentity(ctx context.Context, id fhirModel.ID, p
 		return nil, err
 	}
 
-	t := prepareUpdatePatientIdentityTask(p)
+	t := prepareUpdatePatientIdentityTask(p, id)
 
-	otp, err := uc.otp.GenerateByPhone(ctx, params.ConfirmationMethod, string(t.ID))
+	// the marked request is held for the review when its otp is confirmed, so the approval does not skip the otp
+	if uc.demographicsPolicy == entity.DemographicsPolicyHold && len(demographicIssues) > 0 {
+		markTaskForReview(t, entity.TaskReasonRegistryMismatch)
+	}
+
+	addTaskOutcome(t, demographicIssues)
+
+	otp, err := uc.generateOTPByPhone(ctx, params.ConfirmationMethod, string(t.ID))
 	if err != nil {
 		return nil, err
 	}
 
-	err = uc.sendOTP(ctx, otp)
+	err = uc.sendOTP(ctx, t, otp, patientLanguage(params.Patient))
 	if err != nil {
 		return nil, err
 	}
@@ -73,21 +98,6 @@ func (uc *UseCase) UpdatePatientIdentity(ctx context.Context, id fhirModel.ID, p
 	return t, nil
 }
 
-func (uc *UseCase) validateUpdatePatientIdentityByInternalRules(
-	ctx context.Context,
-	patient *fhirModel.Patient,
-	dbPatient *fhirModel.Patient) error {
-	if !converto.BoolValue(dbPatient.Active) {
-		return cerror.NewF(ctx, cerror.KindBadValidation, "patient has inactive status").LogError()
-	}
-
-	if converto.BoolValue(dbPatient.DeceasedBoolean) {
-		return cerror.NewF(ctx, cerror.KindBadValidation, "patient has deceased status").LogError()
-	}
-
-	return uc.validateIdents(ctx, patient, 1)
-}
-
 func (uc *UseCase) searchUpdatePatientIdentifierDuplicateTasks(
 	ctx context.Context,
 	p *fhirModel.Patient) (
@@ -111,7 +121,7 @@ func (uc *UseCase) searchUpdatePatientIdentifierDuplicateTasks(
 }
 
 //nolint:dupl
-func prepareUpdatePatientIdentityTask(p *fhirModel.Parameters) *fhirModel.Task {
+func prepareUpdatePatientIdentityTask(p *fhirModel.Parameters, patientID fhirModel.ID) *fhirModel.Task {
 	return &fhirModel.Task{
 		DomainResource: fhirModel.DomainResource{
 			Resource: fhirModel.Resource{
@@ -123,6 +133,7 @@ func prepareUpdatePatientIdentityTask(p *fhirModel.Parameters) *fhirModel.Task {
 		Status:         fhirModel.TaskStatusInProgress,
 		BusinessStatus: &fhirModel.CodeableConcept{Text: entity.TaskBusinessStatusPatientIdentityUpdated},
 		Intent:         fhirModel.TaskIntentOrder,
+		For:            patientReference(patientID),
 		AuthoredOn:     (*fhirModel.DateTime)(converto.TimePointer(time.Now().UTC())),
 		Input: []*fhirModel.TaskInput{
 			{
@@ -149,14 +160,16 @@ func (uc *UseCase) extractUpdatePatientIdentityParams(ctx context.Context, p *fh
 	var (
 		confirmationMethod string
 		patientParam       interface{}
+		patientParamIndex  int
 	)
 
-	for _, param := range p.Parameter {
+	for i, param := range p.Parameter {
 		switch param.Name {
 		case "confirmationMethod":
 			confirmationMethod = converto.StringValue(param.ValueString)
 		case "patient":
 			patientParam = param.Resource
+			patientParamIndex = i
 		}
 	}
 
@@ -175,6 +188,7 @@ func (uc *UseCase) extractUpdatePatientIdentityParams(ctx context.Context, p *fh
 	return &entity.UpdatePatientIdentityParameters{
 		ConfirmationMethod: confirmationMethod,
 		Patient:            patient,
+		PatientParamIndex:  patientParamIndex,
 	}, nil
 }
 
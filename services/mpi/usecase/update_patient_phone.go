@@ -0,0 +1,219 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/pkg/converto"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
+)
+
+func (uc *UseCase) UpdatePatientPhone(ctx context.Context, id fhirModel.ID, p *fhirModel.Parameters) (
+	*fhirModel.Task, error) {
+	if _, err := uc.fhir.ValidateParameters(ctx, p); err != nil {
+		return nil, err
+	}
+
+	params, err := uc.extractUpdatePatientPhoneParams(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	dbPatient, err := uc.fhir.GetPatientByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = uc.validatePatientByInternalRules(ctx, dbPatient)
+	if err != nil {
+		return nil, err
+	}
+
+	oldTelecom, err := uc.validateUpdatePatientPhone(ctx, dbPatient, params)
+	if err != nil {
+		return nil, err
+	}
+
+	suspected, err := uc.isFraudSuspected(ctx, dbPatient, mobilePhone(params.Phone))
+	if err != nil {
+		return nil, err
+	}
+
+	if suspected && uc.fraudPolicy != entity.FraudPolicyHold {
+		return nil, errTooManyPatientsWithPhone(ctx)
+	}
+
+	duplTasks, err := uc.searchPatientActiveTasks(ctx, id, entity.StructureDefinitionTaskPatientUpdatePhone)
+	if err != nil {
+		return nil, err
+	}
+
+	t := prepareUpdatePatientPhoneTask(p, id)
+	if suspected {
+		markTaskForReview(t, entity.TaskReasonFraudSuspected)
+	}
+
+	otp, err := uc.generateOTPByPhone(ctx, params.Phone, string(t.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	err = uc.sendOTP(ctx, t, otp, patientLanguage(dbPatient))
+	if err != nil {
+		return nil, err
+	}
+
+	if params.ConfirmOldPhone {
+		otp, err = uc.generateOTPByPhone(ctx, oldTelecom.Value, oldPhoneProcessID(t.ID))
+		if err != nil {
+			return nil, err
+		}
+
+		err = uc.sendOTP(ctx, t, otp, patientLanguage(dbPatient))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = uc.saveUpdatePatientIdentityBundle(ctx, p, t, duplTasks)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (uc *UseCase) extractUpdatePatientPhoneParams(ctx context.Context, p *fhirModel.Parameters) (
+	*entity.UpdatePatientPhoneParameters, error) {
+	params := new(entity.UpdatePatientPhoneParameters)
+
+	for _, param := range p.Parameter {
+		switch param.Name {
+		case "phone":
+			params.Phone = converto.StringValue(param.ValueString)
+		case "confirmOldPhone":
+			params.ConfirmOldPhone = converto.BoolValue(param.ValueBoolean)
+		}
+	}
+
+	if params.Phone == "" {
+		return nil, cerror.NewValidationError(
+			ctx, map[string]string{"Parameters.parameter": "missing phone parameter"}).LogError()
+	}
+
+	return params, nil
+}
+
+// validateUpdatePatientPhone checks the new phone against the current one and returns the current mobile phone
+func (uc *UseCase) validateUpdatePatientPhone(
+	ctx context.Context,
+	dbPatient *fhirModel.Patient,
+	params *entity.UpdatePatientPhoneParameters) (*fhirModel.ContactPoint, error) {
+	oldTelecom := findMobilePhone(dbPatient)
+
+	if oldTelecom != nil && oldTelecom.Value == params.Phone {
+		return nil, cerror.NewF(ctx, cerror.KindBadValidation, "patient already has such phone").LogError()
+	}
+
+	if params.ConfirmOldPhone && oldTelecom == nil {
+		return nil, cerror.NewF(ctx, cerror.KindBadValidation, "patient has no phone to confirm").LogError()
+	}
+
+	return oldTelecom, nil
+}
+
+// searchPatientActiveTasks returns the in-progress tasks of the patient having the profile
+func (uc *UseCase) searchPatientActiveTasks(ctx context.Context, patientID fhirModel.ID, profile string) (
+	[]*fhirModel.Task, error) {
+	tasks, err := uc.fhir.SearchTaskByParams(ctx, &entity.SearchTaskParams{
+		PatientID: patientID,
+		Status:    fhirModel.TaskStatusInProgress,
+		Profiles:  []string{profile},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	duplTasks := []*fhirModel.Task{}
+
+	for _, t := range tasks {
+		if t.Status == fhirModel.TaskStatusInProgress && hasTaskProfile(t, profile) {
+			duplTasks = append(duplTasks, t)
+		}
+	}
+
+	return duplTasks, nil
+}
+
+//nolint:dupl
+func prepareUpdatePatientPhoneTask(p *fhirModel.Parameters, patientID fhirModel.ID) *fhirModel.Task {
+	return &fhirModel.Task{
+		DomainResource: fhirModel.DomainResource{
+			Resource: fhirModel.Resource{
+				ID:           fhirModel.ID(uuid.NewV4().String()),
+				ResourceType: fhirModel.ResourceTask,
+				Meta:         &fhirModel.Meta{Profile: []string{entity.StructureDefinitionTaskPatientUpdatePhone}},
+			},
+		},
+		Status:         fhirModel.TaskStatusInProgress,
+		BusinessStatus: &fhirModel.CodeableConcept{Text: entity.TaskBusinessStatusOTPCodeSent},
+		Intent:         fhirModel.TaskIntentOrder,
+		For:            patientReference(patientID),
+		AuthoredOn:     (*fhirModel.DateTime)(converto.TimePointer(time.Now().UTC())),
+		Input: []*fhirModel.TaskInput{
+			{
+				Type: &fhirModel.CodeableConcept{
+					Codings: []*fhirModel.Coding{
+						{
+							Code:   fhirModel.ResourceParameters,
+							System: fhirModel.CodingSystemResourceTypes,
+						},
+					},
+				},
+				ValueX: fhirModel.ValueX{
+					ValueReference: &fhirModel.Reference{
+						Reference: fmt.Sprintf("%s/%s", fhirModel.ResourceParameters, p.ID),
+					},
+				},
+			},
+		},
+	}
+}
+
+func findMobilePhone(p *fhirModel.Patient) *fhirModel.ContactPoint {
+	for _, telecom := range p.Telecom {
+		if telecom.System == fhirModel.TelecomSystemPhone && telecom.Use == fhirModel.TelecomUseMobile {
+			return telecom
+		}
+	}
+
+	return nil
+}
+
+func mobilePhone(value string) *fhirModel.ContactPoint {
+	return &fhirModel.ContactPoint{
+		System: fhirModel.TelecomSystemPhone,
+		Use:    fhirModel.TelecomUseMobile,
+		Value:  value,
+	}
+}
+
+// oldPhoneProcessID returns the otp process id used to confirm the current phone of the patient
+func oldPhoneProcessID(taskID fhirModel.ID) string {
+	return string(taskID) + entity.OTPProcessIDOldPhoneSuffix
+}
+
+// setPatientPhone replaces the mobile phone of the patient or adds it if the patient has none
+func setPatientPhone(p *fhirModel.Patient, phone string) {
+	if telecom := findMobilePhone(p); telecom != nil {
+		telecom.Value = phone
+		return
+	}
+
+	p.Telecom = append(p.Telecom, mobilePhone(phone))
+}
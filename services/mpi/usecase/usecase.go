@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"context"
+	"time"
 
 	fhirModel "wasfaty.api/pkg/fhir/model"
 	"wasfaty.api/services/mpi/entity"
@@ -19,22 +20,56 @@ type FHIRClient interface {
 
 type OTPClient interface {
 	GenerateByPhone(ctx context.Context, phone, processID string) (*entity.OTP, error)
+	GenerateByEmail(ctx context.Context, email, processID string) (*entity.OTP, error)
 	Validate(ctx context.Context, p *entity.ValidateOTPParams) error
 }
 
+// RateLimiter counts the events of the keys, the event is counted for all keys only if none of them is over the limit,
+// otherwise the time after which the event is allowed is returned
+type RateLimiter interface {
+	Allow(ctx context.Context, keys []*entity.RateLimitKey) (time.Duration, error)
+}
+
 type ExtDocRegistryClient interface {
 	Search(ctx context.Context, p *fhirModel.Identifier) (*entity.ExtDocRegistrySearchResult, error)
 }
 
+type SMSSender interface {
+	Send(ctx context.Context, m *entity.SMSMessage) (*entity.SMSDelivery, error)
+}
+
 type UseCase struct {
 	fhir FHIRClient
 
 	otp OTPClient
 
 	docReg ExtDocRegistryClient
+	// the deaths are verified without the registry cache, the cached records could be older than the death
+	deathReg ExtDocRegistryClient
+
+	sms SMSSender
+	// the email otp could be delivered by the remote otp service only, the verified email change is rejected without it
+	emailVerification bool
+
+	demographicsPolicy string
+
+	rateLimiter   RateLimiter
+	otpRateLimits *entity.OTPRateLimits
+
+	matchPolicy *entity.PatientMatchPolicy
+	// the possible duplicates are not searched on create without the policy
+	duplicatePolicy string
+
+	fraudPolicy string
+	// the api consumers allowed to review the held requests
+	reviewers []string
+	// the api consumers allowed to merge, link, deactivate and mark deceased the patients
+	administrators []string
+
+	mergeSurvivorship *entity.MergeSurvivorship
 }
 
-func New(fc FHIRClient, oc OTPClient, edrc ExtDocRegistryClient) *UseCase {
+func New(fc FHIRClient, oc OTPClient, edrc ExtDocRegistryClient, ss SMSSender) *UseCase {
 	// feat 1
 	// feat 2 - hotfix
 
@@ -46,5 +81,73 @@ func New(fc FHIRClient, oc OTPClient, edrc ExtDocRegistryClient) *UseCase {
 
 	// feat 6
 	// feat 7
-	return &UseCase{fhir: fc, otp: oc, docReg: edrc}
+	return &UseCase{fhir: fc, otp: oc, docReg: edrc, deathReg: edrc, sms: ss,
+		demographicsPolicy: entity.DemographicsPolicyWarn,
+		matchPolicy:        entity.DefaultPatientMatchPolicy(),
+		mergeSurvivorship: &entity.MergeSurvivorship{
+			Identifier: entity.SurvivorshipUnion,
+			Telecom:    entity.SurvivorshipUnion,
+		}}
+}
+
+// WithDemographicsPolicy sets the policy applied when the submitted demographics do not match the civil registry
+func (uc *UseCase) WithDemographicsPolicy(policy string) *UseCase {
+	uc.demographicsPolicy = policy
+	return uc
+}
+
+// WithMatchPolicy sets the weights and the grade thresholds of the patient $match
+func (uc *UseCase) WithMatchPolicy(policy *entity.PatientMatchPolicy) *UseCase {
+	uc.matchPolicy = policy
+	return uc
+}
+
+// WithPossibleDuplicatePolicy sets the policy applied when the created patient matches the registered ones
+func (uc *UseCase) WithPossibleDuplicatePolicy(policy string) *UseCase {
+	uc.duplicatePolicy = policy
+	return uc
+}
+
+// WithMergeSurvivorship sets the rules of the values copied from the merged patient
+func (uc *UseCase) WithMergeSurvivorship(rules *entity.MergeSurvivorship) *UseCase {
+	uc.mergeSurvivorship = rules
+	return uc
+}
+
+// WithFraudPolicy sets the policy applied when too many patients share the phone of the confirmed patient
+func (uc *UseCase) WithFraudPolicy(policy string) *UseCase {
+	uc.fraudPolicy = policy
+	return uc
+}
+
+// WithEmailVerification enables the otp verified $update-email, the otp engine should deliver the email otp
+func (uc *UseCase) WithEmailVerification(enabled bool) *UseCase {
+	uc.emailVerification = enabled
+	return uc
+}
+
+// WithDeathRegistry sets the document registry client verifying the civil registry deaths
+func (uc *UseCase) WithDeathRegistry(c ExtDocRegistryClient) *UseCase {
+	uc.deathReg = c
+	return uc
+}
+
+// WithReviewers sets the api consumers allowed to list, approve and reject the held requests
+func (uc *UseCase) WithReviewers(consumers []string) *UseCase {
+	uc.reviewers = consumers
+	return uc
+}
+
+// WithAdministrators sets the api consumers allowed to merge, link, deactivate and mark deceased the patients
+func (uc *UseCase) WithAdministrators(consumers []string) *UseCase {
+	uc.administrators = consumers
+	return uc
+}
+
+// WithOTPRateLimiter limits the otp generation, the otp codes are not limited without the limiter
+func (uc *UseCase) WithOTPRateLimiter(l RateLimiter, limits *entity.OTPRateLimits) *UseCase {
+	uc.rateLimiter = l
+	uc.otpRateLimits = limits
+
+	return uc
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,8 +14,10 @@ import (
 	uuid "github.com/satori/go.uuid"
 	"wasfaty.api/pkg/cerror"
 	"wasfaty.api/pkg/converto"
+	"wasfaty.api/pkg/env"
 	fhirModel "wasfaty.api/pkg/fhir/model"
 	"wasfaty.api/pkg/log"
+	"wasfaty.api/services/mpi/adapter/ratelimit"
 	"wasfaty.api/services/mpi/entity"
 	"wasfaty.api/services/mpi/usecase"
 )
@@ -24,7 +27,13 @@ type createPatientTestFHIR struct {
 	tasks        []*fhirModel.Task
 	patients     []*fhirModel.Patient
 	duplPatients []*fhirModel.Patient
-	parameters   []*fhirModel.Parameters
+	// candidatePatients are returned by the birth date search if set
+	candidatePatients []*fhirModel.Patient
+	// phonePatients are returned by the phone search if set
+	phonePatients []*fhirModel.Patient
+	// pagedTasks are returned by the task search filtered by the status and paged if set
+	pagedTasks []*fhirModel.Task
+	parameters []*fhirModel.Parameters
 
 	validateParametersCallsCount int
 
@@ -46,9 +55,39 @@ func (c *createPatientTestFHIR) CreateBundle(ctx context.Context, b *fhirModel.B
 func (c *createPatientTestFHIR) SearchTaskByParams(ctx context.Context, params *entity.SearchTaskParams) (
 	[]*fhirModel.Task, error) {
 	c.searchTaskByParamsArgs = append(c.searchTaskByParamsArgs, params)
+
+	if c.pagedTasks != nil {
+		return pageTasks(c.pagedTasks, params), nil
+	}
+
 	return c.tasks, nil
 }
 
+func pageTasks(tasks []*fhirModel.Task, params *entity.SearchTaskParams) []*fhirModel.Task {
+	found := []*fhirModel.Task{}
+
+	for _, t := range tasks {
+		for _, status := range strings.Split(params.Status, ",") {
+			if status == "" || status == t.Status {
+				found = append(found, t)
+				break
+			}
+		}
+	}
+
+	if params.Offset >= len(found) {
+		return []*fhirModel.Task{}
+	}
+
+	found = found[params.Offset:]
+
+	if params.Count > 0 && params.Count < len(found) {
+		found = found[:params.Count]
+	}
+
+	return found
+}
+
 func (c *createPatientTestFHIR) GetPatientByID(ctx context.Context, id fhirModel.ID) (*fhirModel.Patient, error) {
 	for _, p := range c.patients {
 		if p.ID == id {
@@ -82,14 +121,28 @@ func (c *createPatientTestFHIR) GetParametersByID(ctx context.Context, id fhirMo
 func (c *createPatientTestFHIR) SearchPatientByParams(ctx context.Context, params *entity.SearchPatientParams) (
 	[]*fhirModel.Patient, error) {
 	c.searchPatientByParamsArgs = append(c.searchPatientByParamsArgs, params)
+
+	if params.BirthDate != "" && c.candidatePatients != nil {
+		return c.candidatePatients, nil
+	}
+
+	if params.Phone != nil && c.phonePatients != nil {
+		return c.phonePatients, nil
+	}
+
 	return c.duplPatients, nil
 }
 
 type createPatientTestExtDocReg struct {
+	result *entity.ExtDocRegistrySearchResult
 }
 
 func (c *createPatientTestExtDocReg) Search(ctx context.Context, i *fhirModel.Identifier) (
 	*entity.ExtDocRegistrySearchResult, error) {
+	if c.result != nil {
+		return c.result, nil
+	}
+
 	return &entity.ExtDocRegistrySearchResult{IsValid: true}, nil
 }
 
@@ -107,19 +160,60 @@ func (c *createPatientTestOTP) GenerateByPhone(ctx context.Context, phone, proce
 	return o, nil
 }
 
+func (c *createPatientTestOTP) GenerateByEmail(ctx context.Context, email, processID string) (*entity.OTP, error) {
+	return c.GenerateByPhone(ctx, email, processID)
+}
+
 func (c *createPatientTestOTP) Validate(ctx context.Context, p *entity.ValidateOTPParams) error {
 	if o, ok := c.otps[p.ProcessID]; ok && o.Code == p.Code && o.Value == p.Value {
 		return nil
 	}
 
+	if o, ok := c.otps[p.ProcessID]; ok && o.MaxAttemptsCount > 0 {
+		o.AttemptsCount++
+
+		return cerror.NewF(ctx, cerror.KindBadValidation, "otp service error: wrong code").
+			WithPayload(&entity.OTPAttempts{Count: o.AttemptsCount, MaxCount: o.MaxAttemptsCount})
+	}
+
 	return cerror.New(ctx, cerror.KindNotExist, cerror.ErrNotFound)
 }
 
+type createPatientTestSMS struct {
+	messages []*entity.SMSMessage
+	err      error
+}
+
+func (c *createPatientTestSMS) Send(ctx context.Context, m *entity.SMSMessage) (*entity.SMSDelivery, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	c.messages = append(c.messages, m)
+
+	return &entity.SMSDelivery{
+		MessageID:        fmt.Sprintf("%d", len(c.messages)),
+		SubmissionStatus: entity.SMSStatusSent,
+		SentAt:           time.Now().UTC(),
+	}, nil
+}
+
+type createPatientTestRateLimiter struct {
+	keys       [][]*entity.RateLimitKey
+	retryAfter time.Duration
+}
+
+func (l *createPatientTestRateLimiter) Allow(ctx context.Context, keys []*entity.RateLimitKey) (time.Duration, error) {
+	l.keys = append(l.keys, keys)
+	return l.retryAfter, nil
+}
+
 type useCaseTestSuite struct {
 	suite.Suite
 	fhir   *createPatientTestFHIR
 	otp    *createPatientTestOTP
 	extReg *createPatientTestExtDocReg
+	sms    *createPatientTestSMS
 	uc     *usecase.UseCase
 }
 
@@ -132,16 +226,20 @@ func (s *useCaseTestSuite) SetupSuite() {
 	s.fhir = new(createPatientTestFHIR)
 	s.otp = new(createPatientTestOTP)
 	s.extReg = new(createPatientTestExtDocReg)
+	s.sms = new(createPatientTestSMS)
 
 	s.otp.otps = make(map[string]*entity.OTP)
 
-	s.uc = usecase.New(s.fhir, s.otp, s.extReg)
+	s.uc = usecase.New(s.fhir, s.otp, s.extReg, s.sms)
 }
 
 func (s *useCaseTestSuite) TearDownTest() {
 	s.fhir.patients = nil
 	s.fhir.duplPatients = nil
+	s.fhir.candidatePatients = nil
+	s.fhir.phonePatients = nil
 	s.fhir.tasks = nil
+	s.fhir.pagedTasks = nil
 	s.fhir.parameters = nil
 	s.fhir.bundles = nil
 	s.fhir.validateParametersCallsCount = 0
@@ -149,6 +247,23 @@ func (s *useCaseTestSuite) TearDownTest() {
 	s.fhir.searchPatientByParamsArgs = nil
 
 	s.otp.otps = make(map[string]*entity.OTP)
+
+	s.extReg.result = nil
+
+	s.sms.messages = nil
+	s.sms.err = nil
+	s.uc.WithDemographicsPolicy(entity.DemographicsPolicyWarn)
+	s.uc.WithOTPRateLimiter(nil, nil)
+	s.uc.WithPossibleDuplicatePolicy("")
+	s.uc.WithFraudPolicy("").WithReviewers(nil).WithAdministrators(nil)
+	s.uc.WithEmailVerification(false)
+}
+
+// administratorCtx allows the patient administration to the api consumer of the returned context
+func (s *useCaseTestSuite) administratorCtx() context.Context {
+	s.uc.WithAdministrators([]string{"registrar-1"})
+
+	return context.WithValue(context.Background(), entity.HeaderConsumerID, "registrar-1") //nolint:staticcheck
 }
 
 func (s *useCaseTestSuite) TearDownSuite() {
@@ -229,6 +344,7 @@ func (s *useCaseTestSuite) TestCreatePatientSuccess() {
 				ResourceType: fhirModel.ResourceTask,
 				Meta:         &fhirModel.Meta{Profile: []string{fhirModel.StructureDefinitionTaskPatientCreate}},
 			},
+			Extension: t.Extension,
 		},
 
 		Status:         fhirModel.TaskStatusInProgress,
@@ -257,6 +373,40 @@ func (s *useCaseTestSuite) TestCreatePatientSuccess() {
 	s.Equal(expectedTask, t)
 	s.False(t.AuthoredOn.Time().IsZero())
 	s.NotEmpty(t.ID)
+	s.Equal(1, len(t.Extension))
+	s.Equal(entity.StructureDefinitionTaskOTPDelivery, t.Extension[0].URL)
+}
+
+func (s *useCaseTestSuite) TestValidateCreatePatient() {
+	ctx := context.Background()
+
+	p := new(fhirModel.Parameters)
+	err := json.Unmarshal([]byte(createPatientReqBody), p)
+	s.NoError(err)
+
+	o, err := s.uc.ValidateCreatePatient(ctx, p)
+	s.NoError(err)
+	s.Equal(1, len(o.Issue))
+	s.Equal(entity.OutcomeIssueSeverityInformation, o.Issue[0].Severity)
+
+	s.Equal(0, len(s.otp.otps))
+	s.Equal(0, len(s.fhir.bundles))
+
+	patient := new(fhirModel.Patient)
+	interfaceToStruct(p.Parameter[0].Resource, patient)
+
+	patient.Active = converto.BoolPointer(false)
+	p.Parameter[0].Resource = patient
+	s.fhir.duplPatients = []*fhirModel.Patient{{}}
+
+	o, err = s.uc.ValidateCreatePatient(ctx, p)
+	s.NoError(err)
+	s.Equal(2, len(o.Issue))
+	s.Equal([]string{"Parameters.parameter[0].resource.active"}, o.Issue[0].Expression)
+	s.Equal("such person already exists", o.Issue[1].Diagnostics)
+
+	s.Equal(0, len(s.otp.otps))
+	s.Equal(0, len(s.fhir.bundles))
 }
 
 func (s *useCaseTestSuite) TestCreatePatientValidateParameters() {
@@ -286,7 +436,44 @@ func (s *useCaseTestSuite) TestCreatePatientValidateParameters() {
 
 	_, err = s.uc.CreatePatient(ctx, p)
 	s.Error(err)
-	s.Contains(err.Error(), "forbidden parameters: deceasedBoolean,photo")
+
+	cErr, ok := err.(*cerror.CError)
+	s.True(ok)
+	s.Equal(map[string]string{
+		"Parameters.parameter[0].resource.deceasedBoolean": "forbidden parameter",
+		"Parameters.parameter[0].resource.photo":           "forbidden parameter",
+	}, cErr.Payload())
+
+	patientResourceMap["active"] = false
+	p.Parameter = append(p.Parameter, &fhirModel.ParametersParameter{Name: "other"})
+
+	_, err = s.uc.CreatePatient(ctx, p)
+	s.Error(err)
+
+	cErr, ok = err.(*cerror.CError)
+	s.True(ok)
+	s.Equal(map[string]string{
+		"Parameters.parameter":                             "expected to have 1 element",
+		"Parameters.parameter[0].resource.active":          "should have true value",
+		"Parameters.parameter[0].resource.deceasedBoolean": "forbidden parameter",
+		"Parameters.parameter[0].resource.photo":           "forbidden parameter",
+	}, cErr.Payload())
+
+	// the parameter and the identifier issues are returned together
+	patient.Identifier[0].Value = "1234567890"
+	patientResourceMap = mapFromStruct(patient)
+	patientResourceMap["photo"] = nil
+	p.Parameter = []*fhirModel.ParametersParameter{{Resource: patientResourceMap}}
+
+	_, err = s.uc.CreatePatient(ctx, p)
+	s.Error(err)
+
+	cErr, ok = err.(*cerror.CError)
+	s.True(ok)
+	s.Equal(map[string]string{
+		"Parameters.parameter[0].resource.identifier[0].value": "invalid identifier value format",
+		"Parameters.parameter[0].resource.photo":               "forbidden parameter",
+	}, cErr.Payload())
 }
 
 func (s *useCaseTestSuite) TestCreatePatientInternalValidate() {
@@ -335,6 +522,22 @@ func (s *useCaseTestSuite) TestCreatePatientInternalValidate() {
 	s.Contains(err.Error(), "identifier code should be one of NI,DP,CZ,JHN")
 
 	patient.Identifier[0].Type.Codings[0].Code = "NI"
+	patient.Identifier[0].Value = "1234567890"
+	patient.Identifier[0].Period.End = (*fhirModel.DateTime)(converto.TimePointer(time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)))
+	p.Parameter[0].Resource = patient
+
+	_, err = s.uc.CreatePatient(ctx, p)
+	s.Error(err)
+
+	cErr, ok := err.(*cerror.CError)
+	s.True(ok)
+	s.Equal(map[string]string{
+		"Parameters.parameter[0].resource.identifier[0].period.end": "identifier is expired",
+		"Parameters.parameter[0].resource.identifier[0].value":      "invalid identifier value format",
+	}, cErr.Payload())
+
+	patient.Identifier[0].Value = validNIValue
+	patient.Identifier[0].Period.End = (*fhirModel.DateTime)(converto.TimePointer(time.Now().UTC()))
 	p.Parameter[0].Resource = patient
 
 	_, err = s.uc.CreatePatient(ctx, p)
@@ -525,6 +728,7 @@ func (s *useCaseTestSuite) TestConfirmCreatePatientSuccess() {
 	s.Equal("Parameters/"+p.ID.String(), bundleTask.Input[1].ValueReference.Reference)
 	s.Equal(1, len(bundleTask.Output))
 	s.Equal("Patient/"+patientParams.ID.String(), bundleTask.Output[0].ValueReference.Reference)
+	s.Equal("Patient/"+patientParams.ID.String(), bundleTask.For.Reference)
 
 	s.Equal(bundleTask, actualTask)
 
@@ -819,6 +1023,7 @@ func (s *useCaseTestSuite) TestUpdatePatient() {
 	s.Equal(fhirModel.TaskIntentOrder, task.Intent)
 	s.Equal(1, len(task.Input))
 	s.Equal(fmt.Sprintf("%s/%s", fhirModel.ResourceParameters, id), task.Input[0].ValueReference.Reference)
+	s.Equal(fmt.Sprintf("%s/%s", fhirModel.ResourcePatient, id), task.For.Reference)
 }
 
 func (s *useCaseTestSuite) TestUpdatePatientIdentitySuccess() {
@@ -869,10 +1074,12 @@ func (s *useCaseTestSuite) TestUpdatePatientIdentitySuccess() {
 				ResourceType: fhirModel.ResourceTask,
 				Meta:         &fhirModel.Meta{Profile: []string{fhirModel.StructureDefinitionTaskPatientUpdateIdentity}},
 			},
+			Extension: t.Extension,
 		},
 		Status:         fhirModel.TaskStatusInProgress,
 		BusinessStatus: &fhirModel.CodeableConcept{Text: entity.TaskBusinessStatusPatientIdentityUpdated},
 		Intent:         fhirModel.TaskIntentOrder,
+		For:            &fhirModel.Reference{Reference: fmt.Sprintf("%s/%s", fhirModel.ResourcePatient, id)},
 		AuthoredOn:     t.AuthoredOn,
 		Input: []*fhirModel.TaskInput{
 			{
@@ -896,6 +1103,8 @@ func (s *useCaseTestSuite) TestUpdatePatientIdentitySuccess() {
 	s.Equal(expectedTask, t)
 	s.False(t.AuthoredOn.Time().IsZero())
 	s.NotEmpty(t.ID)
+	s.Equal(1, len(t.Extension))
+	s.Equal(entity.StructureDefinitionTaskOTPDelivery, t.Extension[0].URL)
 }
 
 func (s *useCaseTestSuite) TestUpdatePatientIdentityInternalValidate() {
@@ -931,11 +1140,17 @@ func (s *useCaseTestSuite) TestUpdatePatientIdentityInternalValidate() {
 	patient := new(fhirModel.Patient)
 	interfaceToStruct(p.Parameter[1].Resource, patient)
 
+	// the request issues are returned together before the patient is read
 	_, err = s.uc.UpdatePatientIdentity(ctx, id, p)
 	s.Error(err)
 	s.Contains(err.Error(), "url id and patient id")
+	s.Contains(err.Error(), "identifier is expired")
 
 	id = fhirModel.ID("244a8e88-c0b0-4d60-b5d7-14afbe79f5f5")
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	patient.Identifier[0].Period.End = (*fhirModel.DateTime)(converto.TimePointer(today))
+	p.Parameter[1].Resource = patient
 
 	_, err = s.uc.UpdatePatientIdentity(ctx, id, p)
 	s.Error(err)
@@ -949,13 +1164,6 @@ func (s *useCaseTestSuite) TestUpdatePatientIdentityInternalValidate() {
 
 	s.fhir.patients[0].DeceasedBoolean = converto.BoolPointer(false)
 
-	_, err = s.uc.UpdatePatientIdentity(ctx, id, p)
-	s.Error(err)
-	s.Contains(err.Error(), "identifier is expired")
-
-	now := time.Now().UTC()
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
-	patient.Identifier[0].Period.End = (*fhirModel.DateTime)(converto.TimePointer(today))
 	patient.Extension[0].Extension[0].ValueCodeableConcept.Codings[0].Code = "AA"
 	patient.Identifier[0].Type.Codings[0].Code = "AA"
 	p.Parameter[1].Resource = patient
@@ -1501,6 +1709,2346 @@ func (s *useCaseTestSuite) TestUpdateEmailPatient() {
 	s.Equal("completed", task.Status)
 }
 
+func (s *useCaseTestSuite) TestGetPatient() {
+	ctx := context.Background()
+	id := fhirModel.ID("9e293127-8ffc-462c-aea0-d5464794b526")
+
+	_, err := s.uc.GetPatient(ctx, id, &entity.GetPatientParams{})
+	s.Error(err)
+	s.Equal(cerror.KindNotExist.String(), cerror.ErrKind(err).String())
+	s.Contains(err.Error(), "such patient does not exist")
+
+	s.fhir.patients = []*fhirModel.Patient{
+		{
+			DomainResource: fhirModel.DomainResource{
+				Resource: fhirModel.Resource{
+					ID:           id,
+					ResourceType: fhirModel.ResourcePatient,
+				},
+			},
+			Active:        converto.BoolPointer(true),
+			Gender:        "male",
+			MaritalStatus: &fhirModel.CodeableConcept{Text: "M"},
+		},
+	}
+
+	p, err := s.uc.GetPatient(ctx, id, &entity.GetPatientParams{})
+	s.NoError(err)
+	s.Equal(mapFromStruct(s.fhir.patients[0]), p)
+
+	p, err = s.uc.GetPatient(ctx, id, &entity.GetPatientParams{Summary: entity.SummaryTrue})
+	s.NoError(err)
+	s.Equal(true, p["active"])
+	s.Equal("male", p["gender"])
+	s.NotContains(p, "maritalStatus")
+	s.Equal(
+		map[string]interface{}{"tag": []interface{}{
+			map[string]interface{}{"system": entity.MetaTagSystemObservationValue, "code": entity.MetaTagSubsetted},
+		}},
+		p["meta"])
+
+	p, err = s.uc.GetPatient(ctx, id, &entity.GetPatientParams{Elements: []string{"maritalStatus"}})
+	s.NoError(err)
+	s.Contains(p, "maritalStatus")
+	s.Contains(p, "id")
+	s.Contains(p, "resourceType")
+	s.NotContains(p, "active")
+	s.NotContains(p, "gender")
+
+	_, err = s.uc.GetPatient(ctx, id, &entity.GetPatientParams{Summary: "count"})
+	s.Error(err)
+	s.Contains(err.Error(), "unsupported value")
+
+	_, err = s.uc.GetPatient(ctx, id, &entity.GetPatientParams{
+		Elements: []string{"gender"},
+		Summary:  entity.SummaryTrue,
+	})
+	s.Error(err)
+	s.Contains(err.Error(), "can not be combined with _summary")
+}
+
+func (s *useCaseTestSuite) TestSearchPatients() {
+	ctx := context.Background()
+
+	_, err := s.uc.SearchPatients(ctx, &entity.SearchPatientParams{})
+	s.Error(err)
+	s.Contains(err.Error(), "either identifier or phone with birthdate should be passed")
+
+	_, err = s.uc.SearchPatients(ctx, &entity.SearchPatientParams{
+		Phone: &entity.SearchPatientByPhoneParams{Phone: "+380673212121"},
+	})
+	s.Error(err)
+	s.Contains(err.Error(), "phone and birthdate should be passed together")
+
+	_, err = s.uc.SearchPatients(ctx, &entity.SearchPatientParams{
+		Identifier: &entity.SearchPatientByIdentifierParams{Value: "system|value"},
+		Offset:     entity.PatientSearchMaxResults,
+	})
+	s.Error(err)
+	s.Contains(err.Error(), "_offset")
+
+	for i := 0; i < 3; i++ {
+		s.fhir.duplPatients = append(s.fhir.duplPatients, &fhirModel.Patient{
+			DomainResource: fhirModel.DomainResource{
+				Resource: fhirModel.Resource{ID: fhirModel.ID(fmt.Sprintf("patient-%d", i))},
+			},
+		})
+	}
+
+	b, err := s.uc.SearchPatients(ctx, &entity.SearchPatientParams{
+		Identifier: &entity.SearchPatientByIdentifierParams{Value: "system|value"},
+		Count:      2,
+	})
+	s.NoError(err)
+	s.Equal(fhirModel.BundleTypeSearchset, b.Type)
+	s.Equal(2, len(b.Entry))
+	s.Equal("Patient/patient-0", b.Entry[0].FullURL)
+	s.Equal(entity.SearchEntryModeMatch, b.Entry[0].Search.Mode)
+	s.Equal(2, len(b.Link))
+	s.Equal(entity.BundleLinkNext, b.Link[1].Relation)
+	s.Equal("Patient?_count=2&_offset=2&identifier=system%7Cvalue", b.Link[1].URL)
+	s.Equal(3, s.fhir.searchPatientByParamsArgs[0].Count)
+
+	b, err = s.uc.SearchPatients(ctx, &entity.SearchPatientParams{
+		Phone:  &entity.SearchPatientByPhoneParams{Phone: "+380673212121", BirthDate: "2000-01-01"},
+		Count:  entity.PatientSearchMaxCount * 2,
+		Offset: entity.PatientSearchMaxResults - 5,
+	})
+	s.NoError(err)
+	s.Equal(3, len(b.Entry))
+	s.Equal(6, s.fhir.searchPatientByParamsArgs[1].Count)
+	s.Equal(entity.BundleLinkPrevious, b.Link[1].Relation)
+
+	// the last page has no next link even if more patients are found
+	b, err = s.uc.SearchPatients(ctx, &entity.SearchPatientParams{
+		Identifier: &entity.SearchPatientByIdentifierParams{Value: "system|value"},
+		Count:      2,
+		Offset:     entity.PatientSearchMaxResults - 2,
+	})
+	s.NoError(err)
+	s.Equal(2, len(b.Entry))
+	s.Equal(2, len(b.Link))
+	s.Equal(entity.BundleLinkPrevious, b.Link[1].Relation)
+}
+
+func (s *useCaseTestSuite) TestGetTask() {
+	ctx := context.Background()
+	id := fhirModel.ID("9e293127-8ffc-462c-aea0-d5464794b526")
+
+	_, err := s.uc.GetTask(ctx, id)
+	s.Error(err)
+	s.Equal(cerror.KindNotExist.String(), cerror.ErrKind(err).String())
+
+	s.fhir.tasks = []*fhirModel.Task{{
+		DomainResource: fhirModel.DomainResource{
+			Resource: fhirModel.Resource{
+				ID:   id,
+				Meta: &fhirModel.Meta{Profile: []string{"some-other-profile"}},
+			},
+		},
+		Status: fhirModel.TaskStatusInProgress,
+	}}
+
+	_, err = s.uc.GetTask(ctx, id)
+	s.Error(err)
+	s.Equal(cerror.KindNotExist.String(), cerror.ErrKind(err).String())
+
+	s.fhir.tasks[0].Meta.Profile = []string{fhirModel.StructureDefinitionTaskPatientCreate}
+
+	t, err := s.uc.GetTask(ctx, id)
+	s.NoError(err)
+	s.Equal(s.fhir.tasks[0], t)
+}
+
+func (s *useCaseTestSuite) TestSearchTasks() {
+	ctx := context.Background()
+
+	_, err := s.uc.SearchTasks(ctx, &entity.SearchTaskParams{})
+	s.Error(err)
+	s.Contains(err.Error(), "patient")
+
+	_, err = s.uc.SearchTasks(ctx, &entity.SearchTaskParams{PatientID: "123", Status: "unknown"})
+	s.Error(err)
+	s.Contains(err.Error(), "status")
+
+	_, err = s.uc.SearchTasks(ctx, &entity.SearchTaskParams{PatientID: "123", Profiles: []string{"unknown"}})
+	s.Error(err)
+	s.Contains(err.Error(), "profile")
+
+	s.fhir.tasks = []*fhirModel.Task{
+		{
+			DomainResource: fhirModel.DomainResource{
+				Resource: fhirModel.Resource{
+					ID:   "task-1",
+					Meta: &fhirModel.Meta{Profile: []string{fhirModel.StructureDefinitionTaskPatientUpdateIdentity}},
+				},
+			},
+		},
+		{
+			DomainResource: fhirModel.DomainResource{
+				Resource: fhirModel.Resource{
+					ID:   "task-2",
+					Meta: &fhirModel.Meta{Profile: []string{"some-other-profile"}},
+				},
+			},
+		},
+	}
+
+	b, err := s.uc.SearchTasks(ctx, &entity.SearchTaskParams{PatientID: "123", Status: fhirModel.TaskStatusInProgress})
+	s.NoError(err)
+	s.Equal(fhirModel.BundleTypeSearchset, b.Type)
+	s.Equal(1, len(b.Entry))
+	s.Equal("Task/task-1", b.Entry[0].FullURL)
+	s.Equal(entity.MPITaskProfiles(), s.fhir.searchTaskByParamsArgs[0].Profiles)
+	s.Equal(fhirModel.ID("123"), s.fhir.searchTaskByParamsArgs[0].PatientID)
+}
+
+func (s *useCaseTestSuite) TestCancelTask() {
+	ctx := context.Background()
+	id := fhirModel.ID("9e293127-8ffc-462c-aea0-d5464794b526")
+	p := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("b488aa02-f181-4b50-bdca-63b74c5ee447"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionTaskCancelRequest}},
+		},
+	}
+
+	_, err := s.uc.CancelTask(ctx, id, p)
+	s.Error(err)
+	s.Contains(err.Error(), "missing reason parameter")
+
+	p.Parameter = []*fhirModel.ParametersParameter{
+		{Name: "reason", ValueX: fhirModel.ValueX{ValueString: converto.StringPointer("changed my mind")}},
+	}
+
+	_, err = s.uc.CancelTask(ctx, id, p)
+	s.Error(err)
+	s.Equal(cerror.KindNotExist.String(), cerror.ErrKind(err).String())
+
+	s.fhir.tasks = []*fhirModel.Task{{
+		DomainResource: fhirModel.DomainResource{
+			Resource: fhirModel.Resource{
+				ID:   id,
+				Meta: &fhirModel.Meta{Profile: []string{fhirModel.StructureDefinitionTaskPatientUpdate}},
+			},
+		},
+		Status: fhirModel.TaskStatusInProgress,
+	}}
+
+	_, err = s.uc.CancelTask(ctx, id, p)
+	s.Error(err)
+	s.Contains(err.Error(), "can not be canceled")
+
+	s.fhir.tasks[0].Meta.Profile = []string{fhirModel.StructureDefinitionTaskPatientCreate}
+	s.fhir.tasks[0].Status = fhirModel.TaskStatusCompleted
+
+	_, err = s.uc.CancelTask(ctx, id, p)
+	s.Error(err)
+	s.Contains(err.Error(), "such patient request is not active")
+
+	s.fhir.tasks[0].Status = fhirModel.TaskStatusInProgress
+
+	t, err := s.uc.CancelTask(ctx, id, p)
+	s.NoError(err)
+	s.Equal(fhirModel.TaskStatusCanceled, t.Status)
+	s.Equal(&fhirModel.CodeableConcept{Text: entity.TaskBusinessStatusCanceledByRequest}, t.BusinessStatus)
+	s.Equal(&fhirModel.CodeableConcept{Text: "changed my mind"}, t.StatusReason)
+	s.Equal("Parameters/"+p.ID.String(), t.Input[0].ValueReference.Reference)
+
+	s.Equal(1, len(s.fhir.bundles))
+	s.Equal(2, len(s.fhir.bundles[0].Entry))
+	s.Equal(p, s.fhir.bundles[0].Entry[0].Resource)
+	s.Equal(http.MethodPut, s.fhir.bundles[0].Entry[1].Request.Method)
+	s.Equal("Task/"+id.String(), s.fhir.bundles[0].Entry[1].Request.URL)
+}
+
+func (s *useCaseTestSuite) TestResendOTP() {
+	ctx := context.Background()
+	taskID := fhirModel.ID("9e293127-8ffc-462c-aea0-d5464794b526")
+	p := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("b488aa02-f181-4b50-bdca-63b74c5ee447"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientResendOTPRequest}},
+		},
+	}
+
+	_, err := s.uc.ResendOTP(ctx, p)
+	s.Error(err)
+	s.Contains(err.Error(), "missing task_id parameter")
+
+	p.Parameter = []*fhirModel.ParametersParameter{
+		{Name: "task_id", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Task/" + taskID.String()}}},
+	}
+
+	_, err = s.uc.ResendOTP(ctx, p)
+	s.Error(err)
+	s.Equal(cerror.KindNotExist.String(), cerror.ErrKind(err).String())
+
+	patientParams := new(fhirModel.Parameters)
+	err = json.Unmarshal([]byte(createPatientReqBody), patientParams)
+	s.NoError(err)
+
+	s.fhir.parameters = []*fhirModel.Parameters{patientParams}
+
+	authoredOn := time.Now().UTC().Add(-2 * entity.OTPResendCooldown)
+	s.fhir.tasks = []*fhirModel.Task{{
+		DomainResource: fhirModel.DomainResource{
+			Resource: fhirModel.Resource{
+				ID:   taskID,
+				Meta: &fhirModel.Meta{Profile: []string{fhirModel.StructureDefinitionTaskPatientCreate}},
+			},
+		},
+		Status:     fhirModel.TaskStatusInProgress,
+		AuthoredOn: (*fhirModel.DateTime)(converto.TimePointer(authoredOn)),
+		Input: []*fhirModel.TaskInput{
+			{
+				ValueX: fhirModel.ValueX{
+					ValueReference: &fhirModel.Reference{Reference: "Parameters/" + patientParams.ID.String()},
+				},
+			},
+		},
+	}}
+
+	t, err := s.uc.ResendOTP(ctx, p)
+	s.NoError(err)
+	s.Equal(1, len(s.otp.otps))
+	s.Equal("+380673212121", s.otp.otps[taskID.String()].Value)
+	s.Equal(2, len(t.Extension))
+	s.Equal(entity.StructureDefinitionTaskOTPDelivery, t.Extension[0].URL)
+	s.Equal(entity.StructureDefinitionTaskOTPResent, t.Extension[1].URL)
+	s.Equal(1, len(s.fhir.bundles))
+	s.Equal(2, len(s.fhir.bundles[0].Entry))
+	s.Equal("Task/"+taskID.String(), s.fhir.bundles[0].Entry[1].Request.URL)
+
+	// the otp has just been resent so the cooldown is not over yet
+	_, err = s.uc.ResendOTP(ctx, p)
+	s.Error(err)
+	s.Contains(err.Error(), "otp can be resent in")
+
+	sentAt := (*fhirModel.DateTime)(converto.TimePointer(authoredOn))
+	s.fhir.tasks[0].Extension = nil
+
+	for i := 0; i < entity.OTPResendMaxCount; i++ {
+		s.fhir.tasks[0].Extension = append(s.fhir.tasks[0].Extension, &fhirModel.Extension{
+			URL:    entity.StructureDefinitionTaskOTPResent,
+			ValueX: fhirModel.ValueX{ValueDateTime: sentAt},
+		})
+	}
+
+	_, err = s.uc.ResendOTP(ctx, p)
+	s.Error(err)
+	s.Contains(err.Error(), "otp resend limit for such patient request is reached")
+
+	s.fhir.tasks[0].Status = fhirModel.TaskStatusCompleted
+
+	_, err = s.uc.ResendOTP(ctx, p)
+	s.Error(err)
+	s.Contains(err.Error(), "such patient request is not active")
+
+	s.fhir.tasks[0].Meta.Profile = []string{fhirModel.StructureDefinitionTaskPatientUpdate}
+
+	_, err = s.uc.ResendOTP(ctx, p)
+	s.Error(err)
+	s.Contains(err.Error(), "can not be resent")
+}
+
+func (s *useCaseTestSuite) TestResendOTPUpdatePatientIdentity() {
+	taskID := fhirModel.ID("9e293127-8ffc-462c-aea0-d5464794b526")
+	p := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("b488aa02-f181-4b50-bdca-63b74c5ee447"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientResendOTPRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "task_id", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Task/" + taskID.String()}}},
+		},
+	}
+
+	identityParams := new(fhirModel.Parameters)
+	err := json.Unmarshal([]byte(updatePatientIdentityReqBody), identityParams)
+	s.NoError(err)
+
+	s.fhir.parameters = []*fhirModel.Parameters{identityParams}
+	s.fhir.tasks = []*fhirModel.Task{{
+		DomainResource: fhirModel.DomainResource{
+			Resource: fhirModel.Resource{
+				ID:   taskID,
+				Meta: &fhirModel.Meta{Profile: []string{fhirModel.StructureDefinitionTaskPatientUpdateIdentity}},
+			},
+		},
+		Status: fhirModel.TaskStatusInProgress,
+		Input: []*fhirModel.TaskInput{
+			{
+				ValueX: fhirModel.ValueX{
+					ValueReference: &fhirModel.Reference{Reference: "Parameters/" + identityParams.ID.String()},
+				},
+			},
+		},
+	}}
+
+	_, err = s.uc.ResendOTP(context.Background(), p)
+	s.NoError(err)
+	s.Equal("+380672200333", s.otp.otps[taskID.String()].Value)
+}
+
+func (s *useCaseTestSuite) TestResendOTPUpdatePatientPhone() {
+	patient := preparePhonePatient(s)
+	taskID := fhirModel.ID("9e293127-8ffc-462c-aea0-d5464794b526")
+	p := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("b488aa02-f181-4b50-bdca-63b74c5ee447"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientResendOTPRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "task_id", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Task/" + taskID.String()}}},
+		},
+	}
+
+	phoneParams := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{ID: fhirModel.ID("2f0e4a8b-6c1d-4d8e-9b7a-3c5d6e7f8a9b")},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "phone", ValueX: fhirModel.ValueX{ValueString: converto.StringPointer("+380671112233")}},
+			{Name: "confirmOldPhone", ValueX: fhirModel.ValueX{ValueBoolean: converto.BoolPointer(true)}},
+		},
+	}
+	s.fhir.parameters = []*fhirModel.Parameters{phoneParams}
+	s.fhir.tasks = []*fhirModel.Task{{
+		DomainResource: fhirModel.DomainResource{
+			Resource: fhirModel.Resource{
+				ID:   taskID,
+				Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionTaskPatientUpdatePhone}},
+			},
+		},
+		Status: fhirModel.TaskStatusInProgress,
+		For:    &fhirModel.Reference{Reference: "Patient/" + patient.ID.String()},
+		Input: []*fhirModel.TaskInput{
+			{
+				ValueX: fhirModel.ValueX{
+					ValueReference: &fhirModel.Reference{Reference: "Parameters/" + phoneParams.ID.String()},
+				},
+			},
+		},
+	}}
+
+	_, err := s.uc.ResendOTP(context.Background(), p)
+	s.NoError(err)
+	s.Equal("+380671112233", s.otp.otps[taskID.String()].Value)
+	s.Equal("+380673212121", s.otp.otps[taskID.String()+entity.OTPProcessIDOldPhoneSuffix].Value)
+}
+
+func (s *useCaseTestSuite) TestUpdatePatientPhone() {
+	ctx := context.Background()
+	patient := preparePhonePatient(s)
+	p := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("b488aa02-f181-4b50-bdca-63b74c5ee447"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientUpdatePhoneRequest}},
+		},
+	}
+
+	_, err := s.uc.UpdatePatientPhone(ctx, patient.ID, p)
+	s.Error(err)
+	s.Contains(err.Error(), "missing phone parameter")
+
+	p.Parameter = []*fhirModel.ParametersParameter{
+		{Name: "phone", ValueX: fhirModel.ValueX{ValueString: converto.StringPointer("+380673212121")}},
+		{Name: "confirmOldPhone", ValueX: fhirModel.ValueX{ValueBoolean: converto.BoolPointer(true)}},
+	}
+
+	_, err = s.uc.UpdatePatientPhone(ctx, patient.ID, p)
+	s.Error(err)
+	s.Contains(err.Error(), "patient already has such phone")
+
+	p.Parameter[0].ValueString = converto.StringPointer("+380671112233")
+
+	for i := 0; i < entity.MaxPatientsWithSamePhone; i++ {
+		s.fhir.duplPatients = append(s.fhir.duplPatients, &fhirModel.Patient{})
+	}
+
+	_, err = s.uc.UpdatePatientPhone(ctx, patient.ID, p)
+	s.Error(err)
+	s.Contains(err.Error(), "too many persons with same phone")
+	s.Equal("+380671112233", s.fhir.searchPatientByParamsArgs[0].Phone.Phone)
+
+	s.fhir.duplPatients = nil
+	s.fhir.tasks = []*fhirModel.Task{{
+		DomainResource: fhirModel.DomainResource{
+			Resource: fhirModel.Resource{
+				ID:   fhirModel.ID("7ec2a2a3-8cf3-4f3b-9c8f-2b8c1a1bb3c4"),
+				Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionTaskPatientUpdatePhone}},
+			},
+		},
+		Status: fhirModel.TaskStatusInProgress,
+	}}
+
+	t, err := s.uc.UpdatePatientPhone(ctx, patient.ID, p)
+	s.NoError(err)
+	s.Equal(fhirModel.TaskStatusInProgress, t.Status)
+	s.Equal(&fhirModel.Reference{Reference: "Patient/" + patient.ID.String()}, t.For)
+	s.Equal(&entity.SearchTaskParams{
+		PatientID: patient.ID,
+		Status:    fhirModel.TaskStatusInProgress,
+		Profiles:  []string{entity.StructureDefinitionTaskPatientUpdatePhone},
+	}, s.fhir.searchTaskByParamsArgs[0])
+
+	s.Equal(2, len(s.otp.otps))
+	s.Equal("+380671112233", s.otp.otps[t.ID.String()].Value)
+	s.Equal("+380673212121", s.otp.otps[t.ID.String()+entity.OTPProcessIDOldPhoneSuffix].Value)
+
+	s.Equal(1, len(s.fhir.bundles))
+	s.Equal(3, len(s.fhir.bundles[0].Entry))
+	s.Equal(fhirModel.TaskStatusCanceled, s.fhir.tasks[0].Status)
+}
+
+func (s *useCaseTestSuite) TestConfirmUpdatePatientPhone() {
+	ctx := context.Background()
+	patient := preparePhonePatient(s)
+	taskID := fhirModel.ID("9e293127-8ffc-462c-aea0-d5464794b526")
+
+	phoneParams := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{ID: fhirModel.ID("2f0e4a8b-6c1d-4d8e-9b7a-3c5d6e7f8a9b")},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "phone", ValueX: fhirModel.ValueX{ValueString: converto.StringPointer("+380671112233")}},
+			{Name: "confirmOldPhone", ValueX: fhirModel.ValueX{ValueBoolean: converto.BoolPointer(true)}},
+		},
+	}
+	s.fhir.parameters = []*fhirModel.Parameters{phoneParams}
+
+	s.fhir.tasks = []*fhirModel.Task{{
+		DomainResource: fhirModel.DomainResource{
+			Resource: fhirModel.Resource{
+				ID:   taskID,
+				Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionTaskPatientUpdatePhone}},
+			},
+		},
+		Status: fhirModel.TaskStatusInProgress,
+		For:    &fhirModel.Reference{Reference: "Patient/" + patient.ID.String()},
+		Input: []*fhirModel.TaskInput{
+			{
+				ValueX: fhirModel.ValueX{
+					ValueReference: &fhirModel.Reference{Reference: "Parameters/" + phoneParams.ID.String()},
+				},
+			},
+		},
+	}}
+
+	s.otp.otps = map[string]*entity.OTP{
+		taskID.String(): {Code: "1234", Value: "+380671112233"},
+		taskID.String() + entity.OTPProcessIDOldPhoneSuffix: {Code: "5678", Value: "+380673212121"},
+	}
+
+	p := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("b488aa02-f181-4b50-bdca-63b74c5ee447"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientConfirmUpdatePhoneRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "otp", ValueX: fhirModel.ValueX{ValueString: converto.StringPointer("1234")}},
+			{Name: "task_id", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Task/" + taskID.String()}}},
+		},
+	}
+
+	_, err := s.uc.ConfirmUpdatePatientPhone(ctx, fhirModel.ID("another-patient"), p)
+	s.Error(err)
+	s.Equal(cerror.KindNotExist.String(), cerror.ErrKind(err).String())
+
+	_, err = s.uc.ConfirmUpdatePatientPhone(ctx, patient.ID, p)
+	s.Error(err)
+	s.Contains(err.Error(), "missing oldPhoneOtp parameter")
+
+	p.Parameter = append(p.Parameter, &fhirModel.ParametersParameter{
+		Name: "oldPhoneOtp", ValueX: fhirModel.ValueX{ValueString: converto.StringPointer("0000")},
+	})
+
+	_, err = s.uc.ConfirmUpdatePatientPhone(ctx, patient.ID, p)
+	s.Error(err)
+	s.Equal(cerror.KindNotExist.String(), cerror.ErrKind(err).String())
+
+	p.Parameter[2].ValueString = converto.StringPointer("5678")
+
+	t, err := s.uc.ConfirmUpdatePatientPhone(ctx, patient.ID, p)
+	s.NoError(err)
+	s.Equal(fhirModel.TaskStatusCompleted, t.Status)
+	s.Equal(&fhirModel.CodeableConcept{Text: entity.TaskBusinessStatusPatientPhoneUpdated}, t.BusinessStatus)
+	s.Equal("Patient/"+patient.ID.String(), t.Output[0].ValueReference.Reference)
+
+	s.Equal(1, len(s.fhir.bundles))
+	s.Equal(3, len(s.fhir.bundles[0].Entry))
+
+	bundlePatient, ok := s.fhir.bundles[0].Entry[1].Resource.(*fhirModel.Patient)
+	s.True(ok)
+	s.Equal(1, len(bundlePatient.Telecom))
+	s.Equal("+380671112233", bundlePatient.Telecom[0].Value)
+}
+
+func (s *useCaseTestSuite) TestUpdatePatientEmailWithVerification() {
+	ctx := context.Background()
+	s.uc.WithEmailVerification(true)
+	id := fhirModel.ID("9e293127-8ffc-462c-aea0-d5464794b527")
+
+	p := new(fhirModel.Parameters)
+	err := json.Unmarshal([]byte(updateEmailPatientReqBody), p)
+	s.NoError(err)
+
+	p.Parameter = append(p.Parameter, &fhirModel.ParametersParameter{
+		Name: "verify", ValueX: fhirModel.ValueX{ValueBoolean: converto.BoolPointer(true)},
+	})
+
+	s.fhir.patients = []*fhirModel.Patient{
+		{
+			DomainResource: fhirModel.DomainResource{
+				Resource: fhirModel.Resource{ID: id},
+			},
+			Active: converto.BoolPointer(true),
+			Telecom: []*fhirModel.ContactPoint{
+				{System: fhirModel.TelecomSystemEmail, Value: "test@test.com"},
+			},
+		},
+	}
+
+	t, err := s.uc.UpdatePatientEmail(ctx, id, p)
+	s.NoError(err)
+	s.Equal(fhirModel.TaskStatusInProgress, t.Status)
+	s.Equal([]string{fhirModel.StructureDefinitionTaskPatientUpdateEmail}, t.Meta.Profile)
+	s.Equal("test@test1.test", s.otp.otps[t.ID.String()].Value)
+
+	s.Equal(1, len(s.fhir.bundles))
+	s.Equal(3, len(s.fhir.bundles[0].Entry))
+
+	bundlePatient, ok := s.fhir.bundles[0].Entry[2].Resource.(*fhirModel.Patient)
+	s.True(ok)
+	s.Equal(2, len(bundlePatient.Telecom))
+	s.Equal("test@test.com", bundlePatient.Telecom[0].Value)
+	s.Equal("test@test1.test", bundlePatient.Telecom[1].Value)
+	s.Equal(entity.StructureDefinitionContactPointVerificationStatus, bundlePatient.Telecom[1].Extension[0].URL)
+	s.Equal(entity.ContactPointVerificationStatusPending, converto.StringValue(bundlePatient.Telecom[1].Extension[0].ValueCode))
+
+	// the email otp is resent to the pending email
+	delete(s.otp.otps, t.ID.String())
+	t.AuthoredOn = (*fhirModel.DateTime)(converto.TimePointer(time.Now().UTC().Add(-time.Hour)))
+	s.fhir.tasks = []*fhirModel.Task{t}
+
+	_, err = s.uc.ResendOTP(ctx, &fhirModel.Parameters{
+		Resource: fhirModel.Resource{ID: fhirModel.ID("b488aa02-f181-4b50-bdca-63b74c5ee447")},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "task_id", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Task/" + t.ID.String()}}},
+		},
+	})
+	s.NoError(err)
+	s.Equal("test@test1.test", s.otp.otps[t.ID.String()].Value)
+
+	// the pending email is dropped with the canceled task
+	_, err = s.uc.CancelTask(ctx, t.ID, &fhirModel.Parameters{
+		Resource: fhirModel.Resource{ID: fhirModel.ID("c488aa02-f181-4b50-bdca-63b74c5ee447")},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "reason", ValueX: fhirModel.ValueX{ValueString: converto.StringPointer("changed mind")}},
+		},
+	})
+	s.NoError(err)
+	s.Equal(fhirModel.TaskStatusCanceled, t.Status)
+
+	b := s.fhir.bundles[len(s.fhir.bundles)-1]
+	s.Require().Len(b.Entry, 3)
+	s.Equal(s.fhir.patients[0], b.Entry[2].Resource)
+	s.Equal(1, len(s.fhir.patients[0].Telecom))
+	s.Equal("test@test.com", s.fhir.patients[0].Telecom[0].Value)
+
+	s.uc.WithEmailVerification(false)
+
+	_, err = s.uc.UpdatePatientEmail(ctx, id, p)
+	s.Error(err)
+	s.Contains(err.Error(), "email verification is not available")
+}
+
+func (s *useCaseTestSuite) TestConfirmUpdatePatientEmail() {
+	ctx := context.Background()
+	id := fhirModel.ID("9e293127-8ffc-462c-aea0-d5464794b527")
+	taskID := fhirModel.ID("7ec2a2a3-8cf3-4f3b-9c8f-2b8c1a1bb3c4")
+
+	patient := &fhirModel.Patient{
+		DomainResource: fhirModel.DomainResource{
+			Resource: fhirModel.Resource{ID: id},
+		},
+		Active: converto.BoolPointer(true),
+		Telecom: []*fhirModel.ContactPoint{
+			{System: fhirModel.TelecomSystemEmail, Value: "test@test.com"},
+		},
+	}
+	s.fhir.patients = []*fhirModel.Patient{patient}
+
+	s.fhir.tasks = []*fhirModel.Task{{
+		DomainResource: fhirModel.DomainResource{
+			Resource: fhirModel.Resource{
+				ID:   taskID,
+				Meta: &fhirModel.Meta{Profile: []string{fhirModel.StructureDefinitionTaskPatientUpdateEmail}},
+			},
+		},
+		Status: fhirModel.TaskStatusInProgress,
+		For:    &fhirModel.Reference{Reference: "Patient/" + id.String()},
+	}}
+
+	p := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("b488aa02-f181-4b50-bdca-63b74c5ee447"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientConfirmUpdateEmailRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "otp", ValueX: fhirModel.ValueX{ValueString: converto.StringPointer("1234")}},
+			{Name: "task_id", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Task/" + taskID.String()}}},
+		},
+	}
+
+	_, err := s.uc.ConfirmUpdatePatientEmail(ctx, id, p)
+	s.Error(err)
+	s.Contains(err.Error(), "patient has no email to confirm")
+
+	patient.Telecom = append(patient.Telecom, &fhirModel.ContactPoint{
+		System: fhirModel.TelecomSystemEmail,
+		Value:  "test@test1.test",
+		Extension: []*fhirModel.Extension{
+			{
+				URL:    entity.StructureDefinitionContactPointVerificationStatus,
+				ValueX: fhirModel.ValueX{ValueCode: converto.StringPointer(entity.ContactPointVerificationStatusPending)},
+			},
+		},
+	})
+
+	_, err = s.uc.ConfirmUpdatePatientEmail(ctx, id, p)
+	s.Error(err)
+	s.Equal(cerror.KindNotExist.String(), cerror.ErrKind(err).String())
+
+	s.otp.otps = map[string]*entity.OTP{
+		taskID.String(): {Code: "1234", Value: "test@test1.test"},
+	}
+
+	t, err := s.uc.ConfirmUpdatePatientEmail(ctx, id, p)
+	s.NoError(err)
+	s.Equal(fhirModel.TaskStatusCompleted, t.Status)
+	s.Equal(&fhirModel.CodeableConcept{Text: entity.TaskBusinessStatusPatientEmailUpdated}, t.BusinessStatus)
+
+	s.Equal(1, len(s.fhir.bundles))
+
+	bundlePatient, ok := s.fhir.bundles[0].Entry[1].Resource.(*fhirModel.Patient)
+	s.True(ok)
+	s.Equal([]*fhirModel.ContactPoint{
+		{System: fhirModel.TelecomSystemEmail, Value: "test@test1.test"},
+	}, bundlePatient.Telecom)
+}
+
+func (s *useCaseTestSuite) TestCreatePatientDemographicsPolicy() {
+	mismatchedRecord := &entity.ExtDocRegistrySearchResult{
+		IsValid:     true,
+		GivenName:   "Peter",
+		FamilyName:  "Chalmers",
+		BirthDate:   "1990-01-02",
+		Gender:      "female",
+		Nationality: "SA",
+		Status:      "ACTIVE",
+	}
+
+	// matching record
+	s.extReg.result = &entity.ExtDocRegistrySearchResult{
+		IsValid:     true,
+		GivenName:   "peter",
+		FamilyName:  "CHALMERS",
+		BirthDate:   "2020-12-25",
+		Gender:      "male",
+		Nationality: "SA",
+	}
+	s.uc.WithDemographicsPolicy(entity.DemographicsPolicyReject)
+
+	params := new(fhirModel.Parameters)
+	s.Require().NoError(json.Unmarshal([]byte(createPatientReqBody), params))
+
+	task, err := s.uc.CreatePatient(context.Background(), params)
+	s.Require().NoError(err)
+	s.Require().Empty(task.Contained)
+
+	// reject
+	s.extReg.result = mismatchedRecord
+
+	params = new(fhirModel.Parameters)
+	s.Require().NoError(json.Unmarshal([]byte(createPatientReqBody), params))
+
+	_, err = s.uc.CreatePatient(context.Background(), params)
+	s.Require().Error(err)
+	s.Require().Equal(cerror.KindBadValidation, cerror.ErrKind(err))
+	s.Require().Equal(map[string]string{
+		"Parameters.parameter[0].resource.birthDate": "does not match the civil registry record",
+		"Parameters.parameter[0].resource.gender":    "does not match the civil registry record",
+	}, err.(*cerror.CError).Payload())
+
+	// warn
+	s.uc.WithDemographicsPolicy(entity.DemographicsPolicyWarn)
+
+	params = new(fhirModel.Parameters)
+	s.Require().NoError(json.Unmarshal([]byte(createPatientReqBody), params))
+
+	task, err = s.uc.CreatePatient(context.Background(), params)
+	s.Require().NoError(err)
+	s.Require().Len(task.Contained, 1)
+
+	o := task.Contained[0].(*fhirModel.OperationOutcome)
+	s.Require().Len(o.Issue, 2)
+	s.Require().Len(task.Output, 1)
+	s.Require().Equal(fhirModel.ResourceOperationOutcome, task.Output[0].Type.Codings[0].Code)
+	s.Require().Equal("#"+o.ID.String(), task.Output[0].ValueReference.Reference)
+	s.Require().Equal(entity.OutcomeIssueSeverityWarning, o.Issue[0].Severity)
+	s.Require().Equal([]string{"Parameters.parameter[0].resource.birthDate"}, o.Issue[0].Expression)
+	s.Require().Equal([]string{"Parameters.parameter[0].resource.gender"}, o.Issue[1].Expression)
+
+	savedPatient := new(fhirModel.Patient)
+	interfaceToStruct(params.Parameter[0].Resource, savedPatient)
+	s.Require().Equal("male", savedPatient.Gender)
+
+	// autocorrect
+	s.uc.WithDemographicsPolicy(entity.DemographicsPolicyAutocorrect)
+
+	params = new(fhirModel.Parameters)
+	s.Require().NoError(json.Unmarshal([]byte(createPatientReqBody), params))
+
+	task, err = s.uc.CreatePatient(context.Background(), params)
+	s.Require().NoError(err)
+	s.Require().Len(task.Contained, 1)
+	s.Require().Equal(entity.OutcomeIssueSeverityInformation,
+		task.Contained[0].(*fhirModel.OperationOutcome).Issue[0].Severity)
+
+	savedPatient = new(fhirModel.Patient)
+	interfaceToStruct(params.Parameter[0].Resource, savedPatient)
+	s.Require().Equal("female", savedPatient.Gender)
+	s.Require().Equal("1990-01-02", savedPatient.BirthDate.String())
+	s.Require().Equal("Chalmers", savedPatient.Name[0].Family)
+
+	// the arabic name is kept when the registry has the latin one
+	s.extReg.result = &entity.ExtDocRegistrySearchResult{IsValid: true, GivenName: "Peter", FamilyName: "Smith"}
+
+	params = new(fhirModel.Parameters)
+	s.Require().NoError(json.Unmarshal([]byte(createPatientReqBody), params))
+
+	patient := new(fhirModel.Patient)
+	interfaceToStruct(params.Parameter[0].Resource, patient)
+	patient.Name = []*fhirModel.HumanName{
+		{Family: "تشالمرز", Given: []string{"بيتر"}},
+		{Family: "Chalmers", Given: []string{"Peter"}},
+	}
+	params.Parameter[0].Resource = patient
+
+	_, err = s.uc.CreatePatient(context.Background(), params)
+	s.Require().NoError(err)
+
+	savedPatient = new(fhirModel.Patient)
+	interfaceToStruct(params.Parameter[0].Resource, savedPatient)
+	s.Require().Len(savedPatient.Name, 2)
+	s.Require().Equal("تشالمرز", savedPatient.Name[0].Family)
+	s.Require().Equal("Smith", savedPatient.Name[1].Family)
+}
+
+func (s *useCaseTestSuite) TestUpdatePatientIdentityDemographicsPolicy() {
+	s.extReg.result = &entity.ExtDocRegistrySearchResult{
+		IsValid:    true,
+		GivenName:  "John",
+		FamilyName: "Doe",
+	}
+	s.uc.WithDemographicsPolicy(entity.DemographicsPolicyReject)
+
+	id := fhirModel.ID("244a8e88-c0b0-4d60-b5d7-14afbe79f5f5")
+	s.fhir.patients = []*fhirModel.Patient{
+		{
+			DomainResource: fhirModel.DomainResource{Resource: fhirModel.Resource{ID: id}},
+			Active:         converto.BoolPointer(true),
+		},
+	}
+
+	params := new(fhirModel.Parameters)
+	s.Require().NoError(json.Unmarshal([]byte(updatePatientIdentityReqBody), params))
+
+	patient := new(fhirModel.Patient)
+	interfaceToStruct(params.Parameter[1].Resource, patient)
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	patient.Identifier[0].Period.End = (*fhirModel.DateTime)(converto.TimePointer(today))
+	params.Parameter[1].Resource = patient
+
+	_, err := s.uc.UpdatePatientIdentity(context.Background(), id, params)
+	s.Require().Error(err)
+	s.Require().Equal(map[string]string{
+		"Parameters.parameter[1].resource.name": "does not match the civil registry record",
+	}, err.(*cerror.CError).Payload())
+
+	// the hold policy marks the update for the review, the patient is updated by the approval
+	reviewerCtx := context.WithValue(context.Background(), entity.HeaderConsumerID, "registrar-1") //nolint:staticcheck
+	s.uc.WithDemographicsPolicy(entity.DemographicsPolicyHold).WithReviewers([]string{"registrar-1"})
+
+	task, err := s.uc.UpdatePatientIdentity(context.Background(), id, params)
+	s.Require().NoError(err)
+	s.Equal(fhirModel.TaskStatusInProgress, task.Status)
+	s.Equal(entity.TaskReasonRegistryMismatch, task.StatusReason.Codings[0].Code)
+
+	confirmParams := new(fhirModel.Parameters)
+	s.Require().NoError(json.Unmarshal([]byte(confirmUpdatePatientIdentityReqBody), confirmParams))
+	confirmParams.Parameter[1].ValueReference.Reference = "Task/" + task.ID.String()
+
+	s.fhir.parameters = []*fhirModel.Parameters{params}
+	s.fhir.tasks = []*fhirModel.Task{task}
+	s.otp.otps = map[string]*entity.OTP{task.ID.String(): {Code: "1234", Value: "+380672200333"}}
+
+	task, err = s.uc.ConfirmUpdatePatientIdentity(context.Background(), id, confirmParams)
+	s.Require().NoError(err)
+	s.Equal(fhirModel.TaskStatusOnHold, task.Status)
+	s.Equal(entity.TaskBusinessStatusRegistryMismatchReview, task.BusinessStatus.Text)
+	s.Len(s.fhir.bundles[len(s.fhir.bundles)-1].Entry, 2)
+
+	reviewParams := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("2a4b3c5d-0000-4000-8000-000000000001"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionTaskReviewRequest}},
+		},
+	}
+
+	task, err = s.uc.ApproveReviewTask(reviewerCtx, task.ID, reviewParams)
+	s.Require().NoError(err)
+	s.Equal(fhirModel.TaskStatusCompleted, task.Status)
+	s.Equal(entity.TaskBusinessStatusConfirmPatientIdentityUpdated, task.BusinessStatus.Text)
+	s.Nil(task.StatusReason)
+
+	bundle := s.fhir.bundles[len(s.fhir.bundles)-1]
+	s.Require().Len(bundle.Entry, 3)
+	s.Equal("Patient/"+id.String(), bundle.Entry[1].Request.URL)
+}
+
+func (s *useCaseTestSuite) TestCreatePatientSendOTP() {
+	ctx := context.Background()
+
+	p := new(fhirModel.Parameters)
+	s.Require().NoError(json.Unmarshal([]byte(createPatientReqBody), p))
+
+	task, err := s.uc.CreatePatient(ctx, p)
+	s.Require().NoError(err)
+
+	otp := s.otp.otps[string(task.ID)]
+	s.Require().Len(s.sms.messages, 1)
+	s.Require().Equal(&entity.SMSMessage{
+		To:       otp.Value,
+		Text:     fmt.Sprintf(entity.OTPMessageTemplates()[entity.LanguageArabic], otp.Code),
+		Language: entity.LanguageArabic,
+	}, s.sms.messages[0])
+
+	var delivery *fhirModel.Extension
+
+	for _, e := range task.Extension {
+		if e.URL == entity.StructureDefinitionTaskOTPDelivery {
+			delivery = e
+		}
+	}
+
+	s.Require().NotNil(delivery)
+	s.Require().Equal("submissionStatus", delivery.Extension[0].URL)
+	s.Require().Equal(entity.SMSStatusSent, *delivery.Extension[0].ValueCode)
+	s.Require().Equal(entity.LanguageArabic, *delivery.Extension[1].ValueCode)
+	s.Require().Equal("1", *delivery.Extension[3].ValueString)
+
+	// preferred english
+	patient := new(fhirModel.Patient)
+	interfaceToStruct(p.Parameter[0].Resource, patient)
+	patient.Communication = []*fhirModel.PatientCommunication{
+		{
+			Language:  &fhirModel.CodeableConcept{Codings: []*fhirModel.Coding{{Code: entity.LanguageEnglish}}},
+			Preferred: converto.BoolPointer(true),
+		},
+	}
+	p.Parameter[0].Resource = patient
+
+	task, err = s.uc.CreatePatient(ctx, p)
+	s.Require().NoError(err)
+	s.Require().Len(s.sms.messages, 2)
+	s.Require().Equal(entity.LanguageEnglish, s.sms.messages[1].Language)
+	s.Require().Equal(
+		fmt.Sprintf(entity.OTPMessageTemplates()[entity.LanguageEnglish], s.otp.otps[string(task.ID)].Code),
+		s.sms.messages[1].Text)
+
+	// delivery error
+	s.sms.err = cerror.NewF(ctx, cerror.KindInternal, "sms gateway error")
+	bundlesCount := len(s.fhir.bundles)
+
+	_, err = s.uc.CreatePatient(ctx, p)
+	s.Require().Error(err)
+	s.Require().Len(s.fhir.bundles, bundlesCount)
+}
+
+func (s *useCaseTestSuite) TestConfirmCreatePatientOTPAttempts() {
+	ctx := context.Background()
+
+	p := new(fhirModel.Parameters)
+	s.Require().NoError(json.Unmarshal([]byte(confirmCreatePatientReqBody), p))
+
+	patientParams := new(fhirModel.Parameters)
+	s.Require().NoError(json.Unmarshal([]byte(createPatientReqBody), patientParams))
+
+	s.fhir.parameters = []*fhirModel.Parameters{patientParams}
+	s.fhir.tasks = []*fhirModel.Task{{
+		DomainResource: fhirModel.DomainResource{
+			Resource: fhirModel.Resource{ID: fhirModel.ID("9e293127-8ffc-462c-aea0-d5464794b526")},
+		},
+		Status: fhirModel.TaskStatusInProgress,
+		Input: []*fhirModel.TaskInput{
+			{
+				ValueX: fhirModel.ValueX{
+					ValueReference: &fhirModel.Reference{Reference: "Parameters/" + patientParams.ID.String()},
+				},
+			},
+		},
+	}}
+	s.otp.otps = map[string]*entity.OTP{
+		s.fhir.tasks[0].ID.String(): {Code: "0000", Value: "+380673212121", MaxAttemptsCount: 2},
+	}
+
+	_, err := s.uc.ConfirmCreatePatient(ctx, p)
+	s.Require().Error(err)
+	s.Require().Equal(cerror.KindBadValidation, cerror.ErrKind(err))
+	s.Require().Equal(&entity.OTPAttempts{Count: 1, MaxCount: 2}, err.(*cerror.CError).Payload())
+	s.Require().Empty(s.fhir.bundles)
+
+	_, err = s.uc.ConfirmCreatePatient(ctx, p)
+	s.Require().Error(err)
+	s.Require().Equal("otp attempts for such patient request are exhausted", err.Error())
+	s.Require().Equal(0, err.(*cerror.CError).Payload().(*entity.OTPAttempts).Remaining())
+
+	s.Require().Len(s.fhir.bundles, 1)
+
+	bundleTask, ok := s.fhir.bundles[0].Entry[1].Resource.(*fhirModel.Task)
+	s.Require().True(ok)
+	s.Require().Equal(fhirModel.TaskStatusRejected, bundleTask.Status)
+	s.Require().Equal(
+		&fhirModel.CodeableConcept{Text: entity.TaskBusinessStatusOTPAttemptsExhausted}, bundleTask.BusinessStatus)
+}
+
+func (s *useCaseTestSuite) TestSweepExpiredTasks() {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	task := func(id string, authoredOn time.Time, otpExpiresAt *time.Time) *fhirModel.Task {
+		t := &fhirModel.Task{
+			DomainResource: fhirModel.DomainResource{
+				Resource: fhirModel.Resource{
+					ID:   fhirModel.ID(id),
+					Meta: &fhirModel.Meta{Profile: []string{fhirModel.StructureDefinitionTaskPatientCreate}},
+				},
+			},
+			Status:     fhirModel.TaskStatusInProgress,
+			AuthoredOn: (*fhirModel.DateTime)(converto.TimePointer(authoredOn)),
+		}
+
+		if otpExpiresAt != nil {
+			t.Extension = []*fhirModel.Extension{{
+				URL: entity.StructureDefinitionTaskOTPDelivery,
+				Extension: []*fhirModel.Extension{{
+					URL:    "expiresAt",
+					ValueX: fhirModel.ValueX{ValueDateTime: (*fhirModel.DateTime)(otpExpiresAt)},
+				}},
+			}}
+		}
+
+		return t
+	}
+
+	_, err := s.uc.SweepExpiredTasks(ctx, &entity.SweepTasksParams{})
+	s.Error(err)
+
+	otpValid := now.Add(time.Hour)
+	otpExpired := now.Add(-time.Hour)
+
+	s.fhir.pagedTasks = []*fhirModel.Task{
+		task("expired", now.Add(-48*time.Hour), nil),
+		task("otp-expired", now.Add(-48*time.Hour), &otpExpired),
+		task("otp-valid", now.Add(-48*time.Hour), &otpValid),
+		task("fresh", now.Add(-time.Hour), nil),
+		task("expired-2", now.Add(-72*time.Hour), nil),
+	}
+
+	n, err := s.uc.SweepExpiredTasks(ctx, &entity.SweepTasksParams{TTL: 24 * time.Hour, BatchSize: 2})
+	s.Require().NoError(err)
+	s.Equal(3, n)
+
+	s.Require().Len(s.fhir.searchTaskByParamsArgs, 3)
+	s.Equal(entity.PendingTaskProfiles(), s.fhir.searchTaskByParamsArgs[0].Profiles)
+	s.Equal(fhirModel.TaskStatusInProgress, s.fhir.searchTaskByParamsArgs[0].Status)
+	s.Equal(2, s.fhir.searchTaskByParamsArgs[0].Count)
+	s.Equal("authored-on", s.fhir.searchTaskByParamsArgs[0].Sort)
+	s.WithinDuration(now.Add(-24*time.Hour), *s.fhir.searchTaskByParamsArgs[0].AuthoredBefore, time.Minute)
+
+	// the page of the kept tasks does not stop the sweep, the next search skips them
+	s.Equal(0, s.fhir.searchTaskByParamsArgs[1].Offset)
+	s.Equal(2, s.fhir.searchTaskByParamsArgs[2].Offset)
+
+	// the canceled tasks are saved in the batches of the transaction bundles
+	s.Require().Len(s.fhir.bundles, 2)
+	s.Len(s.fhir.bundles[0].Entry, 2)
+	s.Len(s.fhir.bundles[1].Entry, 1)
+	s.Equal(fhirModel.BundleTypeTransaction, s.fhir.bundles[0].Type)
+	s.Equal("Task/expired", s.fhir.bundles[0].Entry[0].Request.URL)
+	s.Equal(http.MethodPut, s.fhir.bundles[0].Entry[0].Request.Method)
+
+	for _, t := range s.fhir.pagedTasks {
+		switch t.ID {
+		case "expired", "otp-expired", "expired-2":
+			s.Equal(fhirModel.TaskStatusCanceled, t.Status)
+			s.Equal(entity.TaskBusinessStatusExpired, t.BusinessStatus.Text)
+		default:
+			s.Equal(fhirModel.TaskStatusInProgress, t.Status)
+		}
+	}
+}
+
+func (s *useCaseTestSuite) TestResendOTPRateLimited() {
+	ctx := context.WithValue(context.Background(), entity.HeaderConsumerID, "consumer-1") //nolint:staticcheck
+	taskID := fhirModel.ID("9e293127-8ffc-462c-aea0-d5464794b526")
+	p := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("b488aa02-f181-4b50-bdca-63b74c5ee447"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientResendOTPRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "task_id", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Task/" + taskID.String()}}},
+		},
+	}
+
+	patientParams := new(fhirModel.Parameters)
+	err := json.Unmarshal([]byte(createPatientReqBody), patientParams)
+	s.NoError(err)
+
+	s.fhir.parameters = []*fhirModel.Parameters{patientParams}
+	s.fhir.tasks = []*fhirModel.Task{{
+		DomainResource: fhirModel.DomainResource{
+			Resource: fhirModel.Resource{
+				ID:   taskID,
+				Meta: &fhirModel.Meta{Profile: []string{fhirModel.StructureDefinitionTaskPatientCreate}},
+			},
+		},
+		Status:     fhirModel.TaskStatusInProgress,
+		AuthoredOn: (*fhirModel.DateTime)(converto.TimePointer(time.Now().UTC().Add(-2 * entity.OTPResendCooldown))),
+		Input: []*fhirModel.TaskInput{
+			{
+				ValueX: fhirModel.ValueX{
+					ValueReference: &fhirModel.Reference{Reference: "Parameters/" + patientParams.ID.String()},
+				},
+			},
+		},
+	}}
+
+	limiter := &createPatientTestRateLimiter{retryAfter: time.Minute}
+	limits := &entity.OTPRateLimits{
+		Value:    entity.RateLimit{Count: 5, Window: time.Hour},
+		Process:  entity.RateLimit{Count: 3, Window: 15 * time.Minute},
+		Consumer: entity.RateLimit{Count: 100, Window: time.Minute},
+	}
+
+	s.uc.WithOTPRateLimiter(limiter, limits)
+
+	_, err = s.uc.ResendOTP(ctx, p)
+	s.Require().Error(err)
+	s.Equal(http.StatusTooManyRequests, cerror.ErrKind(err).HTTPCode())
+
+	cErr, ok := err.(*cerror.CError)
+	s.Require().True(ok)
+	s.Equal(&entity.RateLimitExceeded{RetryAfter: time.Minute}, cErr.Payload())
+
+	// the otp is not generated and the sms is not sent over the limit
+	s.Empty(s.otp.otps)
+	s.Empty(s.sms.messages)
+	s.Require().Len(limiter.keys, 1)
+	s.Equal([]*entity.RateLimitKey{
+		{Key: "phone:+380673212121", Limit: limits.Value},
+		{Key: "process:" + taskID.String(), Limit: limits.Process},
+		{Key: "consumer:consumer-1", Limit: limits.Consumer},
+	}, limiter.keys[0])
+
+	limiter.retryAfter = 0
+
+	_, err = s.uc.ResendOTP(ctx, p)
+	s.NoError(err)
+	s.Len(s.sms.messages, 1)
+}
+
+// the default process limit counts the first otp of the request together with all its resends
+func (s *useCaseTestSuite) TestCreatePatientResendOTPDefaultRateLimits() {
+	ctx := context.WithValue(context.Background(), entity.HeaderConsumerID, "consumer-1") //nolint:staticcheck
+
+	cfg := new(ratelimit.Config)
+	s.Require().NoError(env.ParseCfg(cfg))
+	s.uc.WithOTPRateLimiter(ratelimit.NewMemoryLimiter(), cfg.Limits())
+
+	patientParams := new(fhirModel.Parameters)
+	s.Require().NoError(json.Unmarshal([]byte(createPatientReqBody), patientParams))
+
+	t, err := s.uc.CreatePatient(ctx, patientParams)
+	s.Require().NoError(err)
+
+	s.fhir.parameters = []*fhirModel.Parameters{patientParams}
+	s.fhir.tasks = []*fhirModel.Task{t}
+
+	p := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("b488aa02-f181-4b50-bdca-63b74c5ee447"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientResendOTPRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "task_id", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Task/" + t.ID.String()}}},
+		},
+	}
+
+	// the cooldown since the last sent otp is passed without waiting
+	passCooldown := func() {
+		t.AuthoredOn = (*fhirModel.DateTime)(converto.TimePointer(t.AuthoredOn.Time().Add(-entity.OTPResendCooldown)))
+
+		for _, e := range t.Extension {
+			if e.URL == entity.StructureDefinitionTaskOTPResent {
+				e.ValueDateTime = (*fhirModel.DateTime)(converto.TimePointer(
+					e.ValueDateTime.Time().Add(-entity.OTPResendCooldown)))
+			}
+		}
+	}
+
+	for i := 0; i < entity.OTPResendMaxCount; i++ {
+		passCooldown()
+
+		_, err = s.uc.ResendOTP(ctx, p)
+		s.Require().NoError(err, "resend %d", i+1)
+	}
+
+	s.Len(s.sms.messages, 1+entity.OTPResendMaxCount)
+}
+
+func (s *useCaseTestSuite) TestMatchPatients() {
+	ctx := context.Background()
+	birthDate := fhirModel.Date(time.Date(1990, 5, 12, 0, 0, 0, 0, time.UTC))
+	swappedBirthDate := fhirModel.Date(time.Date(1990, 12, 5, 0, 0, 0, 0, time.UTC))
+
+	patient := func(id, gender string, bd *fhirModel.Date, names ...*fhirModel.HumanName) *fhirModel.Patient {
+		return &fhirModel.Patient{
+			DomainResource: fhirModel.DomainResource{Resource: fhirModel.Resource{ID: fhirModel.ID(id)}},
+			Name:           names,
+			Gender:         gender,
+			BirthDate:      bd,
+		}
+	}
+
+	identifier := func(code, value string) *fhirModel.Identifier {
+		return &fhirModel.Identifier{
+			Type:  &fhirModel.CodeableConcept{Codings: []*fhirModel.Coding{{Code: code}}},
+			Value: value,
+		}
+	}
+
+	query := patient("", "male", &birthDate,
+		&fhirModel.HumanName{Given: []string{"أحمد"}, Family: "محمد"},
+		&fhirModel.HumanName{Given: []string{"Ahmad"}, Family: "Mohammed"})
+	query.Telecom = []*fhirModel.ContactPoint{{System: fhirModel.TelecomSystemPhone, Value: "+966500000001"}}
+	query.Identifier = []*fhirModel.Identifier{identifier("PRC", "2000000001")}
+
+	// the same person registered with the passport
+	samePerson := patient("1", "male", &birthDate,
+		&fhirModel.HumanName{Given: []string{"احمد"}, Family: "محمد"},
+		&fhirModel.HumanName{Given: []string{"Ahmed"}, Family: "Mohammed"})
+	samePerson.Telecom = []*fhirModel.ContactPoint{{System: fhirModel.TelecomSystemPhone, Value: "0500000001"}}
+	samePerson.Identifier = []*fhirModel.Identifier{identifier("PPN", "A1234567")}
+
+	// the same name and demographics with the other iqama number are the other person
+	otherPerson := patient("4", "male", &birthDate, &fhirModel.HumanName{Given: []string{"Ahmad"}, Family: "Mohammed"})
+	otherPerson.Identifier = []*fhirModel.Identifier{identifier("PRC", "2000000002")}
+
+	s.fhir.duplPatients = []*fhirModel.Patient{
+		patient("2", "male", &swappedBirthDate, &fhirModel.HumanName{Given: []string{"Ahmad"}, Family: "Mohamed"}),
+		samePerson,
+		patient("3", "female", &birthDate, &fhirModel.HumanName{Given: []string{"Sara"}, Family: "Ali"}),
+		otherPerson,
+	}
+
+	params := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientMatchRequest}},
+		},
+	}
+
+	_, err := s.uc.MatchPatients(ctx, params)
+	s.Error(err)
+	s.Contains(err.Error(), "missing resource parameter")
+
+	params.Parameter = []*fhirModel.ParametersParameter{{Name: "resource", Resource: query}}
+
+	b, err := s.uc.MatchPatients(ctx, params)
+	s.Require().NoError(err)
+
+	// the candidates are searched by the names with the birth date and the year, the identifier and the phone
+	s.Equal([]*entity.SearchPatientParams{
+		{Family: "محمد", BirthDate: "1990-05-12", Count: entity.PatientMatchCandidatesCount},
+		{Family: "محمد", Given: "أحمد", BirthDate: "1990", Count: entity.PatientMatchCandidatesCount},
+		{Family: "Mohammed", BirthDate: "1990-05-12", Count: entity.PatientMatchCandidatesCount},
+		{Family: "Mohammed", Given: "Ahmad", BirthDate: "1990", Count: entity.PatientMatchCandidatesCount},
+		{
+			Identifier: &entity.SearchPatientByIdentifierParams{Value: "2000000001"},
+			Count:      entity.PatientMatchCandidatesCount,
+		},
+		{
+			Phone: &entity.SearchPatientByPhoneParams{Phone: "+966500000001"},
+			Count: entity.PatientMatchCandidatesCount,
+		},
+	}, s.fhir.searchPatientByParamsArgs)
+
+	s.Equal(fhirModel.BundleTypeSearchset, b.Type)
+	s.Require().Len(b.Entry, 2)
+	s.Equal(2, *b.Total)
+
+	s.Equal("Patient/1", b.Entry[0].FullURL)
+	s.Equal(entity.SearchEntryModeMatch, b.Entry[0].Search.Mode)
+	s.Equal(1.0, *b.Entry[0].Search.Score)
+	s.Equal(entity.StructureDefinitionMatchGrade, b.Entry[0].Search.Extension[0].URL)
+	s.Equal(entity.MatchGradeCertain, *b.Entry[0].Search.Extension[0].ValueCode)
+
+	s.Equal("Patient/2", b.Entry[1].FullURL)
+	s.Equal(entity.MatchGradeProbable, *b.Entry[1].Search.Extension[0].ValueCode)
+	s.Less(*b.Entry[1].Search.Score, *b.Entry[0].Search.Score)
+
+	params.Parameter = append(params.Parameter, &fhirModel.ParametersParameter{
+		Name:   "onlyCertainMatches",
+		ValueX: fhirModel.ValueX{ValueBoolean: converto.BoolPointer(true)},
+	})
+
+	b, err = s.uc.MatchPatients(ctx, params)
+	s.Require().NoError(err)
+	s.Require().Len(b.Entry, 1)
+	s.Equal("Patient/1", b.Entry[0].FullURL)
+
+	// the stricter policy grades the same person as probable only
+	s.uc.WithMatchPolicy(&entity.PatientMatchPolicy{
+		Weights:  entity.DefaultPatientMatchPolicy().Weights,
+		Certain:  1.01,
+		Probable: 0.9,
+		Possible: 0.8,
+	})
+	defer s.uc.WithMatchPolicy(entity.DefaultPatientMatchPolicy())
+
+	b, err = s.uc.MatchPatients(ctx, params)
+	s.Require().NoError(err)
+	s.Empty(b.Entry)
+}
+
+func (s *useCaseTestSuite) TestCreatePatientPossibleDuplicates() {
+	birthDate := fhirModel.Date(time.Date(2020, 12, 25, 0, 0, 0, 0, time.UTC))
+	s.fhir.candidatePatients = []*fhirModel.Patient{{
+		DomainResource: fhirModel.DomainResource{Resource: fhirModel.Resource{ID: "registered"}},
+		Name:           []*fhirModel.HumanName{{Given: []string{"Peter", "James"}, Family: "Chalmers"}},
+		Gender:         "male",
+		BirthDate:      &birthDate,
+		Telecom:        []*fhirModel.ContactPoint{{System: fhirModel.TelecomSystemPhone, Value: "0673212121"}},
+	}}
+
+	// the near duplicate passes silently when the policy is off
+	s.uc.WithPossibleDuplicatePolicy(entity.PossibleDuplicatePolicyOff)
+
+	params := new(fhirModel.Parameters)
+	s.Require().NoError(json.Unmarshal([]byte(createPatientReqBody), params))
+
+	task, err := s.uc.CreatePatient(context.Background(), params)
+	s.Require().NoError(err)
+	s.Empty(task.Output)
+
+	// output
+	s.uc.WithPossibleDuplicatePolicy(entity.PossibleDuplicatePolicyOutput)
+
+	params = new(fhirModel.Parameters)
+	s.Require().NoError(json.Unmarshal([]byte(createPatientReqBody), params))
+
+	s.fhir.searchPatientByParamsArgs = nil
+
+	task, err = s.uc.CreatePatient(context.Background(), params)
+	s.Require().NoError(err)
+	s.Equal(fhirModel.TaskStatusInProgress, task.Status)
+
+	// the identifiers are checked for the exact duplicates, the near ones are searched by the name
+	nameSearches := 0
+
+	for _, args := range s.fhir.searchPatientByParamsArgs {
+		s.Nil(args.Phone)
+
+		if args.Identifier == nil {
+			s.Equal("Chalmers", args.Family)
+			nameSearches++
+		}
+	}
+
+	s.Equal(2, nameSearches)
+
+	s.Require().Len(task.Output, 2)
+	s.Equal(entity.TaskReasonPossibleDuplicate, task.Output[0].Type.Codings[0].Code)
+	s.Equal("Patient/registered", task.Output[0].ValueReference.Reference)
+
+	s.Require().Len(task.Contained, 1)
+	o := task.Contained[0].(*fhirModel.OperationOutcome)
+	s.Equal("#"+o.ID.String(), task.Output[1].ValueReference.Reference)
+	s.Require().Len(o.Issue, 1)
+	s.Equal(entity.OutcomeIssueSeverityWarning, o.Issue[0].Severity)
+	s.Equal(entity.OutcomeIssueCodeDuplicate, o.Issue[0].Code)
+	s.Contains(o.Issue[0].Diagnostics, "Patient/registered")
+	s.Len(s.sms.messages, 2)
+
+	// hold
+	s.uc.WithPossibleDuplicatePolicy(entity.PossibleDuplicatePolicyHold)
+
+	params = new(fhirModel.Parameters)
+	s.Require().NoError(json.Unmarshal([]byte(createPatientReqBody), params))
+
+	task, err = s.uc.CreatePatient(context.Background(), params)
+	s.Require().NoError(err)
+	s.Equal(fhirModel.TaskStatusInProgress, task.Status)
+	s.Equal(entity.TaskReasonPossibleDuplicate, task.StatusReason.Codings[0].Code)
+	s.Len(task.Output, 2)
+
+	// the request is held for the review when its otp is confirmed, so the otp is sent
+	s.Len(s.sms.messages, 3)
+
+	// the validation warns about the possible duplicate too
+	params = new(fhirModel.Parameters)
+	s.Require().NoError(json.Unmarshal([]byte(createPatientReqBody), params))
+
+	o, err = s.uc.ValidateCreatePatient(context.Background(), params)
+	s.Require().NoError(err)
+	s.Require().Len(o.Issue, 1)
+	s.Equal(entity.OutcomeIssueCodeDuplicate, o.Issue[0].Code)
+}
+
+func (s *useCaseTestSuite) TestReviewTasks() {
+	reviewerCtx := context.WithValue(context.Background(), entity.HeaderConsumerID, "registrar-1") //nolint:staticcheck
+	s.uc.WithFraudPolicy(entity.FraudPolicyHold).WithReviewers([]string{"registrar-1"})
+
+	// the registry mismatch marks the created request for the review, its otp is still sent
+	s.uc.WithDemographicsPolicy(entity.DemographicsPolicyHold)
+	s.extReg.result = &entity.ExtDocRegistrySearchResult{IsValid: true, BirthDate: "2019-01-01"}
+
+	params := new(fhirModel.Parameters)
+	s.Require().NoError(json.Unmarshal([]byte(createPatientReqBody), params))
+
+	task, err := s.uc.CreatePatient(context.Background(), params)
+	s.Require().NoError(err)
+	s.Equal(fhirModel.TaskStatusInProgress, task.Status)
+	s.Equal(entity.TaskReasonRegistryMismatch, task.StatusReason.Codings[0].Code)
+	s.Equal(entity.TaskBusinessStatusOTPCodeSent, task.BusinessStatus.Text)
+	s.Len(task.Contained, 1)
+	s.Len(s.sms.messages, 1)
+
+	p := new(fhirModel.Parameters)
+	s.Require().NoError(json.Unmarshal([]byte(confirmCreatePatientReqBody), p))
+
+	patientParams := new(fhirModel.Parameters)
+	s.Require().NoError(json.Unmarshal([]byte(createPatientReqBody), patientParams))
+
+	taskID := fhirModel.ID("9e293127-8ffc-462c-aea0-d5464794b526")
+	pendingTask := func(statusReason *fhirModel.CodeableConcept) *fhirModel.Task {
+		return &fhirModel.Task{
+			DomainResource: fhirModel.DomainResource{
+				Resource: fhirModel.Resource{
+					ID:   taskID,
+					Meta: &fhirModel.Meta{Profile: []string{fhirModel.StructureDefinitionTaskPatientCreate}},
+				},
+			},
+			Status:       fhirModel.TaskStatusInProgress,
+			StatusReason: statusReason,
+			Input: []*fhirModel.TaskInput{{
+				ValueX: fhirModel.ValueX{
+					ValueReference: &fhirModel.Reference{Reference: "Parameters/" + patientParams.ID.String()},
+				},
+			}},
+		}
+	}
+
+	// the marked request is held once the patient confirmed the otp
+	s.fhir.parameters = []*fhirModel.Parameters{patientParams}
+	s.fhir.tasks = []*fhirModel.Task{pendingTask(task.StatusReason)}
+	s.otp.otps = map[string]*entity.OTP{taskID.String(): {Code: "2655", Value: "+380673212121"}}
+
+	task, err = s.uc.ConfirmCreatePatient(context.Background(), p)
+	s.Require().NoError(err)
+	s.Equal(fhirModel.TaskStatusOnHold, task.Status)
+	s.Equal(entity.TaskReasonRegistryMismatch, task.StatusReason.Codings[0].Code)
+	s.Equal(entity.TaskBusinessStatusRegistryMismatchReview, task.BusinessStatus.Text)
+	s.Len(s.fhir.bundles[len(s.fhir.bundles)-1].Entry, 2)
+
+	// the fraud hit holds the confirmed request
+	s.fhir.tasks = []*fhirModel.Task{pendingTask(nil)}
+	s.otp.otps = map[string]*entity.OTP{taskID.String(): {Code: "2655", Value: "+380673212121"}}
+
+	for i := 0; i < entity.MaxPatientsWithSamePhone; i++ {
+		s.fhir.phonePatients = append(s.fhir.phonePatients, &fhirModel.Patient{})
+	}
+
+	task, err = s.uc.ConfirmCreatePatient(context.Background(), p)
+	s.Require().NoError(err)
+	s.Equal(fhirModel.TaskStatusOnHold, task.Status)
+	s.Equal(entity.TaskReasonFraudSuspected, task.StatusReason.Codings[0].Code)
+	s.Equal(entity.TaskBusinessStatusFraudReview, task.BusinessStatus.Text)
+	s.Len(s.fhir.bundles[len(s.fhir.bundles)-1].Entry, 2)
+
+	// the queue is available to the reviewers only
+	_, err = s.uc.SearchReviewTasks(context.Background(), &entity.SearchReviewTaskParams{})
+	s.Require().Error(err)
+	s.Equal(cerror.KindFromHTTPCode(http.StatusForbidden), cerror.ErrKind(err))
+
+	_, err = s.uc.SearchReviewTasks(reviewerCtx, &entity.SearchReviewTaskParams{Reason: "unknown"})
+	s.Require().Error(err)
+	s.Contains(err.Error(), "unsupported value")
+
+	b, err := s.uc.SearchReviewTasks(reviewerCtx, &entity.SearchReviewTaskParams{Reason: entity.TaskReasonFraudSuspected})
+	s.Require().NoError(err)
+	s.Require().Len(b.Entry, 1)
+	s.Equal("Task/"+taskID.String(), b.Entry[0].FullURL)
+
+	searchArgs := s.fhir.searchTaskByParamsArgs[len(s.fhir.searchTaskByParamsArgs)-1]
+	s.Equal(entity.CodeSystemTaskReason+"|"+entity.TaskReasonFraudSuspected, searchArgs.StatusReason)
+	s.Equal(fhirModel.TaskStatusOnHold, searchArgs.Status)
+	s.Equal(entity.ReviewTaskProfiles(), searchArgs.Profiles)
+	s.Equal(entity.ReviewTaskDefaultCount+1, searchArgs.Count)
+	s.Require().Len(b.Link, 1)
+	s.Equal(entity.BundleLinkSelf, b.Link[0].Relation)
+
+	// approve
+	reviewParams := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("2a4b3c5d-0000-4000-8000-000000000001"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionTaskReviewRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "comment", ValueX: fhirModel.ValueX{ValueString: converto.StringPointer("checked in person")}},
+		},
+	}
+
+	_, err = s.uc.ApproveReviewTask(context.Background(), taskID, reviewParams)
+	s.Require().Error(err)
+	s.Equal(cerror.KindFromHTTPCode(http.StatusForbidden), cerror.ErrKind(err))
+
+	task, err = s.uc.ApproveReviewTask(reviewerCtx, taskID, reviewParams)
+	s.Require().NoError(err)
+	s.Equal(fhirModel.TaskStatusCompleted, task.Status)
+	s.Equal(entity.TaskBusinessStatusPatientCreated, task.BusinessStatus.Text)
+	s.Nil(task.StatusReason)
+	s.Equal("Patient/"+patientParams.ID.String(), task.For.Reference)
+
+	bundle := s.fhir.bundles[len(s.fhir.bundles)-1]
+	s.Require().Len(bundle.Entry, 3)
+	s.Equal(reviewParams, bundle.Entry[0].Resource)
+	s.Equal(fhirModel.ResourcePatient, bundle.Entry[1].Request.URL)
+
+	review := task.Extension[len(task.Extension)-1]
+	s.Equal(entity.StructureDefinitionTaskReview, review.URL)
+	s.Equal("registrar-1", converto.StringValue(review.Extension[0].ValueString))
+	s.Equal(entity.ReviewDecisionApproved, converto.StringValue(review.Extension[1].ValueCode))
+	s.Equal(entity.TaskReasonFraudSuspected, converto.StringValue(review.Extension[2].ValueCode))
+	s.Equal("checked in person", converto.StringValue(review.Extension[4].ValueString))
+
+	_, err = s.uc.ApproveReviewTask(reviewerCtx, taskID, reviewParams)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "such patient request is not held for review")
+
+	// reject
+	heldID := fhirModel.ID("7f0e1d2c-0000-4000-8000-000000000002")
+	s.fhir.tasks = append(s.fhir.tasks, &fhirModel.Task{
+		DomainResource: fhirModel.DomainResource{
+			Resource: fhirModel.Resource{
+				ID:   heldID,
+				Meta: &fhirModel.Meta{Profile: []string{fhirModel.StructureDefinitionTaskPatientCreate}},
+			},
+		},
+		Status: fhirModel.TaskStatusOnHold,
+		StatusReason: &fhirModel.CodeableConcept{
+			Codings: []*fhirModel.Coding{{System: entity.CodeSystemTaskReason, Code: entity.TaskReasonPossibleDuplicate}},
+		},
+	})
+
+	_, err = s.uc.RejectReviewTask(reviewerCtx, heldID, reviewParams)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "missing reason parameter")
+
+	reviewParams.Parameter = []*fhirModel.ParametersParameter{
+		{Name: "reason", ValueX: fhirModel.ValueX{ValueString: converto.StringPointer("forged document")}},
+	}
+
+	task, err = s.uc.RejectReviewTask(reviewerCtx, heldID, reviewParams)
+	s.Require().NoError(err)
+	s.Equal(fhirModel.TaskStatusRejected, task.Status)
+	s.Equal(entity.TaskBusinessStatusRejectedByReviewer, task.BusinessStatus.Text)
+	s.Equal("forged document", task.StatusReason.Text)
+
+	review = task.Extension[len(task.Extension)-1]
+	s.Equal(entity.ReviewDecisionRejected, converto.StringValue(review.Extension[1].ValueCode))
+	s.Equal(entity.TaskReasonPossibleDuplicate, converto.StringValue(review.Extension[2].ValueCode))
+	s.Equal(fhirModel.TaskStatusRejected,
+		s.fhir.bundles[len(s.fhir.bundles)-1].Entry[1].Resource.(*fhirModel.Task).Status)
+
+	// the approval of the person registered while the request was held rejects it
+	duplID := fhirModel.ID("5c6d7e8f-0000-4000-8000-000000000003")
+	s.fhir.tasks = append(s.fhir.tasks, &fhirModel.Task{
+		DomainResource: fhirModel.DomainResource{
+			Resource: fhirModel.Resource{
+				ID:   duplID,
+				Meta: &fhirModel.Meta{Profile: []string{fhirModel.StructureDefinitionTaskPatientCreate}},
+			},
+		},
+		Status: fhirModel.TaskStatusOnHold,
+		StatusReason: &fhirModel.CodeableConcept{
+			Codings: []*fhirModel.Coding{{System: entity.CodeSystemTaskReason, Code: entity.TaskReasonFraudSuspected}},
+		},
+		Input: []*fhirModel.TaskInput{{
+			ValueX: fhirModel.ValueX{
+				ValueReference: &fhirModel.Reference{Reference: "Parameters/" + patientParams.ID.String()},
+			},
+		}},
+	})
+	s.fhir.duplPatients = []*fhirModel.Patient{{}}
+
+	_, err = s.uc.ApproveReviewTask(reviewerCtx, duplID, reviewParams)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "such person already exists")
+
+	rejected := s.fhir.bundles[len(s.fhir.bundles)-1].Entry[1].Resource.(*fhirModel.Task)
+	s.Equal(duplID, rejected.ID)
+	s.Equal(fhirModel.TaskStatusRejected, rejected.Status)
+
+	reviews := 0
+
+	for _, e := range rejected.Extension {
+		if e.URL == entity.StructureDefinitionTaskReview {
+			reviews++
+
+			s.Equal(entity.ReviewDecisionRejected, converto.StringValue(e.Extension[1].ValueCode))
+			s.Equal("such person already exists", converto.StringValue(e.Extension[4].ValueString))
+		}
+	}
+
+	s.Equal(1, reviews)
+
+	// the queue is paged like the patient search
+	s.fhir.pagedTasks = []*fhirModel.Task{}
+
+	for _, id := range []fhirModel.ID{"held-1", "held-2", "held-3"} {
+		s.fhir.pagedTasks = append(s.fhir.pagedTasks, &fhirModel.Task{
+			DomainResource: fhirModel.DomainResource{
+				Resource: fhirModel.Resource{
+					ID:   id,
+					Meta: &fhirModel.Meta{Profile: []string{fhirModel.StructureDefinitionTaskPatientCreate}},
+				},
+			},
+			Status: fhirModel.TaskStatusOnHold,
+			StatusReason: &fhirModel.CodeableConcept{
+				Codings: []*fhirModel.Coding{{System: entity.CodeSystemTaskReason, Code: entity.TaskReasonFraudSuspected}},
+			},
+		})
+	}
+
+	_, err = s.uc.SearchReviewTasks(reviewerCtx, &entity.SearchReviewTaskParams{Offset: -1})
+	s.Require().Error(err)
+	s.Contains(err.Error(), "should not be negative")
+
+	b, err = s.uc.SearchReviewTasks(reviewerCtx, &entity.SearchReviewTaskParams{Count: 2})
+	s.Require().NoError(err)
+	s.Require().Len(b.Entry, 2)
+	s.Equal("Task/held-2", b.Entry[1].FullURL)
+	s.Require().Len(b.Link, 2)
+	s.Equal(entity.BundleLinkNext, b.Link[1].Relation)
+	s.Equal("Task/$review-queue?_count=2&_offset=2", b.Link[1].URL)
+
+	b, err = s.uc.SearchReviewTasks(reviewerCtx, &entity.SearchReviewTaskParams{Count: 2, Offset: 2})
+	s.Require().NoError(err)
+	s.Require().Len(b.Entry, 1)
+	s.Equal("Task/held-3", b.Entry[0].FullURL)
+	s.Require().Len(b.Link, 2)
+	s.Equal(entity.BundleLinkPrevious, b.Link[1].Relation)
+	s.Equal("Task/$review-queue?_count=2&_offset=0", b.Link[1].URL)
+}
+
+func (s *useCaseTestSuite) TestReviewUpdatePatientPhone() {
+	ctx := context.Background()
+	reviewerCtx := context.WithValue(ctx, entity.HeaderConsumerID, "registrar-1") //nolint:staticcheck
+	s.uc.WithFraudPolicy(entity.FraudPolicyHold).WithReviewers([]string{"registrar-1"})
+
+	patient := preparePhonePatient(s)
+
+	for i := 0; i < entity.MaxPatientsWithSamePhone; i++ {
+		s.fhir.phonePatients = append(s.fhir.phonePatients, &fhirModel.Patient{})
+	}
+
+	// the fraud hit marks the phone change for the review, its otp is still sent
+	phoneParams := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("2f0e4a8b-6c1d-4d8e-9b7a-3c5d6e7f8a9b"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientUpdatePhoneRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "phone", ValueX: fhirModel.ValueX{ValueString: converto.StringPointer("+380671112233")}},
+		},
+	}
+
+	task, err := s.uc.UpdatePatientPhone(ctx, patient.ID, phoneParams)
+	s.Require().NoError(err)
+	s.Equal(fhirModel.TaskStatusInProgress, task.Status)
+	s.Equal(entity.TaskReasonFraudSuspected, task.StatusReason.Codings[0].Code)
+	s.Equal("+380671112233", s.otp.otps[task.ID.String()].Value)
+
+	// the marked phone change is held once the patient confirmed the otp
+	s.fhir.parameters = []*fhirModel.Parameters{phoneParams}
+	s.fhir.tasks = []*fhirModel.Task{task}
+
+	p := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("b488aa02-f181-4b50-bdca-63b74c5ee447"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientConfirmUpdatePhoneRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "otp", ValueX: fhirModel.ValueX{ValueString: converto.StringPointer("1234")}},
+			{Name: "task_id", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Task/" + task.ID.String()}}},
+		},
+	}
+	s.otp.otps = map[string]*entity.OTP{task.ID.String(): {Code: "1234", Value: "+380671112233"}}
+
+	task, err = s.uc.ConfirmUpdatePatientPhone(ctx, patient.ID, p)
+	s.Require().NoError(err)
+	s.Equal(fhirModel.TaskStatusOnHold, task.Status)
+	s.Equal(entity.TaskBusinessStatusFraudReview, task.BusinessStatus.Text)
+	s.Len(s.fhir.bundles[len(s.fhir.bundles)-1].Entry, 2)
+	s.Equal("+380673212121", patient.Telecom[0].Value)
+
+	b, err := s.uc.SearchReviewTasks(reviewerCtx, &entity.SearchReviewTaskParams{})
+	s.Require().NoError(err)
+	s.Require().Len(b.Entry, 1)
+	s.Equal("Task/"+task.ID.String(), b.Entry[0].FullURL)
+
+	// the approval sets the phone without a new otp
+	reviewParams := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("2a4b3c5d-0000-4000-8000-000000000001"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionTaskReviewRequest}},
+		},
+	}
+
+	task, err = s.uc.ApproveReviewTask(reviewerCtx, task.ID, reviewParams)
+	s.Require().NoError(err)
+	s.Equal(fhirModel.TaskStatusCompleted, task.Status)
+	s.Equal(entity.TaskBusinessStatusPatientPhoneUpdated, task.BusinessStatus.Text)
+	s.Nil(task.StatusReason)
+
+	bundle := s.fhir.bundles[len(s.fhir.bundles)-1]
+	s.Require().Len(bundle.Entry, 3)
+
+	bundlePatient, ok := bundle.Entry[1].Resource.(*fhirModel.Patient)
+	s.Require().True(ok)
+	s.Equal("+380671112233", bundlePatient.Telecom[0].Value)
+
+	review := task.Extension[len(task.Extension)-1]
+	s.Equal(entity.ReviewDecisionApproved, converto.StringValue(review.Extension[1].ValueCode))
+	s.Equal(entity.TaskReasonFraudSuspected, converto.StringValue(review.Extension[2].ValueCode))
+}
+
+func (s *useCaseTestSuite) TestMergePatients() {
+	ctx := s.administratorCtx()
+	source, target := prepareMergedPatients(s)
+
+	p := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("5c9d1e2f-0000-4000-8000-000000000003"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientMergeRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "source-patient", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Patient/source"}}},
+		},
+	}
+
+	// the patients are merged by the administrators only
+	_, err := s.uc.MergePatients(context.Background(), p)
+	s.Require().Error(err)
+	s.Equal(cerror.KindFromHTTPCode(http.StatusForbidden), cerror.ErrKind(err))
+
+	_, err = s.uc.MergePatients(ctx, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "missing target-patient parameter")
+
+	p.Parameter = append(p.Parameter, &fhirModel.ParametersParameter{
+		Name: "target-patient", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Patient/source"}},
+	})
+
+	_, err = s.uc.MergePatients(ctx, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "source and target patients are the same")
+
+	p.Parameter[1].ValueReference.Reference = "Patient/unknown"
+
+	_, err = s.uc.MergePatients(ctx, p)
+	s.Require().Error(err)
+	s.Equal(cerror.KindNotExist, cerror.ErrKind(err))
+	s.Contains(err.Error(), "target patient does not exist")
+
+	// union
+	p.Parameter[1].ValueReference.Reference = "Patient/target"
+
+	task, err := s.uc.MergePatients(ctx, p)
+	s.Require().NoError(err)
+	s.Equal(fhirModel.TaskStatusCompleted, task.Status)
+	s.Equal(entity.TaskBusinessStatusPatientsMerged, task.BusinessStatus.Text)
+	s.Equal("Patient/target", task.For.Reference)
+	s.Equal("Patient/source", task.Output[0].ValueReference.Reference)
+	s.Equal("registrar-1", taskAdministrator(task))
+
+	b := s.fhir.bundles[len(s.fhir.bundles)-1]
+	s.Require().Len(b.Entry, 4)
+	s.Equal(fhirModel.BundleTypeTransaction, b.Type)
+	s.Equal("Patient/source", b.Entry[2].Request.URL)
+	s.Equal("Patient/target", b.Entry[3].Request.URL)
+
+	s.False(converto.BoolValue(source.Active))
+	s.Equal([]*fhirModel.PatientLink{{
+		Other: &fhirModel.Reference{Reference: "Patient/target"}, Type: entity.PatientLinkReplacedBy,
+	}}, source.Link)
+	s.Equal([]*fhirModel.PatientLink{{
+		Other: &fhirModel.Reference{Reference: "Patient/source"}, Type: entity.PatientLinkReplaces,
+	}}, target.Link)
+
+	// the target national id is kept and the missing passport is copied
+	s.Require().Len(target.Identifier, 2)
+	s.Equal("1000000001", target.Identifier[0].Value)
+	s.Equal("P100", target.Identifier[1].Value)
+	s.Require().Len(target.Telecom, 2)
+	s.Equal("+966500000002", target.Telecom[1].Value)
+
+	// the merged source is not merged again
+	_, err = s.uc.MergePatients(ctx, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "patient has inactive status")
+
+	// source
+	source, target = prepareMergedPatients(s)
+	s.uc.WithMergeSurvivorship(&entity.MergeSurvivorship{
+		Identifier: entity.SurvivorshipSource,
+		Telecom:    entity.SurvivorshipSource,
+	})
+
+	defer s.uc.WithMergeSurvivorship(&entity.MergeSurvivorship{
+		Identifier: entity.SurvivorshipUnion,
+		Telecom:    entity.SurvivorshipUnion,
+	})
+
+	_, err = s.uc.MergePatients(ctx, p)
+	s.Require().NoError(err)
+	s.Require().Len(target.Identifier, 2)
+	s.Equal("1000000002", target.Identifier[0].Value)
+	s.Require().Len(target.Telecom, 1)
+	s.Equal("+966500000002", target.Telecom[0].Value)
+
+	// target
+	source, target = prepareMergedPatients(s)
+	s.uc.WithMergeSurvivorship(&entity.MergeSurvivorship{
+		Identifier: entity.SurvivorshipTarget,
+		Telecom:    entity.SurvivorshipTarget,
+	})
+
+	_, err = s.uc.MergePatients(ctx, p)
+	s.Require().NoError(err)
+	s.Len(target.Identifier, 1)
+	s.Len(target.Telecom, 1)
+	s.Len(source.Identifier, 2)
+}
+
+func (s *useCaseTestSuite) TestUnmergePatients() {
+	ctx := s.administratorCtx()
+	source, target := prepareMergedPatients(s)
+
+	mp := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("5c9d1e2f-0000-4000-8000-000000000004"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientMergeRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "source-patient", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Patient/source"}}},
+			{Name: "target-patient", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Patient/target"}}},
+		},
+	}
+
+	mergeTask, err := s.uc.MergePatients(ctx, mp)
+	s.Require().NoError(err)
+	s.Require().Len(mergeTask.Contained, 3)
+
+	s.fhir.tasks = []*fhirModel.Task{mergeTask}
+
+	p := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("5c9d1e2f-0000-4000-8000-000000000005"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientUnmergeRequest}},
+		},
+	}
+
+	_, err = s.uc.UnmergePatients(ctx, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "missing merge-task parameter")
+
+	p.Parameter = []*fhirModel.ParametersParameter{
+		{Name: "merge-task", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Task/unknown"}}},
+	}
+
+	_, err = s.uc.UnmergePatients(ctx, p)
+	s.Require().Error(err)
+	s.Equal(cerror.KindNotExist, cerror.ErrKind(err))
+	s.Contains(err.Error(), "such merge does not exist")
+
+	// the telecom added to the target after the merge would be lost
+	p.Parameter[0].ValueReference.Reference = fmt.Sprintf("Task/%s", mergeTask.ID)
+	target.Telecom = append(target.Telecom, &fhirModel.ContactPoint{System: "phone", Value: "+966500000003"})
+
+	_, err = s.uc.UnmergePatients(ctx, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "target patient changed since the merge")
+
+	target.Telecom = target.Telecom[:len(target.Telecom)-1]
+
+	// the telecom removed from the target after the merge would be put back
+	mergedTelecom := target.Telecom
+	target.Telecom = target.Telecom[:1]
+
+	_, err = s.uc.UnmergePatients(ctx, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "target patient changed since the merge")
+
+	target.Telecom = mergedTelecom
+	source.Active = converto.BoolPointer(true)
+
+	_, err = s.uc.UnmergePatients(ctx, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "source patient changed since the merge")
+
+	source.Active = converto.BoolPointer(false)
+
+	// another patient got the source identifier while it was merged
+	s.fhir.duplPatients = []*fhirModel.Patient{
+		target, {DomainResource: fhirModel.DomainResource{Resource: fhirModel.Resource{ID: "other"}}},
+	}
+
+	_, err = s.uc.UnmergePatients(ctx, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "such person already exists")
+
+	// the target still has the copied source identifiers and is not a duplicate
+	s.fhir.duplPatients = []*fhirModel.Patient{target}
+
+	task, err := s.uc.UnmergePatients(ctx, p)
+	s.Require().NoError(err)
+	s.Equal(fhirModel.TaskStatusCompleted, task.Status)
+	s.Equal(entity.TaskBusinessStatusPatientsUnmerged, task.BusinessStatus.Text)
+	s.Equal("registrar-1", taskAdministrator(task))
+	s.Equal("Patient/source", task.For.Reference)
+	s.Equal("Patient/target", task.Output[0].ValueReference.Reference)
+	s.Equal(fmt.Sprintf("Task/%s", mergeTask.ID), task.Input[1].ValueReference.Reference)
+
+	b := s.fhir.bundles[len(s.fhir.bundles)-1]
+	s.Require().Len(b.Entry, 5)
+	s.Equal("Patient/source", b.Entry[2].Request.URL)
+	s.Equal("Patient/target", b.Entry[3].Request.URL)
+	s.Equal(fmt.Sprintf("Task/%s", mergeTask.ID), b.Entry[4].Request.URL)
+	s.Equal(entity.TaskBusinessStatusPatientsUnmerged, mergeTask.BusinessStatus.Text)
+
+	s.True(converto.BoolValue(source.Active))
+	s.Empty(source.Link)
+	s.Len(source.Identifier, 2)
+	s.Empty(target.Link)
+	s.Require().Len(target.Identifier, 1)
+	s.Equal("1000000001", target.Identifier[0].Value)
+	s.Require().Len(target.Telecom, 1)
+	s.Equal("+966500000001", target.Telecom[0].Value)
+
+	_, err = s.uc.UnmergePatients(ctx, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "such merge is already reversed")
+}
+
+func (s *useCaseTestSuite) TestLinkPatients() {
+	ctx := s.administratorCtx()
+	source, target := prepareMergedPatients(s)
+
+	p := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("5c9d1e2f-0000-4000-8000-000000000006"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientLinkRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "source-patient", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Patient/source"}}},
+			{Name: "target-patient", ValueX: fhirModel.ValueX{ValueReference: &fhirModel.Reference{Reference: "Patient/target"}}},
+		},
+	}
+
+	_, err := s.uc.LinkPatients(ctx, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "missing type parameter")
+
+	p.Parameter = append(p.Parameter, &fhirModel.ParametersParameter{
+		Name: "type", ValueX: fhirModel.ValueX{ValueCode: converto.StringPointer(entity.PatientLinkReplaces)},
+	})
+
+	_, err = s.uc.LinkPatients(ctx, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "unsupported type parameter value")
+
+	p.Parameter[2].ValueCode = converto.StringPointer(entity.PatientLinkSeeAlso)
+	target.Active = converto.BoolPointer(false)
+
+	_, err = s.uc.LinkPatients(ctx, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "patient has inactive status")
+
+	target.Active = converto.BoolPointer(true)
+
+	task, err := s.uc.LinkPatients(ctx, p)
+	s.Require().NoError(err)
+	s.Equal(entity.TaskBusinessStatusPatientsLinked, task.BusinessStatus.Text)
+	s.Equal("Patient/source", task.For.Reference)
+	s.Equal("registrar-1", taskAdministrator(task))
+	s.Equal("Patient/target", task.Output[0].ValueReference.Reference)
+
+	b := s.fhir.bundles[len(s.fhir.bundles)-1]
+	s.Require().Len(b.Entry, 4)
+	s.Equal("Patient/source", b.Entry[2].Request.URL)
+	s.Equal("Patient/target", b.Entry[3].Request.URL)
+
+	s.Equal([]*fhirModel.PatientLink{{
+		Other: &fhirModel.Reference{Reference: "Patient/target"}, Type: entity.PatientLinkSeeAlso,
+	}}, source.Link)
+	s.Equal([]*fhirModel.PatientLink{{
+		Other: &fhirModel.Reference{Reference: "Patient/source"}, Type: entity.PatientLinkSeeAlso,
+	}}, target.Link)
+
+	_, err = s.uc.LinkPatients(ctx, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "patients are already linked")
+
+	// unlink
+	p.Meta.Profile = []string{entity.StructureDefinitionPatientUnlinkRequest}
+	p.Parameter = p.Parameter[:2]
+
+	task, err = s.uc.UnlinkPatients(ctx, p)
+	s.Require().NoError(err)
+	s.Equal(entity.TaskBusinessStatusPatientsUnlinked, task.BusinessStatus.Text)
+	s.Equal("registrar-1", taskAdministrator(task))
+	s.Empty(source.Link)
+	s.Empty(target.Link)
+
+	_, err = s.uc.UnlinkPatients(ctx, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "patients are not linked")
+
+	// refer is set on the source, the target links back by seealso
+	p.Meta.Profile = []string{entity.StructureDefinitionPatientLinkRequest}
+	p.Parameter = append(p.Parameter, &fhirModel.ParametersParameter{
+		Name: "type", ValueX: fhirModel.ValueX{ValueCode: converto.StringPointer(entity.PatientLinkRefer)},
+	})
+
+	_, err = s.uc.LinkPatients(ctx, p)
+	s.Require().NoError(err)
+	s.Equal([]*fhirModel.PatientLink{{
+		Other: &fhirModel.Reference{Reference: "Patient/target"}, Type: entity.PatientLinkRefer,
+	}}, source.Link)
+	s.Equal([]*fhirModel.PatientLink{{
+		Other: &fhirModel.Reference{Reference: "Patient/source"}, Type: entity.PatientLinkSeeAlso,
+	}}, target.Link)
+
+	p.Meta.Profile = []string{entity.StructureDefinitionPatientUnlinkRequest}
+	p.Parameter = p.Parameter[:2]
+
+	_, err = s.uc.UnlinkPatients(ctx, p)
+	s.Require().NoError(err)
+	s.Empty(source.Link)
+	s.Empty(target.Link)
+}
+
+func (s *useCaseTestSuite) TestDeactivatePatient() {
+	ctx := s.administratorCtx()
+	patient := preparePhonePatient(s)
+
+	p := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("5c9d1e2f-0000-4000-8000-000000000007"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientDeactivateRequest}},
+		},
+	}
+
+	_, err := s.uc.DeactivatePatient(ctx, patient.ID, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "missing reason parameter")
+
+	p.Parameter = []*fhirModel.ParametersParameter{
+		{Name: "reason", ValueX: fhirModel.ValueX{ValueCode: converto.StringPointer("unknown")}},
+	}
+
+	_, err = s.uc.DeactivatePatient(ctx, patient.ID, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "unsupported reason parameter value")
+
+	p.Parameter[0].ValueCode = converto.StringPointer(entity.PatientActivityReasonDuplicate)
+
+	task, err := s.uc.DeactivatePatient(ctx, patient.ID, p)
+	s.Require().NoError(err)
+	s.False(converto.BoolValue(patient.Active))
+	s.Equal(entity.TaskBusinessStatusPatientDeactivated, task.BusinessStatus.Text)
+	s.Equal(entity.PatientActivityReasonDuplicate, task.StatusReason.Codings[0].Code)
+	s.Equal("registrar-1", taskAdministrator(task))
+	s.Equal(fmt.Sprintf("Patient/%s", patient.ID), task.For.Reference)
+
+	b := s.fhir.bundles[len(s.fhir.bundles)-1]
+	s.Require().Len(b.Entry, 3)
+	s.Equal(patient, b.Entry[2].Resource)
+
+	_, err = s.uc.DeactivatePatient(ctx, patient.ID, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "patient has inactive status")
+}
+
+func (s *useCaseTestSuite) TestReactivatePatient() {
+	ctx := s.administratorCtx()
+	patient := preparePhonePatient(s)
+	patient.Identifier = []*fhirModel.Identifier{{
+		Type:  &fhirModel.CodeableConcept{Codings: []*fhirModel.Coding{{Code: "NI"}}},
+		Value: "1000000001",
+	}}
+
+	p := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("5c9d1e2f-0000-4000-8000-000000000008"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientReactivateRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{
+				Name:   "reason",
+				ValueX: fhirModel.ValueX{ValueCode: converto.StringPointer(entity.PatientActivityReasonEnteredInError)},
+			},
+		},
+	}
+
+	_, err := s.uc.ReactivatePatient(ctx, patient.ID, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "patient has active status")
+
+	patient.Active = converto.BoolPointer(false)
+	patient.Link = []*fhirModel.PatientLink{{
+		Other: &fhirModel.Reference{Reference: "Patient/1"}, Type: entity.PatientLinkReplacedBy,
+	}}
+
+	_, err = s.uc.ReactivatePatient(ctx, patient.ID, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "patient is merged into another one")
+
+	// the same person was registered while the patient was inactive
+	patient.Link = nil
+	s.fhir.duplPatients = []*fhirModel.Patient{{}}
+
+	_, err = s.uc.ReactivatePatient(ctx, patient.ID, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "such person already exists")
+	s.False(converto.BoolValue(patient.Active))
+
+	s.fhir.duplPatients = nil
+
+	task, err := s.uc.ReactivatePatient(ctx, patient.ID, p)
+	s.Require().NoError(err)
+	s.True(converto.BoolValue(patient.Active))
+	s.Equal(entity.TaskBusinessStatusPatientReactivated, task.BusinessStatus.Text)
+	s.Equal(entity.PatientActivityReasonEnteredInError, task.StatusReason.Codings[0].Code)
+	s.Equal("registrar-1", taskAdministrator(task))
+}
+
+func (s *useCaseTestSuite) TestMarkPatientDeceased() {
+	ctx := s.administratorCtx()
+	patient := preparePhonePatient(s)
+	// the registry date is local, the requested date is the previous day in utc
+	deathDate := time.Date(2026, 1, 1, 1, 0, 0, 0, time.FixedZone("AST", 3*60*60))
+
+	p := &fhirModel.Parameters{
+		Resource: fhirModel.Resource{
+			ID:   fhirModel.ID("5c9d1e2f-0000-4000-8000-000000000009"),
+			Meta: &fhirModel.Meta{Profile: []string{entity.StructureDefinitionPatientMarkDeceasedRequest}},
+		},
+		Parameter: []*fhirModel.ParametersParameter{
+			{Name: "source", ValueX: fhirModel.ValueX{ValueCode: converto.StringPointer("unknown")}},
+		},
+	}
+
+	_, err := s.uc.MarkPatientDeceased(ctx, patient.ID, p)
+	s.Require().Error(err)
+
+	cErr, ok := err.(*cerror.CError)
+	s.True(ok)
+	s.Equal(map[string]string{
+		"Parameters.parameter.date":   "missing date parameter",
+		"Parameters.parameter.source": "unsupported source parameter value",
+	}, cErr.Payload())
+
+	p.Parameter = append(p.Parameter, &fhirModel.ParametersParameter{
+		Name: "date", ValueX: fhirModel.ValueX{ValueDateTime: (*fhirModel.DateTime)(converto.TimePointer(deathDate))},
+	})
+	p.Parameter[0].ValueCode = converto.StringPointer(entity.DeathSourceCivilRegistry)
+
+	_, err = s.uc.MarkPatientDeceased(ctx, patient.ID, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "patient has no document to verify death by")
+
+	patient.Identifier = []*fhirModel.Identifier{{
+		Type:  &fhirModel.CodeableConcept{Codings: []*fhirModel.Coding{{Code: "NI"}}},
+		Value: "1000000001",
+	}}
+
+	_, err = s.uc.MarkPatientDeceased(ctx, patient.ID, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "death is not registered in civil registry")
+
+	s.extReg.result = &entity.ExtDocRegistrySearchResult{Deceased: true, DeathDate: "2026-01-02"}
+
+	_, err = s.uc.MarkPatientDeceased(ctx, patient.ID, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "death date does not match civil registry record")
+	s.Empty(s.fhir.bundles)
+
+	s.extReg.result.DeathDate = "2026-01-01"
+
+	// more than one page of the patient tasks, the held review is canceled too
+	patientRef := fmt.Sprintf("Patient/%s", patient.ID)
+	s.fhir.pagedTasks = []*fhirModel.Task{
+		{
+			DomainResource: fhirModel.DomainResource{Resource: fhirModel.Resource{ID: "other"}},
+			Status:         fhirModel.TaskStatusInProgress,
+			For:            &fhirModel.Reference{Reference: "Patient/other"},
+		},
+		{
+			DomainResource: fhirModel.DomainResource{Resource: fhirModel.Resource{ID: "completed"}},
+			Status:         fhirModel.TaskStatusCompleted,
+			For:            &fhirModel.Reference{Reference: patientRef},
+		},
+	}
+
+	for i := 0; i < 100; i++ {
+		s.fhir.pagedTasks = append(s.fhir.pagedTasks, &fhirModel.Task{
+			DomainResource: fhirModel.DomainResource{Resource: fhirModel.Resource{ID: fhirModel.ID(fmt.Sprint(i))}},
+			Status:         fhirModel.TaskStatusInProgress,
+			For:            &fhirModel.Reference{Reference: patientRef},
+		})
+	}
+
+	held := &fhirModel.Task{
+		DomainResource: fhirModel.DomainResource{Resource: fhirModel.Resource{ID: "held"}},
+		Status:         fhirModel.TaskStatusOnHold,
+		For:            &fhirModel.Reference{Reference: patientRef},
+	}
+	s.fhir.pagedTasks = append(s.fhir.pagedTasks, held)
+
+	// the pending email of the canceled email change is dropped
+	s.fhir.pagedTasks[2].Meta = &fhirModel.Meta{Profile: []string{fhirModel.StructureDefinitionTaskPatientUpdateEmail}}
+	telecomCount := len(patient.Telecom)
+	patient.Telecom = append(patient.Telecom, &fhirModel.ContactPoint{
+		System: fhirModel.TelecomSystemEmail,
+		Value:  "test@test1.test",
+		Extension: []*fhirModel.Extension{
+			{
+				URL:    entity.StructureDefinitionContactPointVerificationStatus,
+				ValueX: fhirModel.ValueX{ValueCode: converto.StringPointer(entity.ContactPointVerificationStatusPending)},
+			},
+		},
+	})
+
+	task, err := s.uc.MarkPatientDeceased(ctx, patient.ID, p)
+	s.Require().NoError(err)
+	s.Equal(entity.TaskBusinessStatusPatientDeceased, task.BusinessStatus.Text)
+	s.Equal(entity.DeathSourceCivilRegistry, task.StatusReason.Codings[0].Code)
+	s.Equal(fmt.Sprintf("Patient/%s", patient.ID), task.For.Reference)
+	s.Equal("registrar-1", taskAdministrator(task))
+	s.True(deathDate.Equal(patient.DeceasedDateTime.Time()))
+	s.Len(patient.Telecom, telecomCount)
+
+	searchArgs := s.fhir.searchTaskByParamsArgs[len(s.fhir.searchTaskByParamsArgs)-2:]
+	s.Equal(patient.ID, searchArgs[0].PatientID)
+	s.Equal("in-progress,on-hold", searchArgs[0].Status)
+	s.Equal(0, searchArgs[0].Offset)
+	s.Equal(100, searchArgs[1].Offset)
+
+	s.Require().Len(s.fhir.bundles, 1)
+	b := s.fhir.bundles[0]
+	s.Require().Len(b.Entry, 104)
+	s.Equal(patient, b.Entry[2].Resource)
+	s.Equal(held, b.Entry[103].Resource)
+
+	for _, t := range s.fhir.pagedTasks[2:] {
+		s.Equal(fhirModel.TaskStatusCanceled, t.Status)
+		s.Equal(entity.TaskBusinessStatusCanceledPatientDeceased, t.BusinessStatus.Text)
+	}
+
+	s.Equal(fhirModel.TaskStatusInProgress, s.fhir.pagedTasks[0].Status)
+	s.Equal(fhirModel.TaskStatusCompleted, s.fhir.pagedTasks[1].Status)
+
+	_, err = s.uc.MarkPatientDeceased(ctx, patient.ID, p)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "patient has deceased status")
+
+	// the deaths from the other sources are not verified by the registry
+	patient.DeceasedDateTime = nil
+	s.extReg.result = nil
+	p.Parameter[0].ValueCode = converto.StringPointer(entity.DeathSourceHealthcareFacility)
+
+	_, err = s.uc.MarkPatientDeceased(ctx, patient.ID, p)
+	s.Require().NoError(err)
+}
+
+// taskAdministrator returns the administrator recorded in the task
+func taskAdministrator(t *fhirModel.Task) string {
+	for _, e := range t.Extension {
+		if e.URL == entity.StructureDefinitionTaskAdministration {
+			return converto.StringValue(e.Extension[0].ValueString)
+		}
+	}
+
+	return ""
+}
+
+func prepareMergedPatients(s *useCaseTestSuite) (source, target *fhirModel.Patient) {
+	source, target = new(fhirModel.Patient), new(fhirModel.Patient)
+
+	s.Require().NoError(json.Unmarshal([]byte(`{
+		"resourceType": "Patient",
+		"id": "source",
+		"active": true,
+		"identifier": [
+			{"type": {"coding": [{"code": "NI"}]}, "value": "1000000002"},
+			{"type": {"coding": [{"code": "PPN"}]}, "value": "P100"}
+		],
+		"telecom": [{"system": "phone", "use": "mobile", "value": "+966500000002"}]
+	}`), source))
+	s.Require().NoError(json.Unmarshal([]byte(`{
+		"resourceType": "Patient",
+		"id": "target",
+		"active": true,
+		"identifier": [{"type": {"coding": [{"code": "NI"}]}, "value": "1000000001"}],
+		"telecom": [{"system": "phone", "use": "mobile", "value": "+966500000001"}]
+	}`), target))
+
+	s.fhir.patients = []*fhirModel.Patient{source, target}
+
+	return source, target
+}
+
+func preparePhonePatient(s *useCaseTestSuite) *fhirModel.Patient {
+	patient := new(fhirModel.Patient)
+	err := json.Unmarshal([]byte(`{
+		"resourceType": "Patient",
+		"id": "244a8e88-c0b0-4d60-b5d7-14afbe79f5f5",
+		"active": true,
+		"birthDate": "2000-01-01",
+		"telecom": [{"system": "phone", "use": "mobile", "value": "+380673212121"}]
+	}`), patient)
+	s.NoError(err)
+
+	s.fhir.patients = []*fhirModel.Patient{patient}
+
+	return patient
+}
+
 func mapFromStruct(src interface{}) map[string]interface{} {
 	b, _ := json.Marshal(src)
 	m := make(map[string]interface{})
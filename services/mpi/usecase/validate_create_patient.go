@@ -0,0 +1,74 @@
+package usecase
+
+import (
+	"context"
+
+	"wasfaty.api/pkg/cerror"
+	"wasfaty.api/pkg/fhir/ferror"
+	fhirModel "wasfaty.api/pkg/fhir/model"
+	"wasfaty.api/services/mpi/entity"
+)
+
+// ValidateCreatePatient runs the create-request validations without sending an otp and saving the request,
+// every validation issue found is returned in the outcome
+func (uc *UseCase) ValidateCreatePatient(ctx context.Context, params *fhirModel.Parameters) (
+	*fhirModel.OperationOutcome, error) {
+	patient, err := uc.unmarshalPatientParam(ctx, params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &fhirModel.OperationOutcome{
+		DomainResource: fhirModel.DomainResource{
+			Resource: fhirModel.Resource{ResourceType: fhirModel.ResourceOperationOutcome},
+		},
+		Issue: []*fhirModel.OperationOutcomeIssue{},
+	}
+
+	validations := []func() error{
+		func() error { return uc.validateCreatePatientParameters(ctx, params, patient) },
+		func() error { return uc.validatePatientProfile(ctx, params.Meta, patient.Meta) },
+		func() error { return uc.validateCreatePatientByInternalRules(ctx, patient) },
+		func() error {
+			record, err := uc.validateByExtDocRegistry(ctx, patient)
+			if err != nil {
+				return err
+			}
+
+			issues, err := uc.crossCheckDemographics(ctx, params, 0, patient, record)
+			o.Issue = append(o.Issue, issues...)
+
+			return err
+		},
+		func() error { return uc.validatePatientDupls(ctx, patient) },
+		func() error {
+			matches, err := uc.searchPossibleDuplicates(ctx, patient)
+			o.Issue = append(o.Issue, possibleDuplicateIssues(0, matches)...)
+
+			return err
+		},
+	}
+
+	for _, validate := range validations {
+		err := validate()
+		if err == nil {
+			continue
+		}
+
+		if kind := cerror.ErrKind(err); kind != cerror.KindBadValidation && kind != cerror.KindBadParams {
+			return nil, err
+		}
+
+		o.Issue = append(o.Issue, ferror.OutcomeFromError(err).Issue...)
+	}
+
+	if len(o.Issue) == 0 {
+		o.Issue = append(o.Issue, &fhirModel.OperationOutcomeIssue{
+			Severity:    entity.OutcomeIssueSeverityInformation,
+			Code:        entity.OutcomeIssueCodeInformational,
+			Diagnostics: "validation passed",
+		})
+	}
+
+	return o, nil
+}
@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"wasfaty.api/pkg/cerror"
+)
+
+// validationIssues accumulates the field errors of several validations
+// to return them as a single validation error
+type validationIssues map[string]string
+
+// add merges the field errors of a validation error into the issues,
+// any other error is returned back to stop the validation
+func (v validationIssues) add(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var vErr *cerror.ValidationError
+	if errors.As(err, &vErr) {
+		v.merge(vErr.Payload())
+		return nil
+	}
+
+	// the internal and the remote errors could carry a map payload too, they are not the field errors
+	var cErr *cerror.CError
+	if kind := cerror.ErrKind(err); errors.As(err, &cErr) &&
+		(kind == cerror.KindBadValidation || kind == cerror.KindBadParams) {
+		if m, ok := cErr.Payload().(map[string]string); ok {
+			v.merge(m)
+			return nil
+		}
+	}
+
+	return err
+}
+
+// merge keeps all the errors of the same field, the messages are joined
+func (v validationIssues) merge(m map[string]string) {
+	for field, msg := range m {
+		if prev, ok := v[field]; ok && prev != msg {
+			msg = prev + "; " + msg
+		}
+
+		v[field] = msg
+	}
+}
+
+func (v validationIssues) err(ctx context.Context) error {
+	if len(v) == 0 {
+		return nil
+	}
+
+	return cerror.NewValidationError(ctx, v).LogError()
+}